@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"time"
 
 	"oc-ai/internal/ai"
 
@@ -18,7 +22,7 @@ var aiCmd = &cobra.Command{
 	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		prompt := strings.Join(args, " ")
-		aiClient := ai.NewClient(cfg.OpenAIKey, activeTool, cmd.Flag("ai-model").Value.String())
+		aiClient := newAIClient(cmd)
 
 		// Get current context
 		ctx, err := cliClient.GetContext()
@@ -26,17 +30,64 @@ var aiCmd = &cobra.Command{
 			return fmt.Errorf("failed to get cluster context: %w", err)
 		}
 
-		// Generate command
-		command, explanation, safety, err := aiClient.GenerateCommand(prompt, ctx)
-		if err != nil {
-			return err
+		autoConfirm := cmd.Flag("yes").Value.String() == "true"
+		resources := loadResourceCatalog(ctx)
+
+		// Generate command, answering clarifying questions until the model
+		// settles on one; Ctrl-C cancels the request
+		var result *ai.GenerateResult
+		for round := 0; ; round++ {
+			apiCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			progress := newGenerationProgress()
+			result, err = aiClient.Generate(apiCtx, ai.GenerateRequest{
+				Prompt:             prompt,
+				Context:            ctx,
+				Resources:          resources,
+				Progress:           progress.Callback(),
+				Candidates:         candidateCount(cmd),
+				AllowClarification: !autoConfirm && round < maxClarifications,
+				AllowPlan:          true,
+			})
+			progress.Done()
+			stop()
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					fmt.Println("Command generation cancelled")
+					return nil
+				}
+				return err
+			}
+			if result.Clarification == nil {
+				break
+			}
+
+			answer, ok := askClarification(stdin, result.Clarification, ctx)
+			if !ok {
+				fmt.Println("Command cancelled")
+				return nil
+			}
+			prompt = ai.ClarifiedPrompt(prompt, result.Clarification.Question, answer)
 		}
 
+		if result.Plan != nil {
+			return executePlan(cmd, result, ctx)
+		}
+
+		// --yes takes the best ranked candidate
+		if !autoConfirm && !chooseCandidate(stdin, aiClient, prompt, ctx, result) {
+			fmt.Println("Command cancelled")
+			return nil
+		}
+		command, explanation, safety := result.Command, result.Explanation, result.Safety
+
 		// Remove duplicate CLI tool name if present
 		if strings.HasPrefix(command, activeTool+" ") {
 			command = strings.TrimPrefix(command, activeTool+" ")
 		}
 
+		if result.Offline {
+			fmt.Println("\nNo AI model reachable - command generated offline by local rules")
+		}
 		fmt.Printf("\nExplanation: %s\n", explanation)
 		fmt.Printf("Safety Level: %s/5\n", safety)
 		fmt.Printf("Command: %s %s\n\n", activeTool, command)
@@ -51,13 +102,24 @@ var aiCmd = &cobra.Command{
 			return fmt.Errorf("safety level must be between 1 and 5, got %d", safetyLevel)
 		}
 
-		if cmd.Flag("yes").Value.String() == "false" && safetyLevel >= 3 {
+		entry := newHistoryEntry(SourceAI, command, ctx)
+		entry.Prompt, entry.Explanation, entry.Safety = prompt, explanation, safetyLevel
+		entry.Untrusted = result.Untrusted
+
+		// Commands generated from cluster data are always confirmed, even with --yes
+		if result.Untrusted {
+			printInjectionWarnings(result.InjectionFindings)
+			fmt.Println("⚠️ Warning: This command was generated from untrusted cluster data")
+			if !confirm("Type 'yes' to execute: ", "yes") {
+				fmt.Println("Command cancelled")
+				recordDeclined(entry)
+				return nil
+			}
+		} else if cmd.Flag("yes").Value.String() == "false" && safetyLevel >= 3 {
 			fmt.Printf("⚠️ Warning: This command may be destructive (Safety Level: %d/5)\n", safetyLevel)
-			fmt.Print("Confirm execution? [y/N]: ")
-			reader := bufio.NewReader(os.Stdin)
-			response, _ := reader.ReadString('\n')
-			if strings.ToLower(strings.TrimSpace(response)) != "y" {
+			if !confirm("Confirm execution? [y/N]: ", "y") {
 				fmt.Println("Command cancelled")
+				recordDeclined(entry)
 				return nil
 			}
 		}
@@ -68,8 +130,11 @@ var aiCmd = &cobra.Command{
 			return nil
 		}
 
-		// Execute command
+		// Execute command, timing the execution rather than the confirmation
+		entry.Timestamp = time.Now()
 		output, err := cliClient.Execute(command)
+		entry.SetOutcome(output, err)
+		recordHistory(entry)
 		if err != nil {
 			return fmt.Errorf("error executing command: %v\nOutput: %s", err, output)
 		}
@@ -79,20 +144,36 @@ var aiCmd = &cobra.Command{
 			fmt.Println(output)
 		}
 
-		// Add to history
-		if historyCmd := findHistoryCommand(); historyCmd != nil {
-			if err := historyCmd.AddToHistory(command); err != nil {
-				fmt.Printf("Warning: Failed to save command to history: %v\n", err)
-			}
-		}
-
 		return nil
 	},
 }
 
+// executePlan shows a generated plan and runs it step by step
+func executePlan(cmd *cobra.Command, result *ai.GenerateResult, ctx map[string]string) error {
+	printPlan(result.Plan)
+
+	if cmd.Flag("dry-run").Value.String() == "true" {
+		fmt.Println("Dry run - plan not executed")
+		return nil
+	}
+
+	// Plans generated from cluster data are confirmed step by step, even with --yes
+	if result.Untrusted {
+		printInjectionWarnings(result.InjectionFindings)
+		fmt.Println("⚠️ Warning: This plan was generated from untrusted cluster data")
+	}
+	return runPlan(stdin, result.Plan, planOptions{
+		Source:      SourceAI,
+		Context:     ctx,
+		AutoConfirm: cmd.Flag("yes").Value.String() == "true",
+		Untrusted:   result.Untrusted,
+	})
+}
+
 var historyManager *HistoryCommand
 
 func init() {
+	aiCmd.Flags().Int("candidates", 1, "Generate several candidate commands and pick one")
 	rootCmd.AddCommand(aiCmd)
 	var err error
 	historyManager, err = NewHistoryCommand()
@@ -104,3 +185,116 @@ func init() {
 func findHistoryCommand() *HistoryCommand {
 	return historyManager
 }
+
+// newAIClient creates an AI client from the loaded config and common flags
+func newAIClient(cmd *cobra.Command) *ai.Client {
+	model := cfg.DefaultModel
+	if model == "" || cmd.Flags().Changed("ai-model") {
+		model = cmd.Flag("ai-model").Value.String()
+	}
+
+	return newModelClient(cmd, activeTool, model, cfg.CacheEnabled)
+}
+
+// newModelClient creates an AI client for a specific tool and model,
+// optionally backed by the persistent prompt cache
+func newModelClient(cmd *cobra.Command, tool, model string, useCache bool) *ai.Client {
+	apiKey := cfg.OpenAIKey
+	if cassette != nil && cassette.Replaying() && apiKey == "" {
+		// Replayed responses don't need a real key
+		apiKey = "replay"
+	}
+
+	aiClient := ai.NewClient(apiKey, tool, model)
+	aiClient.SetBaseURL(cfg.OpenAIBaseURL)
+	aiClient.SetFallbackModels(cfg.FallbackModels)
+	aiClient.SetRetryPolicy(ai.RetryPolicy{
+		MaxRetries:     cfg.AIMaxRetries,
+		InitialBackoff: cfg.AIInitialBackoff,
+		MaxBackoff:     cfg.AIMaxBackoff,
+		Timeout:        cfg.AITimeout,
+	})
+	if offline, _ := cmd.Flags().GetBool("offline"); offline {
+		aiClient.SetOffline(true)
+	}
+	if usageStore, err := ai.OpenUsageStore(); err != nil {
+		fmt.Printf("Warning: Failed to open usage store: %v\n", err)
+	} else {
+		aiClient.SetUsage(usageStore, ai.Budget{
+			Daily:   cfg.DailyTokenBudget,
+			Session: cfg.SessionTokenBudget,
+			Action:  cfg.BudgetAction,
+		}, cmd.Name())
+	}
+	if cfg.RedactEnabled {
+		redactor, err := ai.NewRedactor(cfg.RedactPatterns, cfg.RedactHashNames)
+		if err != nil {
+			fmt.Printf("Warning: Failed to configure redaction, using defaults: %v\n", err)
+			redactor, _ = ai.NewRedactor(nil, cfg.RedactHashNames)
+		}
+		aiClient.SetRedactor(redactor)
+	}
+	aiClient.SetPrompts(loadPrompts())
+	if cassette != nil {
+		aiClient.SetTransport(cassette.WrapHTTP)
+	}
+	// The persistent cache would hide requests from the cassette
+	if useCache && cassette == nil {
+		diskCache, err := openDiskCache()
+		if err != nil {
+			fmt.Printf("Warning: Failed to open prompt cache: %v\n", err)
+		} else {
+			aiClient.SetDiskCache(diskCache)
+		}
+	}
+	return aiClient
+}
+
+// loadPrompts returns the prompt registry with user overrides, falling back
+// to the built-in templates if an override is invalid
+func loadPrompts() *ai.PromptRegistry {
+	prompts, err := ai.LoadPromptRegistry(cfg.OrgInstructions)
+	if err != nil {
+		fmt.Printf("Warning: Failed to load prompt overrides, using built-in prompts: %v\n", err)
+		return ai.DefaultPrompts(cfg.OrgInstructions)
+	}
+	return prompts
+}
+
+// loadResourceCatalog returns the api-resources catalog for the current
+// context, or nil when grounding is disabled or the cluster can't be queried.
+func loadResourceCatalog(ctx map[string]string) *ai.ResourceCatalog {
+	if !cfg.GroundResources {
+		return nil
+	}
+
+	ttl := cfg.ResourceCacheTTL
+	if cassette != nil {
+		// Always query the cluster so the calls end up in the cassette
+		ttl = time.Nanosecond
+	}
+
+	catalog, err := ai.LoadResourceCatalog(cliClient, ctx["context"], ttl)
+	if err != nil {
+		fmt.Printf("Warning: Could not load cluster resource types: %v\n", err)
+		return nil
+	}
+	return catalog
+}
+
+// stdin is shared by all prompts so buffered input isn't lost between them
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm prompts on stdin and reports whether the answer matches accept
+func confirm(question, accept string) bool {
+	fmt.Print(question)
+	response, _ := stdin.ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(response)) == accept
+}
+
+// printInjectionWarnings reports instruction-like text found in cluster data
+func printInjectionWarnings(findings []ai.InjectionFinding) {
+	for _, f := range findings {
+		fmt.Printf("⚠️ Possible prompt injection in %s\n", f)
+	}
+}
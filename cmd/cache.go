@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"oc-ai/internal/ai"
+
+	"github.com/spf13/cobra"
+)
+
+// diskCaches are the caches opened by this process; their hit counters are
+// written when it exits
+var diskCaches []*ai.DiskCache
+
+// openDiskCache opens the persistent prompt cache using the configured limits
+func openDiskCache() (*ai.DiskCache, error) {
+	dc, err := ai.NewDiskCache(cfg.CacheTTL, cfg.CacheMaxEntries, int64(cfg.CacheMaxSizeMB)<<20)
+	if err == nil {
+		diskCaches = append(diskCaches, dc)
+	}
+	return dc, err
+}
+
+// flushDiskCaches writes the hit counters of the opened caches; failures are
+// ignored since stats are best effort
+func flushDiskCaches() {
+	for _, dc := range diskCaches {
+		dc.Flush()
+	}
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the persistent prompt cache",
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show prompt cache statistics",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		diskCache, err := openDiskCache()
+		if err != nil {
+			return fmt.Errorf("failed to open cache: %w", err)
+		}
+
+		stats, err := diskCache.Stats()
+		if err != nil {
+			return fmt.Errorf("failed to read cache: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Directory:\t%s\n", stats.Dir)
+		fmt.Fprintf(w, "Entries:\t%d (%d expired)\n", stats.Entries, stats.Expired)
+		fmt.Fprintf(w, "Size:\t%.1f KiB of %.1f MiB\n", float64(stats.Bytes)/1024, float64(stats.MaxBytes)/(1<<20))
+		fmt.Fprintf(w, "Limits:\t%d entries, TTL %s\n", stats.MaxEntries, stats.TTL)
+		if stats.Entries > 0 {
+			fmt.Fprintf(w, "Oldest:\t%s\n", stats.Oldest.Format("2006-01-02 15:04:05"))
+			fmt.Fprintf(w, "Newest:\t%s\n", stats.Newest.Format("2006-01-02 15:04:05"))
+		}
+		if total := stats.Hits + stats.Misses; total > 0 {
+			fmt.Fprintf(w, "Hit rate:\t%.1f%% (%d hits, %d misses)\n",
+				float64(stats.Hits)*100/float64(total), stats.Hits, stats.Misses)
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all prompt cache entries",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		diskCache, err := openDiskCache()
+		if err != nil {
+			return fmt.Errorf("failed to open cache: %w", err)
+		}
+
+		removed, err := diskCache.Clear()
+		if err != nil {
+			return fmt.Errorf("failed to clear cache: %w", err)
+		}
+		fmt.Printf("Removed %d cache entries\n", removed)
+		return nil
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
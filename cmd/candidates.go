@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"oc-ai/internal/ai"
+
+	"github.com/spf13/cobra"
+)
+
+// candidateCount returns how many candidates to request, from the
+// --candidates flag or the config
+func candidateCount(cmd *cobra.Command) int {
+	if cmd.Flags().Changed("candidates") {
+		n, _ := cmd.Flags().GetInt("candidates")
+		return n
+	}
+	return cfg.Candidates
+}
+
+// pickCandidate lists the ranked candidates and asks which one to use. It
+// returns the index of the choice, or false if the user cancelled.
+func pickCandidate(reader *bufio.Reader, candidates []ai.Candidate) (int, bool) {
+	fmt.Println("\nCandidates (best first):")
+	for i, c := range candidates {
+		fmt.Printf("  %d) %s %s\n", i+1, activeTool, c.Command)
+		fmt.Printf("     %s\n", c.Explanation)
+		fmt.Printf("     Safety %s/5, score %d (%s)\n", c.Safety, c.Score, strings.Join(c.Reasons, ", "))
+	}
+
+	for {
+		fmt.Printf("Pick a candidate [1-%d, Enter for 1, q to cancel]: ", len(candidates))
+		response, err := reader.ReadString('\n')
+		response = strings.ToLower(strings.TrimSpace(response))
+		switch {
+		case response == "" && err != nil:
+			return 0, false
+		case response == "":
+			return 0, true
+		case response == "q":
+			return 0, false
+		}
+		if n, convErr := strconv.Atoi(response); convErr == nil && n >= 1 && n <= len(candidates) {
+			return n - 1, true
+		}
+		fmt.Println("Invalid choice")
+	}
+}
+
+// chooseCandidate lets the user pick among the result's candidates, applies
+// the choice to the result and records it. It returns false if cancelled.
+func chooseCandidate(reader *bufio.Reader, aiClient *ai.Client, prompt string, ctx map[string]string, result *ai.GenerateResult) bool {
+	if len(result.Candidates) <= 1 {
+		return true
+	}
+
+	chosen, ok := pickCandidate(reader, result.Candidates)
+	if !ok {
+		return false
+	}
+	pick := result.Candidates[chosen]
+	result.Command, result.Explanation, result.Safety = pick.Command, pick.Explanation, pick.Safety
+
+	if err := aiClient.RecordChoice(prompt, ctx, result, chosen); err != nil {
+		fmt.Printf("Warning: Failed to record candidate choice: %v\n", err)
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"oc-ai/internal/ai"
+)
+
+const (
+	// maxClarifications bounds the questions asked for a single prompt
+	maxClarifications = 3
+	// maxClarificationChoices bounds the resources offered as choices
+	maxClarificationChoices = 20
+)
+
+// clarificationChoices returns the suggested answers, listing the resources
+// of the requested type in the current namespace if needed
+func clarificationChoices(c *ai.Clarification, ctx map[string]string) []string {
+	if c.ChoicesFrom == "" {
+		return c.Choices
+	}
+
+	command := "get " + c.ChoicesFrom + " -o name"
+	if ns := ctx["namespace"]; ns != "" {
+		command += " -n " + ns
+	}
+	output, err := cliClient.Execute(command)
+	if err != nil {
+		fmt.Printf("Warning: Could not list %s: %v\n", c.ChoicesFrom, err)
+		return nil
+	}
+
+	names := ai.ParseResourceNames(output)
+	if len(names) > maxClarificationChoices {
+		names = names[:maxClarificationChoices]
+	}
+	return names
+}
+
+// askClarification shows the model's question with numbered choices and
+// returns the answer, or false if the user gave none
+func askClarification(reader *bufio.Reader, c *ai.Clarification, ctx map[string]string) (string, bool) {
+	fmt.Printf("\n❓ %s\n", c.Question)
+	choices := clarificationChoices(c, ctx)
+	for i, choice := range choices {
+		fmt.Printf("  %d) %s\n", i+1, choice)
+	}
+
+	if len(choices) > 0 {
+		fmt.Print("Answer (number or text, empty to cancel): ")
+	} else {
+		fmt.Print("Answer (empty to cancel): ")
+	}
+	answer, _ := reader.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return "", false
+	}
+	if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(choices) {
+		answer = choices[n-1]
+	}
+	return answer, true
+}
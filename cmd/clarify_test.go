@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"oc-ai/internal/ai"
+)
+
+func TestAskClarification(t *testing.T) {
+	question := &ai.Clarification{Question: "Which deployment?", Choices: []string{"web", "api"}}
+	tests := []struct {
+		name     string
+		c        *ai.Clarification
+		input    string
+		answer   string
+		answered bool
+	}{
+		{"number", question, "2\n", "api", true},
+		{"text", question, " worker \n", "worker", true},
+		{"number out of range", question, "3\n", "3", true},
+		{"number without choices", &ai.Clarification{Question: "How many replicas?"}, "3\n", "3", true},
+		{"empty cancels", question, "\n", "", false},
+		{"end of input cancels", question, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, ok := askClarification(bufio.NewReader(strings.NewReader(tt.input)), tt.c, nil)
+			if answer != tt.answer || ok != tt.answered {
+				t.Errorf("askClarification() = %q, %v, want %q, %v", answer, ok, tt.answer, tt.answered)
+			}
+		})
+	}
+}
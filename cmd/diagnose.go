@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"oc-ai/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+var diagnoseCmd = &cobra.Command{
+	Use:   "diagnose [command]",
+	Short: "Run a read-only command and let the AI diagnose its output",
+	Long: `Run a read-only oc/kubectl command (get, describe, logs, events, ...) and ask the
+AI model to explain what its output shows and what to check next.
+Use --stdin to diagnose output you already have.`,
+	Example: `  oc-ai diagnose describe pod web-7d4f9
+  oc-ai diagnose get events -n staging
+  oc logs web-7d4f9 | oc-ai diagnose --stdin logs web-7d4f9`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		command := strings.Join(args, " ")
+
+		// Remove duplicate CLI tool name if present
+		if strings.HasPrefix(command, activeTool+" ") {
+			command = strings.TrimPrefix(command, activeTool+" ")
+		}
+
+		var output string
+		if fromStdin, _ := cmd.Flags().GetBool("stdin"); fromStdin {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read output: %w", err)
+			}
+			output = string(data)
+		} else {
+			if err := checkDiagnosable(command); err != nil {
+				return err
+			}
+			var err error
+			output, err = cliClient.Execute(command)
+			if err != nil {
+				output = summarizeResult(output, err)
+			}
+		}
+		if strings.TrimSpace(output) == "" {
+			return fmt.Errorf("no output to diagnose")
+		}
+
+		aiClient := newAIClient(cmd)
+		apiCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("\nDiagnosis: %s %s\n\n", activeTool, command)
+		if _, err := aiClient.Diagnose(apiCtx, command, output, os.Stdout); err != nil {
+			fmt.Println()
+			if errors.Is(err, context.Canceled) {
+				fmt.Println("Diagnosis cancelled")
+				return nil
+			}
+			return fmt.Errorf("failed to diagnose: %w", err)
+		}
+		fmt.Println()
+		return nil
+	},
+}
+
+// diagnoseVerbs are the subcommands diagnose runs itself: they only read
+// and return once they have printed their output
+var diagnoseVerbs = map[string]bool{
+	"get":           true,
+	"describe":      true,
+	"logs":          true,
+	"events":        true,
+	"top":           true,
+	"explain":       true,
+	"status":        true,
+	"api-resources": true,
+	"api-versions":  true,
+	"version":       true,
+	"whoami":        true,
+	"projects":      true,
+	"cluster-info":  true,
+}
+
+// checkDiagnosable refuses commands outside diagnoseVerbs, and flags that
+// keep a command streaming until it is interrupted
+func checkDiagnosable(command string) error {
+	args := cli.ParseCommand(command)
+	verb := ""
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			verb = arg
+			break
+		}
+	}
+	if !diagnoseVerbs[verb] {
+		verbs := make([]string, 0, len(diagnoseVerbs))
+		for v := range diagnoseVerbs {
+			verbs = append(verbs, v)
+		}
+		sort.Strings(verbs)
+		return fmt.Errorf("diagnose only runs read-only commands (%s), not %q: use --stdin to diagnose other output",
+			strings.Join(verbs, ", "), verb)
+	}
+	for _, arg := range args {
+		name, _, _ := strings.Cut(arg, "=")
+		switch {
+		case name == "--follow" || name == "--watch" || name == "--watch-only",
+			name == "-w", verb == "logs" && name == "-f":
+			return fmt.Errorf("diagnose can't follow a command's output (%s): pipe a bounded output to --stdin instead", arg)
+		}
+	}
+	return nil
+}
+
+func init() {
+	diagnoseCmd.Flags().Bool("stdin", false, "Read the command output from stdin instead of running the command")
+	rootCmd.AddCommand(diagnoseCmd)
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"oc-ai/internal/ai"
+
+	"github.com/spf13/cobra"
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Measure command generation accuracy on a dataset",
+	Long: `Run every case of a JSONL dataset through command generation and compare the
+results with the expected commands. Commands are compared after normalization, so
+flag order, short/long flags and resource aliases don't matter.
+
+Each line is a JSON object:
+  {"prompt": "list pods in staging", "expected": "get pods -n staging", "safety": 1}
+
+Optional fields are "alternatives" (other accepted commands) and "context"
+(namespace, cluster, ... passed to the model).`,
+	Example: `  oc-ai eval --dataset cases.jsonl --model gpt-4-turbo
+  oc-ai eval --dataset cases.jsonl --model gpt-4-turbo --model gpt-4o-mini --format markdown`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataset, _ := cmd.Flags().GetString("dataset")
+		models, _ := cmd.Flags().GetStringSlice("model")
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		failUnder, _ := cmd.Flags().GetFloat64("fail-under")
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		offline, _ := cmd.Flags().GetBool("offline")
+
+		if dataset == "" {
+			return fmt.Errorf("--dataset is required")
+		}
+		if format != "text" && format != "json" && format != "markdown" {
+			return fmt.Errorf("invalid format %q: must be text, json or markdown", format)
+		}
+
+		cases, err := ai.LoadEvalCases(dataset)
+		if err != nil {
+			return err
+		}
+		if len(cases) == 0 {
+			return fmt.Errorf("dataset %s contains no cases", dataset)
+		}
+		switch {
+		case offline:
+			// The local rules are the same whatever the model
+			models = []string{"offline"}
+		case len(models) == 0:
+			models = []string{cfg.DefaultModel}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		var reports []*ai.EvalReport
+		for _, model := range models {
+			// No fallbacks or persistent cache, so every result comes from this model
+			client := newModelClient(cmd, activeTool, model, false)
+			client.SetFallbackModels(nil)
+			// Without a key the client silently uses the local rules, which
+			// would be scored under the model's name
+			if client.Offline() && !offline {
+				return fmt.Errorf("no API key or openai_base_url configured for %s: set OPENAI_API_KEY, or use --offline to evaluate the local rules", model)
+			}
+
+			fmt.Fprintf(os.Stderr, "Evaluating %s on %d cases...\n", model, len(cases))
+			report := ai.RunEval(ctx, client, cases, func(done int, result ai.EvalResult) {
+				if verbose {
+					status := "ok"
+					switch {
+					case result.Error != "":
+						status = "error: " + result.Error
+					case !result.Match:
+						status = "mismatch: " + result.Command
+					}
+					fmt.Fprintf(os.Stderr, "  [%d/%d] %s - %s\n", done, len(cases), result.Prompt, status)
+				}
+			})
+			reports = append(reports, report)
+			if ctx.Err() != nil {
+				fmt.Fprintln(os.Stderr, "Evaluation cancelled")
+				break
+			}
+		}
+
+		out := io.Writer(os.Stdout)
+		if output != "" {
+			file, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("failed to create report file: %w", err)
+			}
+			defer file.Close()
+			out = file
+		}
+
+		switch format {
+		case "json":
+			data, err := json.MarshalIndent(reports, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal report: %w", err)
+			}
+			fmt.Fprintln(out, string(data))
+		case "markdown":
+			writeEvalMarkdown(out, reports)
+		default:
+			writeEvalText(out, reports)
+		}
+
+		for _, r := range reports {
+			if failUnder > 0 && r.Accuracy() < failUnder {
+				return fmt.Errorf("%s accuracy %.1f%% is below %.1f%%", r.Model, r.Accuracy()*100, failUnder*100)
+			}
+		}
+		return nil
+	},
+}
+
+func writeEvalText(out io.Writer, reports []*ai.EvalReport) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tCASES\tACCURACY\tSAFETY EXACT\tSAFETY ±1\tERRORS\tAVG LATENCY")
+	for _, r := range reports {
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%s\t%s\t%d\t%s\n", r.Model, r.Cases, r.Accuracy()*100,
+			percentOf(r.SafetyExact, r.SafetyScored), percentOf(r.SafetyNear, r.SafetyScored),
+			r.Errors, r.AvgDuration.Round(time.Millisecond))
+	}
+	w.Flush()
+
+	for _, r := range reports {
+		var failures []ai.EvalResult
+		for _, result := range r.Results {
+			if !result.Match {
+				failures = append(failures, result)
+			}
+		}
+		if len(failures) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(out, "\nMismatches for %s:\n", r.Model)
+		for _, f := range failures {
+			fmt.Fprintf(out, "- %s\n", f.Prompt)
+			fmt.Fprintf(out, "    expected: %s\n", f.Expected)
+			if f.Error != "" {
+				fmt.Fprintf(out, "    error:    %s\n", f.Error)
+			} else {
+				fmt.Fprintf(out, "    got:      %s\n", f.Command)
+			}
+		}
+	}
+}
+
+func writeEvalMarkdown(out io.Writer, reports []*ai.EvalReport) {
+	fmt.Fprintln(out, "## Evaluation results")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "| Model | Cases | Accuracy | Safety exact | Safety ±1 | Errors | Avg latency |")
+	fmt.Fprintln(out, "|-------|-------|----------|--------------|-----------|--------|-------------|")
+	for _, r := range reports {
+		fmt.Fprintf(out, "| %s | %d | %.1f%% | %s | %s | %d | %s |\n", r.Model, r.Cases, r.Accuracy()*100,
+			percentOf(r.SafetyExact, r.SafetyScored), percentOf(r.SafetyNear, r.SafetyScored),
+			r.Errors, r.AvgDuration.Round(time.Millisecond))
+	}
+
+	for _, r := range reports {
+		fmt.Fprintf(out, "\n### %s\n\n", r.Model)
+		fmt.Fprintln(out, "| | Prompt | Expected | Got | Safety |")
+		fmt.Fprintln(out, "|---|--------|----------|-----|--------|")
+		for _, result := range r.Results {
+			mark := "✅"
+			if !result.Match {
+				mark = "❌"
+			}
+			got := "`" + result.Command + "`"
+			if result.Error != "" {
+				got = "error: " + result.Error
+			}
+			safety := fmt.Sprintf("%d", result.Safety)
+			if result.ExpectedSafety > 0 {
+				safety = fmt.Sprintf("%d (expected %d)", result.Safety, result.ExpectedSafety)
+			}
+			fmt.Fprintf(out, "| %s | %s | `%s` | %s | %s |\n", mark, markdownCell(result.Prompt),
+				markdownCell(result.Expected), markdownCell(got), safety)
+		}
+	}
+}
+
+func percentOf(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(n)*100/float64(total))
+}
+
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+func init() {
+	evalCmd.Flags().String("dataset", "", "JSONL file with evaluation cases")
+	evalCmd.Flags().StringSlice("model", nil, "Model to evaluate (repeat to compare models)")
+	evalCmd.Flags().String("format", "text", "Report format: text, json or markdown")
+	evalCmd.Flags().String("output", "", "Write the report to a file instead of stdout")
+	evalCmd.Flags().Float64("fail-under", 0, "Exit with an error if accuracy is below this ratio (0-1)")
+	evalCmd.Flags().BoolP("verbose", "v", false, "Print each case as it is evaluated")
+	rootCmd.AddCommand(evalCmd)
+}
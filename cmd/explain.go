@@ -1,13 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 	"text/tabwriter"
 
-	"oc-ai/internal/ai"
-
 	"github.com/spf13/cobra"
 )
 
@@ -25,7 +26,7 @@ Provides detailed information about command behavior, risks, and alternatives.`,
 			command = strings.TrimPrefix(command, activeTool+" ")
 		}
 
-		aiClient := ai.NewClient(cfg.OpenAIKey, activeTool, cmd.Flag("ai-model").Value.String())
+		aiClient := newAIClient(cmd)
 
 		// Get current context for more accurate explanation
 		ctx, err := cliClient.GetContext()
@@ -34,11 +35,6 @@ Provides detailed information about command behavior, risks, and alternatives.`,
 			ctx = make(map[string]string)
 		}
 
-		explanation, err := aiClient.ExplainCommand(command)
-		if err != nil {
-			return fmt.Errorf("failed to explain command: %w", err)
-		}
-
 		// Create formatted output
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 		fmt.Fprintf(w, "\nCommand Explanation: %s %s\n", activeTool, command)
@@ -48,8 +44,32 @@ Provides detailed information about command behavior, risks, and alternatives.`,
 		if ctx["namespace"] != "" {
 			fmt.Fprintf(w, "Namespace:\t%s\n", ctx["namespace"])
 		}
-		fmt.Fprintf(w, "\n%s\n", explanation)
+
+		if !cfg.StreamResponses {
+			explanation, err := aiClient.ExplainCommand(command)
+			if err != nil {
+				return fmt.Errorf("failed to explain command: %w", err)
+			}
+			fmt.Fprintf(w, "\n%s\n", explanation)
+			w.Flush()
+			return nil
+		}
+
+		// Stream the explanation as it arrives; Ctrl-C cancels the request
 		w.Flush()
+		fmt.Println()
+		apiCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if _, err := aiClient.ExplainCommandStream(apiCtx, command, os.Stdout); err != nil {
+			fmt.Println()
+			if errors.Is(err, context.Canceled) {
+				fmt.Println("Explanation cancelled")
+				return nil
+			}
+			return fmt.Errorf("failed to explain command: %w", err)
+		}
+		fmt.Println()
 
 		return nil
 	},
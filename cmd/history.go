@@ -1,22 +1,44 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"oc-ai/internal/ai"
+	"oc-ai/internal/cli"
+	"oc-ai/internal/fsutil"
+
 	"github.com/spf13/cobra"
 )
 
+// HistoryCommand stores the history as JSON lines that are only ever
+// appended to. A file lock shared between oc-ai processes guards every write,
+// and the file is compacted to history_limit entries as it grows.
 type HistoryCommand struct {
 	filePath string
 	mutex    sync.Mutex
 }
 
+const (
+	historyFile = "history.jsonl"
+	// legacyHistoryFile is the JSON array used before the JSONL store
+	legacyHistoryFile = "history.json"
+	// minEntryBytes is a lower bound on the size of a history line, so a
+	// file smaller than limit*minEntryBytes can't need compaction
+	minEntryBytes = 64
+)
+
 func NewHistoryCommand() (*HistoryCommand, error) {
 	configDir, err := os.UserConfigDir()
 	if err != nil {
@@ -29,111 +51,457 @@ func NewHistoryCommand() (*HistoryCommand, error) {
 	}
 
 	return &HistoryCommand{
-		filePath: filepath.Join(dirPath, "history.json"),
+		filePath: filepath.Join(dirPath, historyFile),
 		mutex:    sync.Mutex{},
 	}, nil
 }
 
-func (h *HistoryCommand) AddToHistory(command string) error {
+// Add appends an entry to the history, stamping the active tool
+func (h *HistoryCommand) Add(entry HistoryEntry) error {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
 	// Remove duplicate CLI tool name if present
-	if strings.HasPrefix(command, activeTool+" ") {
-		command = strings.TrimPrefix(command, activeTool+" ")
+	if strings.HasPrefix(entry.Command, activeTool+" ") {
+		entry.Command = strings.TrimPrefix(entry.Command, activeTool+" ")
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	entry.Tool = activeTool
+	entry.Version = historyVersion
+	if entry.ID == "" {
+		entry.ID = newHistoryID()
 	}
 
-	entries := h.loadHistory()
-	entries = append(entries, HistoryEntry{
-		Timestamp: time.Now(),
-		Command:   command,
-		Tool:      activeTool,
-	})
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %w", err)
+	}
 
-	// Enforce history limit
-	if cfg != nil && cfg.HistoryLimit > 0 && len(entries) > cfg.HistoryLimit {
-		entries = entries[len(entries)-cfg.HistoryLimit:]
+	lock, err := fsutil.Lock(h.filePath)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	if err := h.migrateLegacy(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(h.filePath, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
 	}
+	defer f.Close()
 
-	return h.saveHistory(entries)
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read history file: %w", err)
+	}
+	// A write cut short by a crash leaves a line without its newline; don't
+	// let the new entry join it
+	if size := info.Size(); size > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, size-1); err == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write history entry: %w", err)
+	}
+
+	limit := historyLimit()
+	if limit <= 0 || info.Size()+int64(len(line)) < int64(limit)*minEntryBytes {
+		return nil
+	}
+	entries, corrupt, err := readHistoryFile(h.filePath)
+	if err != nil {
+		return err
+	}
+	// Compact in batches so the file isn't rewritten on every append
+	if len(entries) > limit+compactionSlack(limit) || len(corrupt) > 0 {
+		return h.rewrite(entries, corrupt)
+	}
+	return nil
 }
 
+// loadHistory returns all entries, oldest first. Damaged lines are skipped
+// and moved to a .corrupt file so the remaining entries stay readable.
 func (h *HistoryCommand) loadHistory() []HistoryEntry {
-	data, err := os.ReadFile(h.filePath)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	lock, err := fsutil.Lock(h.filePath)
 	if err != nil {
-		if !os.IsNotExist(err) {
-			fmt.Printf("Warning: Failed to read history file: %v\n", err)
-		}
+		fmt.Printf("Warning: Failed to lock history file: %v\n", err)
+		return []HistoryEntry{}
+	}
+	defer lock.Unlock()
+	if err := h.migrateLegacy(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
 		return []HistoryEntry{}
 	}
 
-	var entries []HistoryEntry
-	if err := json.Unmarshal(data, &entries); err != nil {
-		fmt.Printf("Warning: Failed to parse history file: %v\n", err)
-		// Backup corrupted file
-		backupPath := h.filePath + ".bak"
-		if err := os.Rename(h.filePath, backupPath); err != nil {
-			fmt.Printf("Warning: Failed to backup corrupted history file: %v\n", err)
-		}
+	entries, corrupt, err := readHistoryFile(h.filePath)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
 		return []HistoryEntry{}
 	}
+	if len(corrupt) > 0 || (historyLimit() > 0 && len(entries) > historyLimit()) {
+		if err := h.rewrite(entries, corrupt); err != nil {
+			fmt.Printf("Warning: Failed to repair history file: %v\n", err)
+		}
+	}
+	if limit := historyLimit(); limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
 	return entries
 }
 
-func (h *HistoryCommand) saveHistory(entries []HistoryEntry) error {
-	data, err := json.MarshalIndent(entries, "", "  ")
+// readHistoryFile parses a JSONL history file, returning the valid entries
+// and the lines that couldn't be parsed
+func readHistoryFile(path string) ([]HistoryEntry, []string, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to marshal history entries: %w", err)
+		if os.IsNotExist(err) {
+			return []HistoryEntry{}, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	entries := []HistoryEntry{}
+	var corrupt []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Command == "" {
+			corrupt = append(corrupt, line)
+			continue
+		}
+		if entry.ID == "" {
+			entry.ID = derivedHistoryID(entry)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, corrupt, nil
+}
+
+// rewrite replaces the history file with the newest history_limit entries,
+// saving any damaged lines to a .corrupt file first. Callers hold the lock.
+func (h *HistoryCommand) rewrite(entries []HistoryEntry, corrupt []string) error {
+	if len(corrupt) > 0 {
+		fmt.Printf("Warning: Skipped %d damaged history lines (saved to %s)\n", len(corrupt), h.filePath+".corrupt")
+		f, err := os.OpenFile(h.filePath+".corrupt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to save damaged history lines: %w", err)
+		}
+		_, err = f.WriteString(strings.Join(corrupt, "\n") + "\n")
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to save damaged history lines: %w", err)
+		}
 	}
 
-	// Write to temporary file first
-	tempFile := h.filePath + ".tmp"
-	if err := os.WriteFile(tempFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write temporary history file: %w", err)
+	if limit := historyLimit(); limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal history entry: %w", err)
+		}
+		buf.Write(append(line, '\n'))
+	}
+	return fsutil.WriteFileAtomic(h.filePath, buf.Bytes(), 0644)
+}
+
+// migrateLegacy converts a history.json array into the JSONL store, keeping
+// the original as history.json.migrated. Callers hold the lock. A legacy
+// file that can't be migrated is left in place, and nothing is recorded
+// until it is fixed, so its entries aren't lost.
+func (h *HistoryCommand) migrateLegacy() error {
+	legacyPath := filepath.Join(filepath.Dir(h.filePath), legacyHistoryFile)
+	data, err := os.ReadFile(legacyPath)
+	if err != nil {
+		return nil
+	}
+	if _, err := os.Stat(h.filePath); err == nil {
+		// Already migrated by a process that couldn't rename the old file
+		return nil
 	}
 
-	// Rename temporary file to actual file (atomic operation)
-	if err := os.Rename(tempFile, h.filePath); err != nil {
-		os.Remove(tempFile) // Clean up temp file if rename fails
-		return fmt.Errorf("failed to save history file: %w", err)
+	var entries []HistoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse legacy history %s, fix or move it to migrate it: %w", legacyPath, err)
+	}
+	migrateHistory(entries)
+	if err := h.rewrite(entries, nil); err != nil {
+		return fmt.Errorf("failed to migrate history file: %w", err)
+	}
+	if err := os.Rename(legacyPath, legacyPath+".migrated"); err != nil {
+		fmt.Printf("Warning: Failed to rename migrated history file: %v\n", err)
 	}
 	return nil
 }
 
+// migrateHistory upgrades entries written by older versions in place.
+// Entries from before version 2 only have a timestamp, command and tool;
+// their source and outcome are unknown.
+func migrateHistory(entries []HistoryEntry) {
+	for i := range entries {
+		if entries[i].Version >= historyVersion {
+			continue
+		}
+		if entries[i].Source == "" {
+			entries[i].Source = SourceUnknown
+		}
+		entries[i].Version = historyVersion
+	}
+}
+
+func historyLimit() int {
+	if cfg == nil {
+		return 0
+	}
+	return cfg.HistoryLimit
+}
+
+// compactionSlack is how far the file may grow past the limit before it is
+// compacted again
+func compactionSlack(limit int) int {
+	if slack := limit / 4; slack > 10 {
+		return slack
+	}
+	return 10
+}
+
+// historyVersion is the current format of history entries
+const historyVersion = 2
+
+// Sources of history entries
+const (
+	SourceAI          = "ai"
+	SourceInteractive = "interactive"
+	SourceTemplate    = "template"
+	SourcePassthrough = "passthrough"
+	// SourceUnknown marks entries migrated from before sources were recorded
+	SourceUnknown = "unknown"
+)
+
+// maxOutputExcerpt bounds the output kept with each history entry
+const maxOutputExcerpt = 2048
+
 type HistoryEntry struct {
+	ID        string    `json:"id,omitempty"`
+	Version   int       `json:"version,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
 	Command   string    `json:"command"`
 	Tool      string    `json:"tool"`
+	Source    string    `json:"source,omitempty"`
+
+	// Prompt, Explanation and Safety describe how the command was generated
+	Prompt      string `json:"prompt,omitempty"`
+	Explanation string `json:"explanation,omitempty"`
+	Safety      int    `json:"safety,omitempty"`
+	Template    string `json:"template,omitempty"`
+
+	Context   string `json:"context,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+
+	// ExitStatus is nil for entries whose outcome wasn't recorded
+	ExitStatus *int          `json:"exit_status,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration,omitempty"`
+	Output     *OutputDigest `json:"output,omitempty"`
+
+	// PlanID and Step are set for commands run as part of a plan, or of a
+	// runbook run (with Template set)
+	PlanID string `json:"plan_id,omitempty"`
+	Step   int    `json:"step,omitempty"`
+	// RerunOf is the id of the entry a rerun command was taken from
+	RerunOf string `json:"rerun_of,omitempty"`
+	// Declined marks generated commands the user chose not to run, and
+	// Revised commands the user edited before running them
+	Declined bool `json:"declined,omitempty"`
+	Revised  bool `json:"revised,omitempty"`
+	// Untrusted marks commands generated from cluster data, which are only
+	// run again after typing "yes"
+	Untrusted bool `json:"untrusted,omitempty"`
+	// Masked commands have sensitive values replaced, so they can't be run
+	// again as recorded
+	Masked bool `json:"masked,omitempty"`
 }
 
-func init() {
-	historyCmd := &cobra.Command{
-		Use:   "history",
-		Short: "Show command history",
-		Run: func(cmd *cobra.Command, args []string) {
-			hc, err := NewHistoryCommand()
-			if err != nil {
-				fmt.Printf("Error initializing history: %v\n", err)
-				return
-			}
-
-			entries := hc.loadHistory()
-			if len(entries) == 0 {
-				fmt.Println("No command history found.")
-				return
-			}
-
-			fmt.Println("Command History:")
-			for i, entry := range entries {
-				// Format command with tool name
-				fmt.Printf("%d. [%s] %s %s\n",
-					i+1,
-					entry.Timestamp.Format("2006-01-02 15:04:05"),
-					entry.Tool,
-					entry.Command)
-			}
-		},
+// newHistoryID returns a short random id for a new entry
+func newHistoryID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// derivedHistoryID gives entries written before ids existed a stable id
+func derivedHistoryID(e HistoryEntry) string {
+	sum := sha256.Sum256([]byte(e.Timestamp.Format(time.RFC3339Nano) + "\x00" + e.Command))
+	return hex.EncodeToString(sum[:4])
+}
+
+// OutputDigest identifies the output of a command without storing all of it
+type OutputDigest struct {
+	SHA256  string `json:"sha256"`
+	Bytes   int    `json:"bytes"`
+	Lines   int    `json:"lines"`
+	Excerpt string `json:"excerpt,omitempty"`
+}
+
+// newHistoryEntry starts an entry for a command about to run in the given
+// kube context. The namespace is the one the command targets.
+func newHistoryEntry(source, command string, ctx map[string]string) HistoryEntry {
+	command = strings.TrimPrefix(command, activeTool+" ")
+	entry := HistoryEntry{
+		Timestamp: time.Now(),
+		Command:   command,
+		Source:    source,
+		Context:   ctx["context"],
+		Namespace: ctx["namespace"],
+	}
+	if ns := ai.CommandNamespace(cli.ParseCommand(command)); ns != "" {
+		entry.Namespace = ns
+	}
+	return entry
+}
+
+// SetOutcome records the result of executing the entry's command
+func (e *HistoryEntry) SetOutcome(output string, err error) {
+	e.Duration = time.Since(e.Timestamp).Round(time.Millisecond)
+	status := exitStatus(err)
+	e.ExitStatus = &status
+	e.Error = ""
+	if err != nil {
+		e.Error = err.Error()
 	}
+	e.Output = digestOutput(output)
+}
 
+// Succeeded reports whether the command is known to have exited with 0
+func (e HistoryEntry) Succeeded() bool {
+	return e.ExitStatus != nil && *e.ExitStatus == 0
+}
+
+// exitStatus returns the exit code of a command, or -1 if it didn't run
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+func digestOutput(output string) *OutputDigest {
+	if output == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(output))
+	digest := &OutputDigest{
+		SHA256: hex.EncodeToString(sum[:]),
+		Bytes:  len(output),
+		Lines:  strings.Count(strings.TrimRight(output, "\n"), "\n") + 1,
+	}
+
+	excerpt := output
+	if len(excerpt) > maxOutputExcerpt {
+		excerpt = strings.ToValidUTF8(excerpt[:maxOutputExcerpt], "") + "\n... (truncated)"
+	}
+	// Secrets in the output shouldn't end up in the history file
+	digest.Excerpt = redactOutput(excerpt)
+	return digest
+}
+
+// redactOutput masks secrets in command output written to disk, unless
+// redaction is disabled
+func redactOutput(output string) string {
+	if cfg != nil && cfg.RedactEnabled {
+		if redactor, err := ai.NewRedactor(cfg.RedactPatterns, false); err == nil {
+			return redactor.Redact(output)
+		}
+	}
+	return output
+}
+
+// recordDeclined records a generated command the user chose not to run
+func recordDeclined(entry HistoryEntry) {
+	entry.Declined = true
+	recordHistory(entry)
+}
+
+// recordHistory adds an entry to the history, warning if it can't be saved
+func recordHistory(entry HistoryEntry) {
+	if hc := findHistoryCommand(); hc != nil {
+		if err := hc.Add(entry); err != nil {
+			fmt.Printf("Warning: Failed to save command to history: %v\n", err)
+		}
+	}
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show command history",
+	Example: `  oc-ai history
+  oc-ai history --failed --since 24h
+  oc-ai history --context prod --namespace payments`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := historyFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		hc, err := openHistory()
+		if err != nil {
+			return err
+		}
+		entries := filter.apply(hc.loadHistory())
+		if len(entries) == 0 {
+			fmt.Println("No command history found.")
+			return nil
+		}
+
+		fmt.Println("Command History:")
+		for _, entry := range entries {
+			printHistoryLine(entry)
+		}
+		return nil
+	},
+}
+
+// printHistoryLine prints an entry as a single line, prefixed with its id
+func printHistoryLine(entry HistoryEntry) {
+	// Format command with tool name
+	fmt.Printf("%s [%s] %s %s",
+		entry.ID,
+		entry.Timestamp.Local().Format("2006-01-02 15:04:05"),
+		entry.Tool,
+		entry.Command)
+	if entry.ExitStatus != nil && !entry.Succeeded() {
+		fmt.Printf("  (failed, exit status %d)", *entry.ExitStatus)
+	}
+	if entry.Declined {
+		fmt.Print("  (declined)")
+	}
+	switch {
+	case entry.PlanID != "" && entry.Template != "":
+		fmt.Printf("  (runbook run %s, step %d)", entry.PlanID, entry.Step)
+	case entry.PlanID != "":
+		fmt.Printf("  (plan %s, step %d)", entry.PlanID, entry.Step)
+	}
+	fmt.Println()
+}
+
+func init() {
+	addHistoryFilterFlags(historyCmd)
 	rootCmd.AddCommand(historyCmd)
 }
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"oc-ai/internal/ai"
+	"oc-ai/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+var historyExportCmd = &cobra.Command{
+	Use:   "export [id...]",
+	Short: "Export history entries as a runbook, shell script or JSON",
+	Long: `Export history entries, selected by id or with the history filter flags, as a
+markdown runbook with prompts, explanations, commands and outputs, a shell script for
+review, or JSON. Exported commands have the CLI tool, kube context and namespace they
+ran with pinned, so they target the same cluster wherever they are run.`,
+	Example: `  oc-ai history export --since 2h --format markdown > incident.md
+  oc-ai history export --since 2h --context prod --format sh --output replay.sh
+  oc-ai history export 3f9c2a1b 8349ff38 --format json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		if format != "markdown" && format != "sh" && format != "json" {
+			return fmt.Errorf("invalid format %q: must be markdown, sh or json", format)
+		}
+
+		filter, err := historyFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		hc, err := openHistory()
+		if err != nil {
+			return err
+		}
+
+		// Declined commands never ran, so they don't belong in a runbook
+		var entries []HistoryEntry
+		for _, e := range filter.apply(hc.loadHistory()) {
+			if !e.Declined {
+				entries = append(entries, e)
+			}
+		}
+		if len(args) > 0 {
+			selected := make(map[string]bool)
+			for _, id := range args {
+				entry, err := findHistoryEntry(entries, id)
+				if err != nil {
+					return err
+				}
+				selected[entry.ID] = true
+			}
+			var kept []HistoryEntry
+			for _, e := range entries {
+				if selected[e.ID] {
+					kept = append(kept, e)
+				}
+			}
+			entries = kept
+		}
+		if len(entries) == 0 {
+			return fmt.Errorf("no history entries to export")
+		}
+
+		out := io.Writer(os.Stdout)
+		if output != "" {
+			file, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("failed to create export file: %w", err)
+			}
+			defer file.Close()
+			out = file
+		}
+
+		switch format {
+		case "json":
+			data, err := json.MarshalIndent(entries, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal history entries: %w", err)
+			}
+			fmt.Fprintln(out, string(data))
+		case "sh":
+			writeHistoryScript(out, entries)
+		default:
+			writeHistoryRunbook(out, entries)
+		}
+
+		if output != "" {
+			fmt.Printf("Exported %d entries to %s\n", len(entries), output)
+		}
+		return nil
+	},
+}
+
+// pinnedCommand renders an entry as a full command line with the tool, kube
+// context and namespace it ran with
+func pinnedCommand(e HistoryEntry) string {
+	tool := e.Tool
+	if tool == "" {
+		tool = activeTool
+	}
+	args := cli.ParseCommand(e.Command)
+
+	line := []string{tool}
+	if e.Context != "" && !hasFlag(args, "--context") {
+		line = append(line, "--context", e.Context)
+	}
+	if e.Namespace != "" && ai.CommandNamespace(args) == "" && !hasFlag(args, "-A", "--all-namespaces") {
+		line = append(line, "-n", e.Namespace)
+	}
+	return cli.ShellJoin(append(line, args...))
+}
+
+// hasFlag reports whether any of the flags is set, with or without a value
+func hasFlag(args []string, flags ...string) bool {
+	for _, arg := range args {
+		for _, flag := range flags {
+			if arg == flag || strings.HasPrefix(arg, flag+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func entryResult(e HistoryEntry) string {
+	switch {
+	case e.ExitStatus == nil:
+		return "not recorded"
+	case e.Succeeded():
+		return "succeeded"
+	}
+	return fmt.Sprintf("failed (exit status %d)", *e.ExitStatus)
+}
+
+// singleLine makes text safe for a comment or heading
+func singleLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func writeHistoryRunbook(out io.Writer, entries []HistoryEntry) {
+	first, last := entries[0].Timestamp.Local(), entries[len(entries)-1].Timestamp.Local()
+	fmt.Fprintln(out, "# Runbook")
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "%d commands run between %s and %s, exported from oc-ai history on %s.\n",
+		len(entries), first.Format("2006-01-02 15:04"), last.Format("2006-01-02 15:04"),
+		time.Now().Format("2006-01-02 15:04"))
+
+	for i, e := range entries {
+		title := e.Prompt
+		if title == "" {
+			title = "Run `" + e.Command + "`"
+		}
+		fmt.Fprintf(out, "\n## %d. %s\n\n", i+1, singleLine(title))
+		fmt.Fprintf(out, "- **Time:** %s\n", e.Timestamp.Local().Format("2006-01-02 15:04:05"))
+		if e.Context != "" {
+			fmt.Fprintf(out, "- **Context:** %s, namespace %s\n", e.Context, e.Namespace)
+		}
+		fmt.Fprintf(out, "- **Source:** %s\n", e.Source)
+		if e.Safety > 0 {
+			fmt.Fprintf(out, "- **Safety:** %d/5\n", e.Safety)
+		}
+		fmt.Fprintf(out, "- **Result:** %s\n", entryResult(e))
+		if e.Explanation != "" {
+			fmt.Fprintf(out, "\n%s\n", e.Explanation)
+		}
+
+		fmt.Fprintln(out)
+		writeFenced(out, "sh", pinnedCommand(e))
+		if e.Output != nil && e.Output.Excerpt != "" {
+			fmt.Fprintln(out, "\nOutput:")
+			fmt.Fprintln(out)
+			writeFenced(out, "", strings.TrimRight(e.Output.Excerpt, "\n"))
+		}
+	}
+}
+
+// writeFenced writes a code block whose fence can't be closed by its content
+func writeFenced(out io.Writer, lang, content string) {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(out, "%s%s\n%s\n%s\n", fence, lang, content, fence)
+}
+
+func writeHistoryScript(out io.Writer, entries []HistoryEntry) {
+	fmt.Fprintln(out, "#!/bin/sh")
+	fmt.Fprintf(out, "# Commands exported from oc-ai history on %s.\n", time.Now().Format("2006-01-02 15:04"))
+	fmt.Fprintln(out, "# Review every command before running this script: the cluster may have changed.")
+	fmt.Fprintln(out, "# Commands that failed originally, or whose sensitive values were masked, are commented out.")
+	fmt.Fprintln(out, "set -eu")
+
+	for i, e := range entries {
+		fmt.Fprintf(out, "\n# [%d] %s (%s)", i+1, e.Timestamp.Local().Format("2006-01-02 15:04:05"), e.Source)
+		if e.Prompt != "" {
+			fmt.Fprintf(out, ": %s", singleLine(e.Prompt))
+		}
+		fmt.Fprintln(out)
+		if e.Explanation != "" {
+			fmt.Fprintf(out, "# %s\n", singleLine(e.Explanation))
+		}
+		if e.Safety >= 3 {
+			fmt.Fprintf(out, "# CAUTION: safety level %d/5\n", e.Safety)
+		}
+
+		command := pinnedCommand(e)
+		if e.Masked {
+			fmt.Fprintf(out, "# sensitive values masked, fill them in: %s\n", command)
+			continue
+		}
+		if e.ExitStatus != nil && !e.Succeeded() {
+			fmt.Fprintf(out, "# %s: %s\n", entryResult(e), command)
+			continue
+		}
+		fmt.Fprintln(out, command)
+	}
+}
+
+func init() {
+	addHistoryFilterFlags(historyExportCmd)
+	historyExportCmd.Flags().String("format", "markdown", "Export format: markdown, sh or json")
+	historyExportCmd.Flags().String("output", "", "Write the export to a file instead of stdout")
+	historyCmd.AddCommand(historyExportCmd)
+}
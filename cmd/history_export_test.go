@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPinnedCommand(t *testing.T) {
+	testHistory(t, 0)
+	tests := []struct {
+		name  string
+		entry HistoryEntry
+		want  string
+	}{
+		{"context and namespace", HistoryEntry{Command: "get pods", Tool: "oc", Context: "dev/api", Namespace: "web"},
+			"oc --context dev/api -n web get pods"},
+		{"tool of the entry", HistoryEntry{Command: "get pods", Tool: "kubectl", Namespace: "web"}, "kubectl -n web get pods"},
+		{"active tool", HistoryEntry{Command: "get nodes"}, "oc get nodes"},
+		{"namespace flag", HistoryEntry{Command: "get pods -n shop", Tool: "oc", Namespace: "shop"}, "oc get pods -n shop"},
+		{"all namespaces", HistoryEntry{Command: "get pods -A", Tool: "oc", Namespace: "web"}, "oc get pods -A"},
+		{"all namespaces with a value", HistoryEntry{Command: "get pods --all-namespaces=true", Tool: "oc", Namespace: "web"},
+			"oc get pods --all-namespaces=true"},
+		{"context flag", HistoryEntry{Command: "get pods --context=prod", Tool: "oc", Context: "dev/api"}, "oc get pods --context=prod"},
+		{"quoted arguments", HistoryEntry{Command: `get pods -l 'app in (web, api)'`, Tool: "oc", Namespace: "web"},
+			"oc -n web get pods -l 'app in (web, api)'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pinnedCommand(tt.entry); got != tt.want {
+				t.Errorf("pinnedCommand() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFenced(t *testing.T) {
+	tests := []struct {
+		name    string
+		lang    string
+		content string
+		want    string
+	}{
+		{"plain", "sh", "oc get pods", "```sh\noc get pods\n```\n"},
+		{"no language", "", "NAME   READY", "```\nNAME   READY\n```\n"},
+		{"fence in the content", "", "```\nrm -rf /\n```", "````\n```\nrm -rf /\n```\n````\n"},
+		{"longer fence in the content", "", "a ```` b", "`````\na ```` b\n`````\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			writeFenced(&out, tt.lang, tt.content)
+			if out.String() != tt.want {
+				t.Errorf("writeFenced() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"oc-ai/internal/ai"
+	"oc-ai/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+// historyFilter selects history entries by where and how they ran
+type historyFilter struct {
+	tool      string
+	source    string
+	context   string
+	namespace string
+	since     time.Time
+	until     time.Time
+	failed    bool
+	succeeded bool
+	limit     int
+}
+
+// addHistoryFilterFlags adds the filter flags shared by the history commands.
+// The global --context and --namespace flags filter by kube context and
+// namespace.
+func addHistoryFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().String("tool", "", "Only entries run with this CLI tool (oc or kubectl)")
+	cmd.Flags().String("source", "", "Only entries from this source (ai, interactive, template or passthrough)")
+	cmd.Flags().String("since", "", "Only entries after a time (e.g. 2h, 3d, 2024-05-01)")
+	cmd.Flags().String("until", "", "Only entries before a time (e.g. 1h, 2024-05-02T12:00:00Z)")
+	cmd.Flags().Bool("failed", false, "Only commands that failed")
+	cmd.Flags().Bool("succeeded", false, "Only commands that succeeded")
+	cmd.Flags().Int("limit", 0, "Show at most this many entries (0 for all)")
+}
+
+func historyFilterFromFlags(cmd *cobra.Command) (*historyFilter, error) {
+	f := &historyFilter{}
+	f.tool, _ = cmd.Flags().GetString("tool")
+	f.source, _ = cmd.Flags().GetString("source")
+	f.context, _ = cmd.Flags().GetString("context")
+	f.namespace, _ = cmd.Flags().GetString("namespace")
+	f.failed, _ = cmd.Flags().GetBool("failed")
+	f.succeeded, _ = cmd.Flags().GetBool("succeeded")
+	f.limit, _ = cmd.Flags().GetInt("limit")
+	if f.failed && f.succeeded {
+		return nil, fmt.Errorf("--failed and --succeeded can't be combined")
+	}
+
+	now := time.Now()
+	for _, bound := range []struct {
+		flag string
+		dest *time.Time
+	}{{"since", &f.since}, {"until", &f.until}} {
+		value, _ := cmd.Flags().GetString(bound.flag)
+		if value == "" {
+			continue
+		}
+		t, err := parseHistoryTime(value, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", bound.flag, err)
+		}
+		*bound.dest = t
+	}
+	return f, nil
+}
+
+func (f *historyFilter) matches(e HistoryEntry) bool {
+	switch {
+	case f.tool != "" && e.Tool != f.tool,
+		f.source != "" && e.Source != f.source,
+		f.context != "" && e.Context != f.context,
+		f.namespace != "" && e.Namespace != f.namespace,
+		!f.since.IsZero() && e.Timestamp.Before(f.since),
+		!f.until.IsZero() && e.Timestamp.After(f.until),
+		f.failed && (e.ExitStatus == nil || e.Succeeded()),
+		f.succeeded && !e.Succeeded():
+		return false
+	}
+	return true
+}
+
+// apply returns the matching entries, keeping the newest if limited
+func (f *historyFilter) apply(entries []HistoryEntry) []HistoryEntry {
+	var matched []HistoryEntry
+	for _, e := range entries {
+		if f.matches(e) {
+			matched = append(matched, e)
+		}
+	}
+	if f.limit > 0 && len(matched) > f.limit {
+		matched = matched[len(matched)-f.limit:]
+	}
+	return matched
+}
+
+// parseHistoryTime accepts a duration before now (2h, 3d, 1w) or a date
+// or timestamp
+func parseHistoryTime(value string, now time.Time) (time.Time, error) {
+	if n, err := strconv.Atoi(strings.TrimRight(value, "dw")); err == nil && len(value) > 1 {
+		switch value[len(value)-1] {
+		case 'd':
+			return now.AddDate(0, 0, -n), nil
+		case 'w':
+			return now.AddDate(0, 0, -7*n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is neither a duration (2h, 3d) nor a date (2006-01-02)", value)
+}
+
+// fuzzyScore rates how well every word of the query matches text. Words
+// found as substrings score highest, especially at the start of a word;
+// otherwise the word's letters must appear in order. Zero means no match.
+func fuzzyScore(query, text string) int {
+	text = strings.ToLower(text)
+	score := 0
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if i := strings.Index(text, word); i >= 0 {
+			score += 100 + 2*len(word)
+			if i == 0 || !isWordRune(rune(text[i-1])) {
+				score += 20
+			}
+			continue
+		}
+
+		// Letters in order, rewarding runs of adjacent letters
+		pos, last, matched := 0, -2, 0
+		for _, ch := range word {
+			i := strings.IndexRune(text[pos:], ch)
+			if i < 0 {
+				return 0
+			}
+			if pos+i == last+1 {
+				matched += 5
+			} else {
+				matched++
+			}
+			last = pos + i
+			pos = last + len(string(ch))
+		}
+		score += matched
+	}
+	return score
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// findHistoryEntry looks up an entry by id or unique id prefix
+func findHistoryEntry(entries []HistoryEntry, id string) (HistoryEntry, error) {
+	var found []HistoryEntry
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil
+		}
+		if strings.HasPrefix(e.ID, id) {
+			found = append(found, e)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return HistoryEntry{}, fmt.Errorf("history entry %s not found", id)
+	case 1:
+		return found[0], nil
+	}
+	return HistoryEntry{}, fmt.Errorf("history id %s is ambiguous (%d entries match)", id, len(found))
+}
+
+// openHistory returns the shared history store
+func openHistory() (*HistoryCommand, error) {
+	if hc := findHistoryCommand(); hc != nil {
+		return hc, nil
+	}
+	hc, err := NewHistoryCommand()
+	if err != nil {
+		return nil, fmt.Errorf("error initializing history: %w", err)
+	}
+	return hc, nil
+}
+
+var historySearchCmd = &cobra.Command{
+	Use:   "search [text]",
+	Short: "Fuzzy search prompts and commands in the history",
+	Example: `  oc-ai history search scale api
+  oc-ai history search logs --context prod --failed`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := strings.Join(args, " ")
+		filter, err := historyFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		limit := filter.limit
+		filter.limit = 0
+
+		hc, err := openHistory()
+		if err != nil {
+			return err
+		}
+
+		type match struct {
+			entry HistoryEntry
+			score int
+		}
+		var matches []match
+		for _, e := range filter.apply(hc.loadHistory()) {
+			if score := fuzzyScore(query, e.Prompt+" "+e.Command); score > 0 {
+				matches = append(matches, match{e, score})
+			}
+		}
+		if len(matches) == 0 {
+			fmt.Println("No matching history entries.")
+			return nil
+		}
+
+		// Best matches first, newer entries breaking ties
+		sort.SliceStable(matches, func(i, j int) bool {
+			if matches[i].score != matches[j].score {
+				return matches[i].score > matches[j].score
+			}
+			return matches[i].entry.Timestamp.After(matches[j].entry.Timestamp)
+		})
+		if limit > 0 && len(matches) > limit {
+			matches = matches[:limit]
+		}
+
+		for _, m := range matches {
+			printHistoryLine(m.entry)
+			if m.entry.Prompt != "" {
+				fmt.Printf("    prompt: %s\n", m.entry.Prompt)
+			}
+		}
+		return nil
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show [id]",
+	Short: "Show the full record of a history entry",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hc, err := openHistory()
+		if err != nil {
+			return err
+		}
+		entry, err := findHistoryEntry(hc.loadHistory(), args[0])
+		if err != nil {
+			return err
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			data, err := json.MarshalIndent(entry, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal history entry: %w", err)
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		fmt.Printf("ID:          %s\n", entry.ID)
+		fmt.Printf("Time:        %s\n", entry.Timestamp.Local().Format("2006-01-02 15:04:05"))
+		fmt.Printf("Command:     %s %s\n", entry.Tool, entry.Command)
+		fmt.Printf("Source:      %s\n", entry.Source)
+		if entry.Template != "" {
+			fmt.Printf("Template:    %s\n", entry.Template)
+		}
+		if entry.Prompt != "" {
+			fmt.Printf("Prompt:      %s\n", entry.Prompt)
+		}
+		if entry.Explanation != "" {
+			fmt.Printf("Explanation: %s\n", entry.Explanation)
+		}
+		if entry.Safety > 0 {
+			fmt.Printf("Safety:      %d/5\n", entry.Safety)
+		}
+		if entry.Context != "" || entry.Namespace != "" {
+			fmt.Printf("Context:     %s (namespace %s)\n", entry.Context, entry.Namespace)
+		}
+		switch {
+		case entry.PlanID != "" && entry.Template != "":
+			fmt.Printf("Runbook run: %s, step %d\n", entry.PlanID, entry.Step)
+		case entry.PlanID != "":
+			fmt.Printf("Plan:        %s, step %d\n", entry.PlanID, entry.Step)
+		}
+		if entry.RerunOf != "" {
+			fmt.Printf("Rerun of:    %s\n", entry.RerunOf)
+		}
+		if entry.ExitStatus != nil {
+			fmt.Printf("Exit status: %d (took %s)\n", *entry.ExitStatus, entry.Duration)
+		}
+		if entry.Error != "" {
+			fmt.Printf("Error:       %s\n", entry.Error)
+		}
+		if out := entry.Output; out != nil {
+			fmt.Printf("Output:      %d bytes, %d lines, sha256 %s\n", out.Bytes, out.Lines, out.SHA256[:12])
+			fmt.Println(out.Excerpt)
+		}
+		return nil
+	},
+}
+
+var historyRerunCmd = &cobra.Command{
+	Use:   "rerun [id]",
+	Short: "Run a command from the history again after re-checking it",
+	Long: `Run a command from the history again. The command is checked again before it
+runs: its safety level is re-assessed with the current rules, its resource types are
+validated against the current cluster, and it is confirmed if the kube context or
+namespace differ from the original run, or if it was declined when generated.
+Commands generated from untrusted cluster data require typing "yes", even with --yes.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hc, err := openHistory()
+		if err != nil {
+			return err
+		}
+		entry, err := findHistoryEntry(hc.loadHistory(), args[0])
+		if err != nil {
+			return err
+		}
+
+		if entry.Masked {
+			return fmt.Errorf("entry %s has sensitive values masked, run template %s again instead", entry.ID, entry.Template)
+		}
+
+		ctx, err := cliClient.GetContext()
+		if err != nil {
+			return fmt.Errorf("failed to get cluster context: %w", err)
+		}
+		command := entry.Command
+
+		// The safety rules may have changed since the command first ran
+		level := ai.AssessSafety(command)
+		if entry.Safety > level {
+			level = entry.Safety
+		}
+		if catalog := loadResourceCatalog(ctx); catalog != nil {
+			if err := catalog.ValidateCommand(command); err != nil {
+				return fmt.Errorf("command is not valid on the current cluster: %w", err)
+			}
+		}
+
+		fmt.Printf("Command: %s %s\n", activeTool, command)
+		if entry.Explanation != "" {
+			fmt.Printf("Explanation: %s\n", entry.Explanation)
+		}
+		fmt.Printf("Safety Level: %d/5\n", level)
+
+		// Warnings that always require confirmation, even with --yes
+		var drift []string
+		if entry.Tool != "" && entry.Tool != activeTool {
+			drift = append(drift, fmt.Sprintf("originally run with %s, now using %s", entry.Tool, activeTool))
+		}
+		if entry.Context != "" && entry.Context != ctx["context"] {
+			drift = append(drift, fmt.Sprintf("originally run in context %s, current context is %s", entry.Context, ctx["context"]))
+		}
+		if ns := ai.CommandNamespace(cli.ParseCommand(command)); ns == "" && entry.Namespace != "" && entry.Namespace != ctx["namespace"] {
+			drift = append(drift, fmt.Sprintf("originally run in namespace %s, current namespace is %s", entry.Namespace, ctx["namespace"]))
+		}
+		for _, d := range drift {
+			fmt.Printf("⚠️ Warning: %s\n", d)
+		}
+
+		if entry.Declined {
+			fmt.Println("⚠️ Warning: This command was declined when it was generated")
+		}
+
+		autoConfirm := cmd.Flag("yes").Value.String() == "true"
+		switch {
+		case entry.Untrusted:
+			// As when it was generated, even with --yes
+			if level >= 3 {
+				fmt.Printf("⚠️ Warning: This command may be destructive (Safety Level: %d/5)\n", level)
+			}
+			fmt.Println("⚠️ Warning: This command was generated from untrusted cluster data")
+			if !confirm("Type 'yes' to execute: ", "yes") {
+				fmt.Println("Command cancelled")
+				return nil
+			}
+		case entry.Declined || len(drift) > 0 || (!autoConfirm && (cfg.ConfirmExecute || level >= 3)):
+			if level >= 3 {
+				fmt.Printf("⚠️ Warning: This command may be destructive (Safety Level: %d/5)\n", level)
+			}
+			if !confirm("Confirm execution? [y/N]: ", "y") {
+				fmt.Println("Command cancelled")
+				return nil
+			}
+		}
+
+		if cmd.Flag("dry-run").Value.String() == "true" {
+			fmt.Println("Dry run - command not executed")
+			return nil
+		}
+
+		rerun := newHistoryEntry(entry.Source, command, ctx)
+		rerun.Prompt, rerun.Explanation, rerun.Safety = entry.Prompt, entry.Explanation, level
+		rerun.Template, rerun.RerunOf = entry.Template, entry.ID
+		rerun.Untrusted = entry.Untrusted
+		output, err := cliClient.Execute(command)
+		rerun.SetOutcome(output, err)
+		recordHistory(rerun)
+		if err != nil {
+			return fmt.Errorf("error executing command: %v\nOutput: %s", err, output)
+		}
+
+		if output != "" {
+			fmt.Println("Command output:")
+			fmt.Println(output)
+		}
+		return nil
+	},
+}
+
+func init() {
+	addHistoryFilterFlags(historySearchCmd)
+	historyShowCmd.Flags().Bool("json", false, "Print the raw JSON record")
+	historyCmd.AddCommand(historySearchCmd, historyShowCmd, historyRerunCmd)
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		query string
+		text  string
+		want  int
+	}{
+		{"scale", "scale deployment web", 100 + 10 + 20},
+		{"SCALE", "Scale deployment web", 100 + 10 + 20},
+		{"web", "scale deployment/web", 100 + 6 + 20},
+		{"ale", "scale deployment web", 100 + 6},
+		{"scale web", "scale deployment web", 130 + 126},
+		// Letters in order: one point each, five for a letter right after the last one
+		{"sdw", "scale deployment web", 3},
+		{"scl", "scale deployment web", 1 + 5 + 1},
+		{"scale api", "scale deployment web", 0},
+		{"xyz", "scale deployment web", 0},
+		{"", "scale deployment web", 0},
+	}
+	for _, tt := range tests {
+		if got := fuzzyScore(tt.query, tt.text); got != tt.want {
+			t.Errorf("fuzzyScore(%q, %q) = %d, want %d", tt.query, tt.text, got, tt.want)
+		}
+	}
+
+	// Whole words rank above parts of words, which rank above scattered letters
+	text := "logs deployment/api --tail=100"
+	if word, part, letters := fuzzyScore("api", text), fuzzyScore("ploy", text), fuzzyScore("lgs", text); !(word > part && part > letters && letters > 0) {
+		t.Errorf("scores: word %d, part %d, letters %d", word, part, letters)
+	}
+}
+
+func TestParseHistoryTime(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		value string
+		want  time.Time
+		err   bool
+	}{
+		{value: "2h", want: now.Add(-2 * time.Hour)},
+		{value: "90m", want: now.Add(-90 * time.Minute)},
+		{value: "1.5h", want: now.Add(-90 * time.Minute)},
+		{value: "3d", want: time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local)},
+		{value: "2w", want: time.Date(2024, 2, 25, 12, 0, 0, 0, time.Local)},
+		{value: "2024-03-01", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)},
+		{value: "2024-03-01 08:30", want: time.Date(2024, 3, 1, 8, 30, 0, 0, time.Local)},
+		{value: "2024-03-01T08:30:00Z", want: time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)},
+		{value: "10", err: true},
+		{value: "d", err: true},
+		{value: "yesterday", err: true},
+		{value: "2024-13-01", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseHistoryTime(tt.value, now)
+		if (err != nil) != tt.err {
+			t.Errorf("parseHistoryTime(%q) error = %v, want error %v", tt.value, err, tt.err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseHistoryTime(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFindHistoryEntry(t *testing.T) {
+	entries := []HistoryEntry{
+		{ID: "a1b2c3d4", Command: "get pods"},
+		{ID: "a1b2ffff", Command: "get nodes"},
+		{ID: "a1b2", Command: "get routes"},
+		{ID: "9f8e7d6c", Command: "get events"},
+	}
+	tests := []struct {
+		id      string
+		command string
+		err     string
+	}{
+		{id: "9f8e7d6c", command: "get events"},
+		{id: "9f", command: "get events"},
+		{id: "a1b2c", command: "get pods"},
+		// An exact id wins over the longer ids it is a prefix of
+		{id: "a1b2", command: "get routes"},
+		{id: "a1", err: "history id a1 is ambiguous (3 entries match)"},
+		{id: "ffff", err: "history entry ffff not found"},
+	}
+	for _, tt := range tests {
+		entry, err := findHistoryEntry(entries, tt.id)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("findHistoryEntry(%s) error = %v, want %s", tt.id, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || entry.Command != tt.command {
+			t.Errorf("findHistoryEntry(%s) = %q, %v, want %q", tt.id, entry.Command, err, tt.command)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"oc-ai/internal/ai"
+	"oc-ai/internal/cli"
+	"oc-ai/internal/templates"
+
+	"github.com/spf13/cobra"
+)
+
+// minTemplateRepeats is how often a prompt must recur to suggest a template
+const minTemplateRepeats = 3
+
+// countedItem is a command or prompt with the number of times it was used
+type countedItem struct {
+	Text  string
+	Count int
+	Last  time.Time
+}
+
+// counter counts items by a normalized key, remembering the latest form
+type counter map[string]*countedItem
+
+func (c counter) add(key, text string, at time.Time) {
+	item, ok := c[key]
+	if !ok {
+		item = &countedItem{}
+		c[key] = item
+	}
+	item.Count++
+	if !at.Before(item.Last) {
+		item.Text, item.Last = text, at
+	}
+}
+
+// top returns the n most used items, most recent first on ties
+func (c counter) top(n int) []countedItem {
+	items := make([]countedItem, 0, len(c))
+	for _, item := range c {
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Last.After(items[j].Last)
+	})
+	if n > 0 && len(items) > n {
+		items = items[:n]
+	}
+	return items
+}
+
+// outcomeStats counts how often commands ran and failed
+type outcomeStats struct {
+	Runs     int
+	Failures int
+	Last     time.Time
+	// Namespaces counts runs per namespace
+	Namespaces map[string]int
+}
+
+func (o *outcomeStats) add(e HistoryEntry) {
+	o.Runs++
+	if e.ExitStatus != nil && !e.Succeeded() {
+		o.Failures++
+	}
+	if e.Timestamp.After(o.Last) {
+		o.Last = e.Timestamp
+	}
+	if e.Namespace != "" {
+		if o.Namespaces == nil {
+			o.Namespaces = make(map[string]int)
+		}
+		o.Namespaces[e.Namespace]++
+	}
+}
+
+func (o *outcomeStats) failureRate() string {
+	return percentOf(o.Failures, o.Runs)
+}
+
+// historyStats summarizes the history store
+type historyStats struct {
+	Executed int
+	Failed   int
+	// Generated commands from ai and interactive mode, by what the user did
+	Generated int
+	Accepted  int
+	Revised   int
+	Declined  int
+
+	Commands  counter
+	Prompts   counter
+	Verbs     map[string]*outcomeStats
+	Contexts  map[string]*outcomeStats
+	templates map[string][]HistoryEntry
+}
+
+// promptKey groups prompts that differ only in case, spacing or numbers
+var promptNumbers = regexp.MustCompile(`\d+`)
+
+func promptKey(prompt string) string {
+	return promptNumbers.ReplaceAllString(strings.Join(strings.Fields(strings.ToLower(prompt)), " "), "#")
+}
+
+func computeHistoryStats(entries []HistoryEntry) *historyStats {
+	s := &historyStats{
+		Commands:  make(counter),
+		Prompts:   make(counter),
+		Verbs:     make(map[string]*outcomeStats),
+		Contexts:  make(map[string]*outcomeStats),
+		templates: make(map[string][]HistoryEntry),
+	}
+
+	for _, e := range entries {
+		generated := e.Source == SourceAI || e.Source == SourceInteractive
+		if generated {
+			s.Generated++
+			switch {
+			case e.Declined:
+				s.Declined++
+			case e.Revised:
+				s.Revised++
+			default:
+				s.Accepted++
+			}
+		}
+		if e.Declined {
+			continue
+		}
+
+		s.Executed++
+		if e.ExitStatus != nil && !e.Succeeded() {
+			s.Failed++
+		}
+		s.Commands.add(ai.NormalizeCommand(e.Command), e.Command, e.Timestamp)
+		if e.Prompt != "" {
+			key := promptKey(e.Prompt)
+			s.Prompts.add(key, e.Prompt, e.Timestamp)
+			s.templates[key] = append(s.templates[key], e)
+		}
+
+		verb := "(none)"
+		if positional := ai.PositionalArgs(cli.ParseCommand(e.Command)); len(positional) > 0 {
+			verb = positional[0]
+		}
+		if s.Verbs[verb] == nil {
+			s.Verbs[verb] = &outcomeStats{}
+		}
+		s.Verbs[verb].add(e)
+
+		context := e.Context
+		if context == "" {
+			context = "(unknown)"
+		}
+		if s.Contexts[context] == nil {
+			s.Contexts[context] = &outcomeStats{}
+		}
+		s.Contexts[context].add(e)
+	}
+	return s
+}
+
+// suggestTemplates proposes templates for prompts repeated at least
+// minTemplateRepeats times. Arguments that vary between the commands of a
+// prompt become parameters.
+func (s *historyStats) suggestTemplates(existing []*templates.Template) []*templates.Template {
+	known := make(map[string]bool)
+	names := make(map[string]bool)
+	for _, t := range existing {
+		known[t.Command] = true
+		names[t.Name] = true
+	}
+
+	var suggestions []*templates.Template
+	for _, item := range s.Prompts.top(0) {
+		if item.Count < minTemplateRepeats {
+			break
+		}
+		t := templateFromEntries(item.Text, s.templates[promptKey(item.Text)])
+		if known[t.Command] {
+			continue
+		}
+		base := t.Name
+		for i := 2; names[t.Name]; i++ {
+			t.Name = fmt.Sprintf("%s-%d", base, i)
+		}
+		// Only suggest what template import accepts
+		data, err := templates.MarshalTemplate(t, false)
+		if err != nil {
+			continue
+		}
+		if _, errs := templates.Parse(data, t.Name+".yaml"); len(errs) > 0 {
+			continue
+		}
+		known[t.Command] = true
+		names[t.Name] = true
+		suggestions = append(suggestions, t)
+	}
+	return suggestions
+}
+
+// shortFlagNames names parameters that follow common short flags
+var shortFlagNames = map[string]string{
+	"-n": "namespace", "-l": "selector", "-o": "output", "-c": "container", "-f": "filename",
+}
+
+func templateFromEntries(prompt string, entries []HistoryEntry) *templates.Template {
+	latest := entries[len(entries)-1]
+	t := &templates.Template{
+		Name:        templateName(prompt),
+		Description: fmt.Sprintf("Generated from the prompt %q", singleLine(prompt)),
+		Command:     latest.Command,
+	}
+
+	// Commands of different shapes can't be merged into one template
+	var commands [][]string
+	for _, e := range entries {
+		args := cli.ParseCommand(e.Command)
+		if len(args) != len(cli.ParseCommand(latest.Command)) {
+			return t
+		}
+		commands = append(commands, args)
+	}
+
+	used := make(map[string]bool)
+	paramName := func(base string) string {
+		base = identifier(base, invalidParamChars, "_")
+		if base == "" || !unicode.IsLetter(rune(base[0])) {
+			base = "p" + base
+		}
+		name := base
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s%d", base, i)
+		}
+		used[name] = true
+		return name
+	}
+
+	latestArgs := commands[len(commands)-1]
+	parts := make([]string, len(latestArgs))
+	for i, arg := range latestArgs {
+		parts[i] = cli.ShellQuote(arg)
+		varies := false
+		for _, args := range commands {
+			if args[i] != arg {
+				varies = true
+				break
+			}
+		}
+		if !varies {
+			continue
+		}
+
+		var name string
+		flag, value, hasValue := strings.Cut(arg, "=")
+		switch {
+		case hasValue && strings.HasPrefix(arg, "-"):
+			name = paramName(strings.TrimLeft(flag, "-"))
+			parts[i] = flag + "={{." + name + "}}"
+			arg = value
+		case i > 0 && strings.HasPrefix(latestArgs[i-1], "-") && !strings.Contains(latestArgs[i-1], "="):
+			base := shortFlagNames[latestArgs[i-1]]
+			if base == "" {
+				base = strings.TrimLeft(latestArgs[i-1], "-")
+			}
+			name = paramName(base)
+			parts[i] = "{{." + name + "}}"
+		default:
+			name = paramName("name")
+			parts[i] = "{{." + name + "}}"
+		}
+		t.Parameters = append(t.Parameters, templates.Parameter{
+			Name:        name,
+			Description: fmt.Sprintf("e.g. %s", arg),
+			Required:    true,
+		})
+	}
+	t.Command = strings.Join(parts, " ")
+	return t
+}
+
+// Characters that template and parameter names can't contain. Parameters
+// are referenced as {{.name}}, so they don't get '-' or '.' either.
+var (
+	invalidNameChars  = regexp.MustCompile(`[^a-z0-9_.-]+`)
+	invalidParamChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+)
+
+// identifier replaces the runs of invalid characters in s with sep
+func identifier(s string, invalid *regexp.Regexp, sep string) string {
+	return strings.Trim(invalid.ReplaceAllString(s, sep), sep+".-_")
+}
+
+// templateName derives a short name from the first words of a prompt
+func templateName(prompt string) string {
+	var words []string
+	for _, word := range strings.Fields(strings.ToLower(prompt)) {
+		word = identifier(word, invalidNameChars, "-")
+		if word == "" || promptNumbers.MatchString(word) {
+			continue
+		}
+		words = append(words, word)
+		if len(words) == 3 {
+			break
+		}
+	}
+	if len(words) == 0 {
+		return "prompt"
+	}
+	return strings.Join(words, "-")
+}
+
+var historyStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show usage statistics and template suggestions from the history",
+	Example: `  oc-ai history stats
+  oc-ai history stats --since 1w --top 5`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		top, _ := cmd.Flags().GetInt("top")
+		filter, err := historyFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		hc, err := openHistory()
+		if err != nil {
+			return err
+		}
+
+		entries := filter.apply(hc.loadHistory())
+		if len(entries) == 0 {
+			fmt.Println("No command history found.")
+			return nil
+		}
+		s := computeHistoryStats(entries)
+
+		fmt.Printf("%d commands run between %s and %s, %d failed (%s)\n", s.Executed,
+			entries[0].Timestamp.Local().Format("2006-01-02"), entries[len(entries)-1].Timestamp.Local().Format("2006-01-02"),
+			s.Failed, percentOf(s.Failed, s.Executed))
+
+		if s.Generated > 0 {
+			fmt.Printf("\nAI acceptance: %s of %d generated commands run as suggested (%d revised, %d declined)\n",
+				percentOf(s.Accepted, s.Generated), s.Generated, s.Revised, s.Declined)
+		}
+
+		fmt.Println("\nMost frequent commands:")
+		for _, item := range s.Commands.top(top) {
+			fmt.Printf("  %4d  %s\n", item.Count, item.Text)
+		}
+
+		if prompts := s.Prompts.top(top); len(prompts) > 0 {
+			fmt.Println("\nMost frequent prompts:")
+			for _, item := range prompts {
+				fmt.Printf("  %4d  %s\n", item.Count, item.Text)
+			}
+		}
+
+		fmt.Println("\nFailures per verb:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  VERB\tRUNS\tFAILED\tRATE")
+		for _, verb := range sortedByRuns(s.Verbs) {
+			v := s.Verbs[verb]
+			fmt.Fprintf(w, "  %s\t%d\t%d\t%s\n", verb, v.Runs, v.Failures, v.failureRate())
+		}
+		w.Flush()
+
+		fmt.Println("\nActivity per context:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  CONTEXT\tRUNS\tFAILURE RATE\tTOP NAMESPACE\tLAST USED")
+		for _, context := range sortedByRuns(s.Contexts) {
+			c := s.Contexts[context]
+			fmt.Fprintf(w, "  %s\t%d\t%s\t%s\t%s\n", context, c.Runs, c.failureRate(), topNamespace(c.Namespaces),
+				c.Last.Local().Format("2006-01-02 15:04"))
+		}
+		w.Flush()
+
+		var existing []*templates.Template
+		if set, err := loadTemplateSet(); err == nil {
+			existing = set.List()
+		}
+		if suggestions := s.suggestTemplates(existing); len(suggestions) > 0 {
+			fmt.Printf("\nTemplate suggestions (prompts used %d+ times), save them to a file and add them with `oc-ai template import <file>`:\n\n", minTemplateRepeats)
+			data, err := templates.Marshal(suggestions, false)
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(data))
+		}
+		return nil
+	},
+}
+
+func sortedByRuns(stats map[string]*outcomeStats) []string {
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if stats[keys[i]].Runs != stats[keys[j]].Runs {
+			return stats[keys[i]].Runs > stats[keys[j]].Runs
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func topNamespace(namespaces map[string]int) string {
+	best, count := "-", 0
+	for ns, n := range namespaces {
+		if n > count || (n == count && ns < best) {
+			best, count = ns, n
+		}
+	}
+	return best
+}
+
+func init() {
+	addHistoryFilterFlags(historyStatsCmd)
+	historyStatsCmd.Flags().Int("top", 10, "Number of commands and prompts to list")
+	historyCmd.AddCommand(historyStatsCmd)
+}
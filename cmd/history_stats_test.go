@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPromptKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Scale web to 3", "scale  web to 5", true},
+		{"show logs of pod-1", "Show logs of pod-22 ", true},
+		{"list pods", "list pods in prod", false},
+		{"scale web", "scale api", false},
+	}
+	for _, tt := range tests {
+		if same := promptKey(tt.a) == promptKey(tt.b); same != tt.same {
+			t.Errorf("promptKey(%q) = %q, promptKey(%q) = %q, want same %v", tt.a, promptKey(tt.a), tt.b, promptKey(tt.b), tt.same)
+		}
+	}
+	if key := promptKey("  Scale WEB to 10 replicas "); key != "scale web to # replicas" {
+		t.Errorf("promptKey() = %q", key)
+	}
+}
+
+func TestTemplateFromEntries(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		want     string
+		params   []string
+	}{
+		{"flag value", []string{"scale deployment web --replicas=3", "scale deployment web --replicas=5"},
+			"scale deployment web --replicas={{.replicas}}", []string{"replicas=e.g. 5"}},
+		{"short flag", []string{"get pods -n web", "get pods -n shop"},
+			"get pods -n {{.namespace}}", []string{"namespace=e.g. shop"}},
+		{"flag with a separate value", []string{"logs web --since 1h", "logs web --since 2h"},
+			"logs web --since {{.since}}", []string{"since=e.g. 2h"}},
+		{"flag name with dashes", []string{"get pods --field-selector=status.phase=Running", "get pods --field-selector=status.phase=Failed"},
+			"get pods --field-selector={{.field_selector}}", []string{"field_selector=e.g. status.phase=Failed"}},
+		{"positional arguments", []string{"label pod a tier=web", "label pod b tier=api"},
+			"label pod {{.name}} {{.name2}}", []string{"name=e.g. b", "name2=e.g. tier=api"}},
+		{"constant arguments are quoted", []string{"get pods -l 'app in (a, b)' -n web", "get pods -l 'app in (a, b)' -n shop"},
+			"get pods -l 'app in (a, b)' -n {{.namespace}}", []string{"namespace=e.g. shop"}},
+		{"nothing varies", []string{"get nodes", "get nodes"}, "get nodes", nil},
+		{"different shapes", []string{"get pods", "get pods -o wide"}, "get pods -o wide", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var entries []HistoryEntry
+			for _, command := range tt.commands {
+				entries = append(entries, HistoryEntry{Command: command, Prompt: "Scale the web deployment to 3"})
+			}
+			tmpl := templateFromEntries("Scale the web deployment to 3", entries)
+			if tmpl.Name != "scale-the-web" {
+				t.Errorf("name = %q, want scale-the-web", tmpl.Name)
+			}
+			if tmpl.Command != tt.want {
+				t.Errorf("command = %q, want %q", tmpl.Command, tt.want)
+			}
+			var params []string
+			for _, p := range tmpl.Parameters {
+				params = append(params, p.Name+"="+p.Description)
+				if !p.Required {
+					t.Errorf("parameter %s isn't required", p.Name)
+				}
+			}
+			if strings.Join(params, ",") != strings.Join(tt.params, ",") {
+				t.Errorf("parameters = %q, want %q", params, tt.params)
+			}
+		})
+	}
+}
+
+func TestTemplateName(t *testing.T) {
+	tests := []struct {
+		prompt string
+		want   string
+	}{
+		{"Scale the web deployment to 3", "scale-the-web"},
+		{"show 20 logs of api", "show-logs-of"},
+		{"what's up?", "what-s-up"},
+		{"42", "prompt"},
+	}
+	for _, tt := range tests {
+		if got := templateName(tt.prompt); got != tt.want {
+			t.Errorf("templateName(%q) = %q, want %q", tt.prompt, got, tt.want)
+		}
+	}
+}
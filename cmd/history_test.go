@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"oc-ai/internal/config"
+)
+
+// testHistory returns a history store in a temporary directory, with the
+// given history_limit
+func testHistory(t *testing.T, limit int) *HistoryCommand {
+	t.Helper()
+	oldCfg, oldTool := cfg, activeTool
+	cfg, activeTool = &config.Config{HistoryLimit: limit}, "oc"
+	t.Cleanup(func() { cfg, activeTool = oldCfg, oldTool })
+	return &HistoryCommand{filePath: filepath.Join(t.TempDir(), historyFile)}
+}
+
+func historyCommands(entries []HistoryEntry) []string {
+	var commands []string
+	for _, entry := range entries {
+		commands = append(commands, entry.Command)
+	}
+	return commands
+}
+
+func TestHistoryConcurrentAdd(t *testing.T) {
+	h := testHistory(t, 0)
+	// A second store on the same file stands in for another oc-ai process
+	other := &HistoryCommand{filePath: h.filePath}
+
+	var wg sync.WaitGroup
+	for i, store := range []*HistoryCommand{h, other} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if err := store.Add(HistoryEntry{Command: fmt.Sprintf("get pods %d-%d", i, j)}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries := h.loadHistory()
+	if len(entries) != 40 {
+		t.Fatalf("got %d entries, want 40", len(entries))
+	}
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		seen[entry.ID] = true
+	}
+	if len(seen) != 40 {
+		t.Errorf("got %d distinct ids, want 40", len(seen))
+	}
+}
+
+func TestHistoryCompaction(t *testing.T) {
+	h := testHistory(t, 5)
+	for i := 0; i < 30; i++ {
+		if err := h.Add(HistoryEntry{Command: fmt.Sprintf("get pods %d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(h.filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines > 5+compactionSlack(5) {
+		t.Errorf("history file has %d lines, want at most %d", lines, 5+compactionSlack(5))
+	}
+
+	got := historyCommands(h.loadHistory())
+	want := []string{"get pods 25", "get pods 26", "get pods 27", "get pods 28", "get pods 29"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+}
+
+func TestHistoryCorruptLines(t *testing.T) {
+	h := testHistory(t, 100)
+	content := `{"command":"get pods","tool":"oc"}
+{"command":"get no
+{"command":"get nodes","tool":"oc"}
+`
+	if err := os.WriteFile(h.filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := historyCommands(h.loadHistory())
+	if strings.Join(got, ",") != "get pods,get nodes" {
+		t.Errorf("entries = %q, want the two valid lines", got)
+	}
+	corrupt, err := os.ReadFile(h.filePath + ".corrupt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(corrupt) != "{\"command\":\"get no\n" {
+		t.Errorf(".corrupt = %q, want the damaged line", corrupt)
+	}
+
+	// The damaged line was moved out, so it is only reported once
+	data, _ := os.ReadFile(h.filePath)
+	if strings.Count(string(data), "\n") != 2 {
+		t.Errorf("history file still has the damaged line:\n%s", data)
+	}
+}
+
+func TestHistoryLegacyMigration(t *testing.T) {
+	h := testHistory(t, 100)
+	legacyPath := filepath.Join(filepath.Dir(h.filePath), legacyHistoryFile)
+	legacy := `[{"timestamp":"2024-01-02T03:04:05Z","command":"get pods","tool":"oc"}]`
+	if err := os.WriteFile(legacyPath, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := h.loadHistory()
+	if len(entries) != 1 || entries[0].Command != "get pods" || entries[0].Source != SourceUnknown {
+		t.Fatalf("entries = %+v, want the migrated legacy entry", entries)
+	}
+	if _, err := os.Stat(legacyPath + ".migrated"); err != nil {
+		t.Errorf("legacy file wasn't kept as .migrated: %v", err)
+	}
+
+	if err := h.Add(HistoryEntry{Command: "get nodes"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := historyCommands(h.loadHistory()); strings.Join(got, ",") != "get pods,get nodes" {
+		t.Errorf("entries = %q, want the legacy entry followed by the new one", got)
+	}
+}
+
+func TestHistoryUnparsableLegacyFile(t *testing.T) {
+	h := testHistory(t, 100)
+	legacyPath := filepath.Join(filepath.Dir(h.filePath), legacyHistoryFile)
+	if err := os.WriteFile(legacyPath, []byte(`[{"command":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Add(HistoryEntry{Command: "get pods"}); err == nil {
+		t.Error("expected an error while the legacy file can't be migrated")
+	}
+	if _, err := os.Stat(legacyPath); err != nil {
+		t.Errorf("legacy file was moved: %v", err)
+	}
+}
+
+func TestNewHistoryEntry(t *testing.T) {
+	testHistory(t, 0)
+	ctx := map[string]string{"context": "dev/api", "namespace": "web"}
+	tests := []struct {
+		name      string
+		command   string
+		ctx       map[string]string
+		want      string
+		namespace string
+	}{
+		{"context namespace", "get pods", ctx, "get pods", "web"},
+		{"tool prefix", "oc get pods", ctx, "get pods", "web"},
+		{"namespace flag", "get pods -n shop", ctx, "get pods -n shop", "shop"},
+		{"long namespace flag", "get pods --namespace=shop", ctx, "get pods --namespace=shop", "shop"},
+		{"no context", "get nodes", nil, "get nodes", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := newHistoryEntry(SourceAI, tt.command, tt.ctx)
+			if entry.Command != tt.want || entry.Namespace != tt.namespace || entry.Context != tt.ctx["context"] {
+				t.Errorf("entry = %q in %q/%q, want %q in %q/%q",
+					entry.Command, entry.Context, entry.Namespace, tt.want, tt.ctx["context"], tt.namespace)
+			}
+			if entry.Source != SourceAI || entry.Timestamp.IsZero() {
+				t.Errorf("entry = %+v, want source and timestamp set", entry)
+			}
+		})
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, 0},
+		{"exit code", exitErr, 3},
+		{"wrapped exit code", fmt.Errorf("command failed: %w", exitErr), 3},
+		{"not run", errors.New("executable file not found in $PATH"), -1},
+	}
+	for _, tt := range tests {
+		if got := exitStatus(tt.err); got != tt.want {
+			t.Errorf("%s: exitStatus() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetOutcome(t *testing.T) {
+	testHistory(t, 0)
+	cfg.RedactEnabled = true
+	tests := []struct {
+		name    string
+		output  string
+		err     error
+		status  int
+		lines   int
+		excerpt string
+	}{
+		{"no output", "", nil, 0, 0, ""},
+		{"lines", "NAME   READY\nweb    1/1\n", nil, 0, 2, "NAME   READY\nweb    1/1\n"},
+		{"error", "Error from server (NotFound)", errors.New("not found"), -1, 1, "Error from server (NotFound)"},
+		{"secret", "password=hunter2", nil, 0, 1, "password=REDACTED_SECRET_1"},
+		{"long output", strings.Repeat("x", maxOutputExcerpt+10), nil, 0, 1, strings.Repeat("x", maxOutputExcerpt) + "\n... (truncated)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := newHistoryEntry(SourceAI, "get pods", nil)
+			entry.SetOutcome(tt.output, tt.err)
+			if entry.ExitStatus == nil || *entry.ExitStatus != tt.status || entry.Succeeded() != (tt.status == 0) {
+				t.Errorf("exit status = %v, want %d", entry.ExitStatus, tt.status)
+			}
+			if tt.err != nil && entry.Error != tt.err.Error() {
+				t.Errorf("error = %q, want %q", entry.Error, tt.err)
+			}
+			if tt.output == "" {
+				if entry.Output != nil {
+					t.Errorf("output = %+v, want none", entry.Output)
+				}
+				return
+			}
+			if entry.Output.Lines != tt.lines || entry.Output.Bytes != len(tt.output) || entry.Output.Excerpt != tt.excerpt {
+				t.Errorf("output = %d lines, %d bytes, %q; want %d lines, %d bytes, %q",
+					entry.Output.Lines, entry.Output.Bytes, entry.Output.Excerpt, tt.lines, len(tt.output), tt.excerpt)
+			}
+		})
+	}
+}
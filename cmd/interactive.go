@@ -1,10 +1,12 @@
 package cmd
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -60,10 +62,12 @@ var interactiveCmd = &cobra.Command{
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		aiClient := ai.NewClient(cfg.OpenAIKey, activeTool, cmd.Flag("ai-model").Value.String())
+		aiClient := newAIClient(cmd)
+		conversation := ai.NewConversation(cfg.ConversationMaxTurns, cfg.ConversationMaxTokens)
+		catalogs := make(map[string]*ai.ResourceCatalog)
 		var lastContext map[string]string
 
-		fmt.Println("Entering interactive mode. Type 'exit' to quit.")
+		fmt.Println("Entering interactive mode. Type 'exit' to quit, ':reset' to clear the conversation.")
 		fmt.Printf("Using %s with %s\n\n", activeTool, cmd.Flag("ai-model").Value.String())
 
 		// Start context update goroutine
@@ -91,7 +95,10 @@ var interactiveCmd = &cobra.Command{
 		}
 		execChan := make(chan execResult)
 
-		reader := bufio.NewReader(os.Stdin)
+		reader := stdin
+		// pending holds a prompt to regenerate after a clarifying question
+		var pending string
+		clarifications := 0
 		for {
 			// Non-blocking context update
 			select {
@@ -107,48 +114,127 @@ var interactiveCmd = &cobra.Command{
 				}
 			}
 
-			// Show current context
-			if ns := lastContext["namespace"]; ns != "" {
-				fmt.Printf("[%s/%s] ", lastContext["cluster"], ns)
-			}
+			var input string
+			if pending != "" {
+				input, pending = pending, ""
+			} else {
+				clarifications = 0
+
+				// Show current context
+				if ns := lastContext["namespace"]; ns != "" {
+					fmt.Printf("[%s/%s] ", lastContext["cluster"], ns)
+				}
+
+				// Read input
+				input, _ = reader.ReadString('\n')
+				input = strings.TrimSpace(input)
 
-			// Read input
-			input, _ := reader.ReadString('\n')
-			input = strings.TrimSpace(input)
+				if input == "exit" || input == "quit" {
+					break
+				}
+
+				if input == "" {
+					continue
+				}
 
-			if input == "exit" || input == "quit" {
-				break
+				if input == ":reset" {
+					conversation.Reset()
+					fmt.Println("Conversation cleared")
+					continue
+				}
 			}
 
-			if input == "" {
-				continue
+			// Resource types are looked up once per kube context
+			catalog, ok := catalogs[lastContext["context"]]
+			if !ok {
+				catalog = loadResourceCatalog(lastContext)
+				catalogs[lastContext["context"]] = catalog
 			}
 
 			// Process command concurrently
-			commandChan := make(chan string)
-			explanationChan := make(chan string)
-			safetyChan := make(chan string)
+			resultChan := make(chan *ai.GenerateResult)
 			errChan := make(chan error)
 
+			// Ctrl-C cancels the request in flight without leaving the session
+			genCtx, stopSignal := signal.NotifyContext(ctx, os.Interrupt)
+			progress := newGenerationProgress()
+
 			go func() {
-				command, explanation, safety, err := aiClient.GenerateCommand(input, lastContext)
+				result, err := aiClient.Generate(genCtx, ai.GenerateRequest{
+					Prompt:             input,
+					Context:            lastContext,
+					Conversation:       conversation,
+					Resources:          catalog,
+					Progress:           progress.Callback(),
+					Candidates:         candidateCount(cmd),
+					AllowClarification: clarifications < maxClarifications,
+					AllowPlan:          true,
+				})
+				progress.Done()
 				if err != nil {
 					errChan <- err
 					return
 				}
-				commandChan <- command
-				explanationChan <- explanation
-				safetyChan <- safety
+				if result.Offline {
+					fmt.Println("\nNo AI model reachable - command generated offline by local rules")
+				}
+				resultChan <- result
 			}()
 
-			// Wait for command generation with timeout
+			// Wait for command generation; the AI client enforces its own timeouts
 			select {
 			case err := <-errChan:
+				stopSignal()
+				if errors.Is(err, context.Canceled) {
+					fmt.Println("\nCommand generation cancelled")
+					continue
+				}
 				fmt.Printf("Error: %v\n", err)
 				continue
-			case command := <-commandChan:
-				explanation := <-explanationChan
-				safety := <-safetyChan
+			case generated := <-resultChan:
+				stopSignal()
+				if c := generated.Clarification; c != nil {
+					answer, ok := askClarification(reader, c, lastContext)
+					if !ok {
+						fmt.Println("Command not executed")
+						continue
+					}
+					clarifications++
+					pending = ai.ClarifiedPrompt(input, c.Question, answer)
+					continue
+				}
+				if plan := generated.Plan; plan != nil {
+					printPlan(plan)
+					if generated.Untrusted {
+						printInjectionWarnings(generated.InjectionFindings)
+						fmt.Println("⚠️ Warning: This plan was generated from untrusted cluster data")
+					}
+					err := runPlan(reader, plan, planOptions{
+						Source:    SourceInteractive,
+						Context:   lastContext,
+						Untrusted: generated.Untrusted,
+					})
+					if err != nil {
+						fmt.Printf("Error: %v\n", err)
+					}
+
+					var commands []string
+					for _, step := range plan.Steps {
+						commands = append(commands, step.Command)
+					}
+					conversation.Add(ai.Turn{
+						Prompt:      input,
+						Command:     strings.Join(commands, "; "),
+						Explanation: plan.Summary,
+					})
+					conversation.SetNote("plan " + plan.Status())
+					continue
+				}
+				if !chooseCandidate(reader, aiClient, input, lastContext, generated) {
+					fmt.Println("Command not executed")
+					continue
+				}
+				command, explanation, safety := generated.Command, generated.Explanation, generated.Safety
 
 				// Remove duplicate CLI tool name if present
 				if strings.HasPrefix(command, activeTool+" ") {
@@ -159,20 +245,40 @@ var interactiveCmd = &cobra.Command{
 				fmt.Printf("Explanation: %s\n", explanation)
 				fmt.Printf("Safety Level: %s/5\n", safety)
 
-				// Get confirmation
-				fmt.Print("\nExecute? [y/N/r (run/revise)]: ")
+				conversation.Add(ai.Turn{
+					Prompt:      input,
+					Command:     command,
+					Explanation: explanation,
+					Safety:      safety,
+				})
+
+				// Get confirmation; commands generated from untrusted cluster data
+				// require typing "yes", as in the ai command
+				accept := "y"
+				if generated.Untrusted {
+					printInjectionWarnings(generated.InjectionFindings)
+					fmt.Println("⚠️ Warning: This command was generated from untrusted cluster data")
+					accept = "yes"
+				}
+				fmt.Printf("\nExecute? [%s/N/r (run/revise)]: ", accept)
 				response, _ := reader.ReadString('\n')
 				response = strings.ToLower(strings.TrimSpace(response))
 
 				switch response {
-				case "y":
+				case accept:
 					// Check cache first
 					if cachedOutput, found := cmdCache.Get(command); found {
 						fmt.Println("Output (cached):")
 						fmt.Println(cachedOutput)
+						conversation.SetOutput(cachedOutput)
 						continue
 					}
 
+					entry := newHistoryEntry(SourceInteractive, command, lastContext)
+					entry.Prompt, entry.Explanation = input, explanation
+					entry.Safety, _ = strconv.Atoi(safety)
+					entry.Untrusted = generated.Untrusted
+
 					// Execute command concurrently
 					go func() {
 						output, err := cliClient.Execute(command)
@@ -188,20 +294,21 @@ var interactiveCmd = &cobra.Command{
 						fmt.Println(result.output)
 						cmdCache.Set(command, result.output)
 					}
+					conversation.SetOutput(summarizeResult(result.output, result.err))
 
-					// Asynchronously save to history
-					go func(cmd string) {
-						if historyCmd := findHistoryCommand(); historyCmd != nil {
-							if err := historyCmd.AddToHistory(cmd); err != nil {
-								fmt.Printf("Warning: Failed to save command to history: %v\n", err)
-							}
-						}
-					}(command)
+					// Saved before the next prompt, so exiting right away keeps the entry
+					entry.SetOutcome(result.output, result.err)
+					recordHistory(entry)
 
 				case "r":
 					fmt.Print("Enter revised command: ")
 					revised, _ := reader.ReadString('\n')
 					revised = strings.TrimSpace(revised)
+					conversation.SetCommand(revised)
+
+					// The revised command is the user's, so only the prompt is kept
+					entry := newHistoryEntry(SourceInteractive, revised, lastContext)
+					entry.Prompt, entry.Revised = input, true
 
 					// Execute revised command concurrently
 					go func() {
@@ -217,22 +324,21 @@ var interactiveCmd = &cobra.Command{
 						fmt.Println("Output:")
 						fmt.Println(result.output)
 					}
+					conversation.SetOutput(summarizeResult(result.output, result.err))
 
-					// Asynchronously save to history
-					go func(cmd string) {
-						if historyCmd := findHistoryCommand(); historyCmd != nil {
-							if err := historyCmd.AddToHistory(cmd); err != nil {
-								fmt.Printf("Warning: Failed to save command to history: %v\n", err)
-							}
-						}
-					}(revised)
+					// Saved before the next prompt, so exiting right away keeps the entry
+					entry.SetOutcome(result.output, result.err)
+					recordHistory(entry)
 
 				default:
+					conversation.SetNote("the command was not executed")
 					fmt.Println("Command not executed")
+					entry := newHistoryEntry(SourceInteractive, command, lastContext)
+					entry.Prompt, entry.Explanation = input, explanation
+					entry.Safety, _ = strconv.Atoi(safety)
+					entry.Untrusted = generated.Untrusted
+					recordDeclined(entry)
 				}
-			case <-time.After(15 * time.Second):
-				fmt.Println("Command generation timed out")
-				continue
 			}
 
 			fmt.Println()
@@ -242,6 +348,15 @@ var interactiveCmd = &cobra.Command{
 	},
 }
 
+// summarizeResult renders a command result for the conversation history
+func summarizeResult(output string, err error) string {
+	if err != nil {
+		return fmt.Sprintf("command failed: %v\n%s", err, output)
+	}
+	return output
+}
+
 func init() {
+	interactiveCmd.Flags().Int("candidates", 1, "Generate several candidate commands and pick one")
 	rootCmd.AddCommand(interactiveCmd)
 }
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"oc-ai/internal/ai"
+
+	"github.com/spf13/cobra"
+)
+
+// printPlan lists the steps of a plan with their status
+func printPlan(plan *ai.Plan) {
+	fmt.Printf("\nPlan: %s\n", plan.Summary)
+	for i, step := range plan.Steps {
+		status := ""
+		if step.Status != ai.StepPending {
+			status = " [" + step.Status + "]"
+		}
+		fmt.Printf("  %d. %s %s (Safety %s/5)%s\n", i+1, plan.Tool, step.Command, step.Safety, status)
+		fmt.Printf("     %s\n", step.Explanation)
+	}
+}
+
+// stepSafety is the more cautious of the model's and the local safety level
+func stepSafety(step ai.PlanStep) int {
+	level := ai.AssessSafety(step.Command)
+	if model, err := strconv.Atoi(step.Safety); err == nil && model > level {
+		level = model
+	}
+	return level
+}
+
+// confirmDrift warns when something resumed was started with another tool
+// or in another kube context, and asks for confirmation even with --yes
+func confirmDrift(started, tool, context string, ctx map[string]string) bool {
+	var drift []string
+	if tool != "" && tool != activeTool {
+		drift = append(drift, fmt.Sprintf("%s with %s, now using %s", started, tool, activeTool))
+	}
+	if context != "" && context != ctx["context"] {
+		drift = append(drift, fmt.Sprintf("%s in context %s, current context is %s", started, context, ctx["context"]))
+	}
+	if len(drift) == 0 {
+		return true
+	}
+	for _, d := range drift {
+		fmt.Printf("⚠️ Warning: %s\n", d)
+	}
+	return confirm("Continue anyway? [y/N]: ", "y")
+}
+
+// planOptions control how runPlan confirms and records steps
+type planOptions struct {
+	// Source and Context are recorded with each step in the history
+	Source  string
+	Context map[string]string
+	// AutoConfirm skips confirmation of steps below safety level 3
+	AutoConfirm bool
+	// Untrusted plans always require typing "yes" for every step
+	Untrusted bool
+}
+
+// runPlan executes the remaining steps of a plan one by one. Every step is
+// checkpointed, and execution stops at the first failure.
+func runPlan(reader *bufio.Reader, plan *ai.Plan, opts planOptions) error {
+	plan.Untrusted = plan.Untrusted || opts.Untrusted
+	if err := plan.Save(); err != nil {
+		return fmt.Errorf("failed to save plan: %w", err)
+	}
+	resumeHint := func() {
+		fmt.Printf("Resume with: oc-ai plan resume %s\n", plan.ID)
+	}
+
+	for i := plan.NextStep(); i >= 0; i = plan.NextStep() {
+		step := &plan.Steps[i]
+		// Remove duplicate CLI tool name if present
+		step.Command = strings.TrimPrefix(step.Command, activeTool+" ")
+		level := stepSafety(*step)
+
+		fmt.Printf("\nStep %d/%d: %s %s\n", i+1, len(plan.Steps), activeTool, step.Command)
+		fmt.Printf("Explanation: %s\n", step.Explanation)
+		fmt.Printf("Safety Level: %d/5\n", level)
+
+		if !opts.AutoConfirm || opts.Untrusted || level >= 3 {
+			if level >= 3 {
+				fmt.Printf("⚠️ Warning: This step may be destructive (Safety Level: %d/5)\n", level)
+			}
+			accept := "y"
+			if opts.Untrusted {
+				accept = "yes"
+			}
+			fmt.Printf("Run this step? [%s/N/s (skip)/q (quit)]: ", accept)
+			response, _ := reader.ReadString('\n')
+			switch strings.ToLower(strings.TrimSpace(response)) {
+			case accept:
+			case "s":
+				entry := newHistoryEntry(opts.Source, step.Command, opts.Context)
+				entry.Prompt, entry.Explanation, entry.Safety = plan.Prompt, step.Explanation, level
+				entry.PlanID, entry.Step = plan.ID, i+1
+				entry.Untrusted = plan.Untrusted
+				recordDeclined(entry)
+				if err := plan.Skip(i); err != nil {
+					return fmt.Errorf("failed to save plan: %w", err)
+				}
+				fmt.Println("Step skipped")
+				continue
+			default:
+				fmt.Println("Plan paused")
+				resumeHint()
+				return nil
+			}
+		}
+
+		entry := newHistoryEntry(opts.Source, step.Command, opts.Context)
+		entry.Prompt, entry.Explanation, entry.Safety = plan.Prompt, step.Explanation, level
+		entry.PlanID, entry.Step = plan.ID, i+1
+		entry.Untrusted = plan.Untrusted
+		output, err := cliClient.Execute(step.Command)
+		entry.SetOutcome(output, err)
+		recordHistory(entry)
+		if output != "" {
+			fmt.Println("Output:")
+			fmt.Println(output)
+		}
+		if saveErr := plan.Finish(i, redactOutput(output), err); saveErr != nil {
+			fmt.Printf("Warning: Failed to checkpoint plan: %v\n", saveErr)
+		}
+		if err != nil {
+			fmt.Printf("Step %d failed: %v\n", i+1, err)
+			resumeHint()
+			return fmt.Errorf("plan stopped at step %d", i+1)
+		}
+	}
+
+	fmt.Println("\nPlan completed")
+	return nil
+}
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "List, inspect and resume multi-step plans",
+}
+
+var planListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved plans",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plans, err := ai.ListPlans()
+		if err != nil {
+			return err
+		}
+		if len(plans) == 0 {
+			fmt.Println("No plans found.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tUPDATED\tSTATUS\tSUMMARY")
+		for _, p := range plans {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.ID, p.UpdatedAt.Format("2006-01-02 15:04"), p.Status(), p.Summary)
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+var planShowCmd = &cobra.Command{
+	Use:   "show [id]",
+	Short: "Show the steps of a plan with their output",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plan, err := ai.LoadPlan(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Plan %s (%s)\nPrompt: %s\n", plan.ID, plan.Status(), plan.Prompt)
+		printPlan(plan)
+		for i, step := range plan.Steps {
+			if step.Output == "" && step.Error == "" {
+				continue
+			}
+			fmt.Printf("\nStep %d output:\n%s\n", i+1, step.Output)
+			if step.Error != "" {
+				fmt.Printf("Error: %s\n", step.Error)
+			}
+		}
+		return nil
+	},
+}
+
+var planResumeCmd = &cobra.Command{
+	Use:   "resume [id]",
+	Short: "Continue a plan from its first unfinished step (default: the latest plan)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var plan *ai.Plan
+		if len(args) == 1 {
+			var err error
+			if plan, err = ai.LoadPlan(args[0]); err != nil {
+				return err
+			}
+		} else {
+			plans, err := ai.ListPlans()
+			if err != nil {
+				return err
+			}
+			for _, p := range plans {
+				if p.NextStep() >= 0 {
+					plan = p
+					break
+				}
+			}
+			if plan == nil {
+				fmt.Println("No unfinished plans found.")
+				return nil
+			}
+		}
+
+		if plan.NextStep() < 0 {
+			fmt.Printf("Plan %s is already completed\n", plan.ID)
+			return nil
+		}
+		ctx, err := cliClient.GetContext()
+		if err != nil {
+			return fmt.Errorf("failed to get cluster context: %w", err)
+		}
+
+		printPlan(plan)
+		if plan.Untrusted {
+			fmt.Println("⚠️ Warning: This plan was generated from untrusted cluster data")
+		}
+		if cmd.Flag("dry-run").Value.String() == "true" {
+			fmt.Println("Dry run - plan not executed")
+			return nil
+		}
+		if !confirmDrift("Plan was created", plan.Tool, plan.Context, ctx) {
+			fmt.Println("Plan cancelled")
+			return nil
+		}
+		return runPlan(stdin, plan, planOptions{
+			Source:      SourceAI,
+			Context:     ctx,
+			AutoConfirm: cmd.Flag("yes").Value.String() == "true",
+			Untrusted:   plan.Untrusted,
+		})
+	},
+}
+
+func init() {
+	planCmd.AddCommand(planListCmd, planShowCmd, planResumeCmd)
+	rootCmd.AddCommand(planCmd)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const progressWidth = 70
+
+// generationProgress shows the command being generated on a single terminal
+// line while the response streams in. Nothing is executed until the full
+// response has been received and validated.
+type generationProgress struct {
+	shown bool
+}
+
+// newGenerationProgress returns nil when streaming is disabled or stderr is
+// not a terminal
+func newGenerationProgress() *generationProgress {
+	if !cfg.StreamResponses || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+	return &generationProgress{}
+}
+
+// Callback returns the function to use as GenerateRequest.Progress, or nil
+// to disable streaming
+func (p *generationProgress) Callback() func(string) {
+	if p == nil {
+		return nil
+	}
+	return p.update
+}
+
+func (p *generationProgress) update(partial string) {
+	parts := strings.SplitN(partial, "|||", 2)
+	// Trim a partially received ||| separator
+	status := strings.TrimRight(strings.Join(strings.Fields(parts[0]), " "), "|")
+	if len(parts) > 1 {
+		status += " (explaining...)"
+	}
+	if len(status) > progressWidth {
+		status = status[:progressWidth-3] + "..."
+	}
+	fmt.Fprintf(os.Stderr, "\r\033[KGenerating: %s", status)
+	p.shown = true
+}
+
+// Done clears the progress line
+func (p *generationProgress) Done() {
+	if p != nil && p.shown {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+}
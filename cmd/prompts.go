@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"oc-ai/internal/ai"
+
+	"github.com/spf13/cobra"
+)
+
+var promptsCmd = &cobra.Command{
+	Use:   "prompts",
+	Short: "List the prompt templates sent to the AI model",
+	Long: `List the prompt templates for each task (generate, explain, diagnose).
+Templates named <task> or <task>.<tool> in ~/.config/oc-ai/prompts/*.tmpl override
+the built-in ones, and org_instructions from the config are appended to every prompt.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		prompts := loadPrompts()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVERSION\tSOURCE")
+		for _, p := range prompts.Prompts() {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", p.Name, p.Version, p.Source)
+		}
+		w.Flush()
+
+		if org := prompts.OrgInstructions(); len(org) > 0 {
+			fmt.Println("\nOrganization instructions:")
+			for _, instruction := range org {
+				fmt.Printf("- %s\n", instruction)
+			}
+		}
+		return nil
+	},
+}
+
+var promptsShowCmd = &cobra.Command{
+	Use:       "show [task]",
+	Short:     "Show the rendered prompt for a task with the current context",
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{ai.TaskGenerate, ai.TaskExplain, ai.TaskDiagnose},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, err := cliClient.GetContext()
+		if err != nil {
+			fmt.Printf("Warning: Could not get cluster context: %v\n", err)
+			ctx = make(map[string]string)
+		}
+
+		prompts := loadPrompts()
+		prompt, err := prompts.Render(args[0], ai.PromptData{
+			Tool:      activeTool,
+			Cluster:   ctx["cluster"],
+			Namespace: ctx["namespace"],
+			User:      ctx["user"],
+			Server:    ctx["server"],
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\nVersion: %s\n", prompt, prompts.Version(args[0], activeTool))
+		return nil
+	},
+}
+
+func init() {
+	promptsCmd.AddCommand(promptsShowCmd)
+	rootCmd.AddCommand(promptsCmd)
+}
@@ -8,6 +8,7 @@ import (
 	"oc-ai/cmd/compat"
 	"oc-ai/internal/cli"
 	"oc-ai/internal/config"
+	"oc-ai/internal/replay"
 
 	"github.com/spf13/cobra"
 )
@@ -16,6 +17,8 @@ var (
 	cfg        *config.Config
 	cliClient  cli.CLI
 	activeTool string
+	// cassette is set when OC_AI_REPLAY records or replays AI and CLI calls
+	cassette *replay.Cassette
 )
 
 var rootCmd = &cobra.Command{
@@ -30,18 +33,36 @@ to commands with safety checks and interactive features. Works with both 'oc' an
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
+		cassette, err = replay.FromEnv()
+		if err != nil {
+			return err
+		}
+		if cassette != nil && cassette.Replaying() {
+			activeTool, cliClient = cassette.Tool, cassette.WrapCLI(nil)
+			return nil
+		}
+
 		kubeconfig, _ := cmd.Flags().GetString("kubeconfig")
 		activeTool, cliClient, err = cli.DetectCLI(kubeconfig, cfg.PreferredCLI)
 		if err != nil {
 			return fmt.Errorf("no suitable CLI tool found: %w", err)
 		}
+		if cassette != nil {
+			cassette.SetTool(activeTool)
+			cliClient = cassette.WrapCLI(cliClient)
+		}
 
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// If no subcommand, pass through to underlying CLI
 		if len(args) > 0 {
-			output, err := cliClient.Execute(strings.Join(args, " "))
+			command := strings.Join(args, " ")
+			ctx, _ := cliClient.GetContext()
+			entry := newHistoryEntry(SourcePassthrough, command, ctx)
+			output, err := cliClient.Execute(command)
+			entry.SetOutcome(output, err)
+			recordHistory(entry)
 			if err != nil {
 				return err
 			}
@@ -52,17 +73,22 @@ to commands with safety checks and interactive features. Works with both 'oc' an
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	addTemplateCommands()
+	err := rootCmd.Execute()
+	flushDiskCaches()
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
 func init() {
-	// Common flags
+	// Common flags. Template parameters can't be named after them, except
+	// namespace and context (see templates.FromGlobalFlag).
 	rootCmd.PersistentFlags().BoolP("yes", "y", false, "Auto-confirm command execution")
 	rootCmd.PersistentFlags().Bool("dry-run", false, "Show command without executing")
 	rootCmd.PersistentFlags().String("ai-model", "gpt-4-turbo", "AI model to use")
+	rootCmd.PersistentFlags().Bool("offline", false, "Generate commands with local rules instead of the AI model")
 
 	// Inherited flags from oc/kubectl
 	rootCmd.PersistentFlags().StringP("namespace", "n", "", "Namespace to use")
@@ -0,0 +1,410 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"oc-ai/internal/ai"
+	"oc-ai/internal/templates"
+
+	"github.com/spf13/cobra"
+)
+
+// runbookData merges built-in variables, parameters and captured variables
+// for rendering
+func runbookData(params map[string]interface{}, builtins templates.Builtins, vars map[string]string) map[string]interface{} {
+	data := builtins.Data(params)
+	for k, v := range vars {
+		data[k] = v
+	}
+	return data
+}
+
+// renderCommand renders a step's command, quoting substituted values
+func renderCommand(name, text string, data map[string]interface{}) (string, error) {
+	tmpl, err := templates.ParseCommand(name, text)
+	if err != nil {
+		return "", fmt.Errorf("error parsing %s: %w", name, err)
+	}
+	return renderTemplate(tmpl, data)
+}
+
+// renderCondition renders a when or until condition
+func renderCondition(name, text string, data map[string]interface{}) (string, error) {
+	tmpl, err := templates.ParseCondition(name, text)
+	if err != nil {
+		return "", fmt.Errorf("error parsing %s: %w", name, err)
+	}
+	return renderTemplate(tmpl, data)
+}
+
+// printRunbook lists the steps of a runbook, rendered with the parameters.
+// Variables captured at run time are shown as <name>.
+func printRunbook(t *templates.Template, params map[string]interface{}, builtins templates.Builtins, run *templates.Run) {
+	var vars map[string]string
+	if run != nil {
+		vars = run.Vars
+	}
+	data := runbookData(t.Mask(params), builtins, vars)
+	for _, step := range t.Steps {
+		for _, name := range step.CaptureNames() {
+			if _, ok := data[name]; !ok {
+				data[name] = templates.Safe("<" + name + ">")
+			}
+		}
+	}
+
+	fmt.Printf("\nRunbook: %s (%d steps)\n", t.Name, len(t.Steps))
+	for i, step := range t.Steps {
+		command, err := renderCommand(step.Name, step.Command, data)
+		if err != nil {
+			command = step.Command
+		}
+		status := ""
+		if run != nil && run.Steps[i].Status != templates.StepPending {
+			status = " [" + run.Steps[i].Status + "]"
+		}
+		fmt.Printf("  %d. %s: %s %s%s\n", i+1, step.Name, activeTool, command, status)
+		if step.Description != "" {
+			fmt.Printf("     %s\n", step.Description)
+		}
+		if step.When != "" {
+			fmt.Printf("     when %s\n", step.When)
+		}
+		if step.Wait != nil {
+			interval, timeout := step.Wait.Durations()
+			fmt.Printf("     every %s until %s (timeout %s)\n", interval, step.Wait.Until, timeout)
+		}
+	}
+}
+
+// runbookParams are the parameter values stored in a run log; sensitive
+// values are left out and asked for again on resume
+func runbookParams(t *templates.Template, params map[string]interface{}) map[string]string {
+	stored := make(map[string]string)
+	for _, p := range t.Parameters {
+		if !p.Sensitive {
+			stored[p.Name] = fmt.Sprint(params[p.Name])
+		}
+	}
+	return stored
+}
+
+// runRunbook executes the remaining steps of a runbook run. Every step is
+// checkpointed in the run log, and execution stops at the first failure.
+func runRunbook(t *templates.Template, params map[string]interface{}, builtins templates.Builtins, run *templates.Run, ctx map[string]string, autoConfirm bool) error {
+	run.Redact = redactOutput
+	if err := run.Save(); err != nil {
+		return fmt.Errorf("failed to save run log: %w", err)
+	}
+	resumeHint := func() {
+		fmt.Printf("Resume with: oc-ai template resume %s\n", run.ID)
+	}
+
+	for i := run.NextStep(); i >= 0; i = run.NextStep() {
+		step := t.Steps[i]
+		data := runbookData(params, builtins, run.Vars)
+		fmt.Printf("\nStep %d/%d: %s\n", i+1, len(t.Steps), step.Name)
+		if step.Description != "" {
+			fmt.Printf("Description: %s\n", step.Description)
+		}
+
+		if step.When != "" {
+			rendered, err := renderCondition("when", step.When, data)
+			if err != nil {
+				return stepFailed(run, i, "", "", err, resumeHint)
+			}
+			if !templates.Truthy(rendered) {
+				fmt.Println("Condition not met, step skipped")
+				if err := run.Skip(i, "condition not met"); err != nil {
+					return fmt.Errorf("failed to save run log: %w", err)
+				}
+				continue
+			}
+		}
+
+		command, err := renderCommand("command", step.Command, data)
+		if err != nil {
+			return stepFailed(run, i, "", "", err, resumeHint)
+		}
+		// Sensitive values are kept out of the terminal, the history and the
+		// run log by rendering the command again with masked values
+		displayCmd := command
+		if t.Sensitive() {
+			if displayCmd, err = renderCommand("command", step.Command, runbookData(t.Mask(params), builtins, run.Vars)); err != nil {
+				return stepFailed(run, i, "", "", err, resumeHint)
+			}
+		}
+		level := ai.AssessSafety(command)
+		fmt.Printf("Command: %s %s\n", activeTool, displayCmd)
+		fmt.Printf("Safety Level: %d/5\n", level)
+
+		entry := newHistoryEntry(SourceTemplate, displayCmd, ctx)
+		entry.Template, entry.Explanation, entry.Safety = t.Name, step.Description, level
+		entry.PlanID, entry.Step = run.ID, i+1
+		entry.Masked = t.Sensitive()
+
+		if !autoConfirm || step.Confirm || level >= 3 {
+			if level >= 3 {
+				fmt.Printf("⚠️ Warning: This step may be destructive (Safety Level: %d/5)\n", level)
+			}
+			fmt.Print("Run this step? [y/N/s (skip)/q (quit)]: ")
+			response, _ := stdin.ReadString('\n')
+			switch strings.ToLower(strings.TrimSpace(response)) {
+			case "y", "yes":
+			case "s":
+				recordDeclined(entry)
+				if err := run.Skip(i, "skipped by user"); err != nil {
+					return fmt.Errorf("failed to save run log: %w", err)
+				}
+				fmt.Println("Step skipped")
+				continue
+			default:
+				fmt.Println("Runbook paused")
+				resumeHint()
+				return nil
+			}
+		}
+
+		entry.Timestamp = time.Now()
+		output, attempts, err := runStep(t, step, command, params, builtins, run.Vars)
+		entry.SetOutcome(output, err)
+		if entry.Output != nil {
+			entry.Output.Excerpt = t.Redact(entry.Output.Excerpt, params)
+		}
+		recordHistory(entry)
+		if output != "" {
+			fmt.Println("Output:")
+			fmt.Println(output)
+		}
+		for _, name := range step.CaptureNames() {
+			if value, ok := run.Vars[name]; ok && err == nil {
+				fmt.Printf("Captured %s = %s\n", name, value)
+			}
+		}
+
+		run.Steps[i].Attempts = attempts
+		if err != nil {
+			return stepFailed(run, i, displayCmd, t.Redact(output, params), err, resumeHint)
+		}
+		if saveErr := run.Finish(i, displayCmd, t.Redact(output, params), nil); saveErr != nil {
+			fmt.Printf("Warning: Failed to checkpoint run: %v\n", saveErr)
+		}
+	}
+
+	fmt.Println("\nRunbook completed")
+	return nil
+}
+
+func stepFailed(run *templates.Run, i int, command, output string, err error, resumeHint func()) error {
+	if saveErr := run.Finish(i, command, output, err); saveErr != nil {
+		fmt.Printf("Warning: Failed to checkpoint run: %v\n", saveErr)
+	}
+	fmt.Printf("Step %d failed: %v\n", i+1, err)
+	resumeHint()
+	return fmt.Errorf("runbook stopped at step %d", i+1)
+}
+
+// runStep executes a step's command and captures its variables into vars.
+// A step with a wait condition is rerun, including after errors, until the
+// condition holds or the timeout expires.
+func runStep(t *templates.Template, step templates.Step, command string, params map[string]interface{}, builtins templates.Builtins, vars map[string]string) (string, int, error) {
+	var interval, timeout time.Duration
+	if step.Wait != nil {
+		interval, timeout = step.Wait.Durations()
+	}
+	deadline := time.Now().Add(timeout)
+
+	for attempt := 1; ; attempt++ {
+		output, err := cliClient.Execute(command)
+		if err == nil {
+			err = captureVars(step, output, vars)
+		}
+		if step.Wait == nil {
+			return output, attempt, err
+		}
+
+		if err == nil {
+			rendered, renderErr := renderCondition("until", step.Wait.Until, runbookData(params, builtins, vars))
+			if renderErr != nil {
+				return output, attempt, renderErr
+			}
+			if templates.Truthy(rendered) {
+				return output, attempt, nil
+			}
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			if err != nil {
+				return output, attempt, fmt.Errorf("timed out after %s waiting for %s: %w", timeout, step.Wait.Until, err)
+			}
+			return output, attempt, fmt.Errorf("timed out after %s waiting for %s", timeout, step.Wait.Until)
+		}
+		reason := "condition not met"
+		if err != nil {
+			reason = t.Redact(err.Error(), params)
+		}
+		fmt.Printf("Waiting: %s (attempt %d), retrying in %s\n", reason, attempt, interval)
+		time.Sleep(interval)
+	}
+}
+
+// captureVars evaluates the step's JSONPath captures on its output
+func captureVars(step templates.Step, output string, vars map[string]string) error {
+	for _, name := range step.CaptureNames() {
+		path, err := templates.CompileJSONPath(step.Capture[name])
+		if err != nil {
+			return err
+		}
+		value, err := path.Capture(output)
+		if err != nil {
+			return fmt.Errorf("failed to capture %s: %w", name, err)
+		}
+		vars[name] = value
+	}
+	return nil
+}
+
+var templateRunsCmd = &cobra.Command{
+	Use:   "runs [id]",
+	Short: "List runbook runs, or show the steps of one",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			run, err := templates.LoadRun(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Run %s of %s (%s)\n", run.ID, run.Template, run.Status())
+			if run.Context != "" {
+				fmt.Printf("Context: %s\n", run.Context)
+			}
+			for i, step := range run.Steps {
+				fmt.Printf("\n%d. %s [%s]", i+1, step.Name, step.Status)
+				if step.Reason != "" {
+					fmt.Printf(" %s", step.Reason)
+				}
+				if step.Attempts > 1 {
+					fmt.Printf(" after %d attempts", step.Attempts)
+				}
+				fmt.Println()
+				if step.Command != "" {
+					fmt.Printf("   %s %s\n", run.Tool, step.Command)
+				}
+				if step.Output != "" {
+					fmt.Printf("%s\n", step.Output)
+				}
+				if step.Error != "" {
+					fmt.Printf("   Error: %s\n", step.Error)
+				}
+			}
+			if len(run.Vars) > 0 {
+				fmt.Println("\nCaptured variables:")
+				for name, value := range run.Vars {
+					fmt.Printf("  %s = %s\n", name, value)
+				}
+			}
+			return nil
+		}
+
+		runs, err := templates.ListRuns()
+		if err != nil {
+			return err
+		}
+		if len(runs) == 0 {
+			fmt.Println("No runbook runs found.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tUPDATED\tSTATUS\tRUNBOOK")
+		for _, r := range runs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.ID, r.UpdatedAt.Format("2006-01-02 15:04"), r.Status(), r.Template)
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+var templateResumeCmd = &cobra.Command{
+	Use:   "resume [id]",
+	Short: "Continue a runbook run from its first unfinished step (default: the latest run)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var run *templates.Run
+		if len(args) == 1 {
+			var err error
+			if run, err = templates.LoadRun(args[0]); err != nil {
+				return err
+			}
+		} else {
+			runs, err := templates.ListRuns()
+			if err != nil {
+				return err
+			}
+			for _, r := range runs {
+				if r.NextStep() >= 0 {
+					run = r
+					break
+				}
+			}
+			if run == nil {
+				fmt.Println("No unfinished runbook runs found.")
+				return nil
+			}
+		}
+		if run.NextStep() < 0 {
+			fmt.Printf("Run %s is already completed\n", run.ID)
+			return nil
+		}
+
+		set, err := loadTemplateSet()
+		if err != nil {
+			return err
+		}
+		t, ok := set.Get(run.Template)
+		if !ok || !t.IsRunbook() {
+			return fmt.Errorf("runbook '%s' not found", run.Template)
+		}
+		if !run.Matches(t) {
+			return fmt.Errorf("the steps of runbook %s changed since run %s started, start a new run instead", t.Name, run.ID)
+		}
+		params, err := t.Resolve(run.Params, promptParameter)
+		if err != nil {
+			return err
+		}
+		for _, name := range run.MaskedVars {
+			value, ok, err := promptParameter(templates.Parameter{Name: name, Description: "captured value masked in the run log", Sensitive: true}, nil)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("captured variable %s was masked in the run log, enter it in a terminal or start a new run", name)
+			}
+			run.Vars[name] = value
+		}
+
+		ctx, err := cliClient.GetContext()
+		if err != nil {
+			return fmt.Errorf("failed to get cluster context: %w", err)
+		}
+
+		builtins := templateBuiltins(cmd, ctx)
+		printRunbook(t, params, builtins, run)
+		if cmd.Flag("dry-run").Value.String() == "true" {
+			fmt.Println("Dry run - runbook not executed")
+			return nil
+		}
+		if !confirmDrift("Run was started", run.Tool, run.Context, ctx) || !confirmOverride(t, set.Overridden(t.Name)) {
+			fmt.Println("Runbook cancelled")
+			return nil
+		}
+		return runRunbook(t, params, builtins, run, ctx, cmd.Flag("yes").Value.String() == "true")
+	},
+}
+
+func init() {
+	templateCmd.AddCommand(templateRunsCmd, templateResumeCmd)
+}
@@ -1,68 +1,194 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
-	"text/template"
+	"text/tabwriter"
+
+	"oc-ai/internal/config"
+	"oc-ai/internal/templates"
 
 	"github.com/spf13/cobra"
 )
 
-type CommandTemplate struct {
-	Name        string              `json:"name"`
-	Description string              `json:"description"`
-	Command     string              `json:"command"`
-	Parameters  []TemplateParameter `json:"parameters"`
+// loadTemplateSet loads the templates of every template directory, warning
+// about invalid template files
+func loadTemplateSet() (*templates.Set, error) {
+	dirs, err := templates.Dirs(cfg.TemplateDirs)
+	if err != nil {
+		return nil, err
+	}
+	set := templates.Load(dirs)
+	for _, err := range set.Errors {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	return set, nil
 }
 
-type TemplateParameter struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Required    bool   `json:"required"`
-}
+// templateTarget returns the scope and directory new templates are written
+// to: the user's templates unless --project or --team is set
+func templateTarget(cmd *cobra.Command) (string, string, error) {
+	project, _ := cmd.Flags().GetBool("project")
+	team, _ := cmd.Flags().GetBool("team")
+	switch {
+	case project && team:
+		return "", "", fmt.Errorf("--project and --team are mutually exclusive")
+	case project:
+		if dir := templates.FindProjectDir(); dir != "" {
+			return templates.ScopeProject, dir, nil
+		}
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", "", err
+		}
+		return templates.ScopeProject, filepath.Join(wd, templates.ProjectDir), nil
+	case team:
+		dirs, err := templates.Dirs(cfg.TemplateDirs)
+		if err != nil {
+			return "", "", err
+		}
+		for _, dir := range dirs {
+			if dir.Scope == templates.ScopeTeam {
+				return templates.ScopeTeam, dir.Path, nil
+			}
+		}
+		return "", "", fmt.Errorf("no team template directory configured (set template_dirs in config.yaml)")
+	}
 
-func loadTemplates() ([]CommandTemplate, error) {
-	configDir, err := os.UserConfigDir()
+	configDir, err := config.Dir()
 	if err != nil {
-		return nil, err
+		return "", "", err
+	}
+	return templates.ScopeUser, filepath.Join(configDir, "templates"), nil
+}
+
+// templateFormat returns whether to write JSON, from --format or else the
+// given default
+func templateFormat(cmd *cobra.Command, defaultJSON bool) (bool, error) {
+	format, _ := cmd.Flags().GetString("format")
+	switch format {
+	case "":
+		return defaultJSON, nil
+	case "yaml", "yml":
+		return false, nil
+	case "json":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid format %q: must be yaml or json", format)
+}
+
+// saveTemplate writes a new template to the target scope. An existing
+// template of the same name in that scope is only replaced with --force.
+func saveTemplate(set *templates.Set, t *templates.Template, scope, dir string, asJSON, force bool) (string, error) {
+	if existing, ok := set.InScope(t.Name, scope); ok {
+		if !force {
+			return "", fmt.Errorf("template %q already exists in %s (use --force to replace it)", t.Name, existing.Path)
+		}
+		if err := templates.Remove(existing); err != nil {
+			return "", err
+		}
+	}
+	if existing, ok := set.Get(t.Name); ok && existing.Scope != scope {
+		if templates.Precedence(scope) < templates.Precedence(existing.Scope) {
+			fmt.Printf("Note: this overrides template %q in %s (%s)\n", t.Name, existing.Path, existing.Scope)
+		} else {
+			fmt.Printf("Note: template %q in %s (%s) takes precedence over this one\n", t.Name, existing.Path, existing.Scope)
+		}
 	}
+	return templates.Write(dir, t, asJSON)
+}
 
-	path := filepath.Join(configDir, "oc-ai", "templates.json")
-	data, err := os.ReadFile(path)
+// editTemplate opens data in the user's editor until it holds one valid
+// template or the user gives up
+func editTemplate(data []byte, ext string) (*templates.Template, error) {
+	file, err := os.CreateTemp("", "oc-ai-template-*"+ext)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	path := file.Name()
+	defer os.Remove(path)
+	_, err = file.Write(data)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write temporary file: %w", err)
 	}
 
-	var templates []CommandTemplate
-	if err := json.Unmarshal(data, &templates); err != nil {
-		return nil, err
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
 	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	for {
+		editorCmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+		editorCmd.Stdin, editorCmd.Stdout, editorCmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := editorCmd.Run(); err != nil {
+			return nil, fmt.Errorf("editor failed: %w", err)
+		}
 
-	return templates, nil
+		parsed, errs := templates.ParseFile(path)
+		if len(errs) == 0 && len(parsed) != 1 {
+			errs = []error{fmt.Errorf("expected exactly one template, found %d", len(parsed))}
+		}
+		if len(errs) == 0 {
+			return parsed[0], nil
+		}
+		for _, err := range errs {
+			fmt.Printf("  %v\n", err)
+		}
+		if !confirm("Edit again? [y/N] ", "y") {
+			return nil, fmt.Errorf("template is invalid, nothing was saved")
+		}
+	}
 }
 
 var templateCmd = &cobra.Command{
 	Use:   "template",
 	Short: "Manage command templates",
+	Long: `Manage parameterized command templates.
+
+Templates are YAML or JSON files holding one template or a list of them. They are
+loaded from, in order of precedence:
+  1. the project's .oc-ai/templates directory (in the current directory or a parent)
+  2. ~/.config/oc-ai/templates/ and the legacy ~/.config/oc-ai/templates.json
+  3. the shared team directories listed in template_dirs in config.yaml
+A template defined in several places is taken from the first one.`,
 }
 
 var templateListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List available templates",
-	Run: func(cmd *cobra.Command, args []string) {
-		templates, err := loadTemplates()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		set, err := loadTemplateSet()
 		if err != nil {
-			fmt.Printf("Error loading templates: %v\n", err)
-			return
+			return err
+		}
+		list := set.List()
+		if len(list) == 0 {
+			fmt.Println("No templates found. Add one with: oc-ai template add <name>")
+			return nil
 		}
 
 		fmt.Println("Available Templates:")
-		for _, t := range templates {
-			fmt.Printf("  %s - %s\n", t.Name, t.Description)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, t := range list {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", t.Name, t.Scope, t.Description)
+		}
+		w.Flush()
+
+		if len(set.Shadowed) > 0 {
+			fmt.Println("\nOverridden:")
+			for _, t := range set.Shadowed {
+				fmt.Printf("  %s (%s) in %s\n", t.Name, t.Scope, t.Path)
+			}
 		}
+		return nil
 	},
 }
 
@@ -70,107 +196,389 @@ var templateShowCmd = &cobra.Command{
 	Use:   "show [name]",
 	Short: "Show template details",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		templates, err := loadTemplates()
-		if err != nil {
-			fmt.Printf("Error loading templates: %v\n", err)
-			return
-		}
-
-		for _, t := range templates {
-			if t.Name == args[0] {
-				fmt.Printf("\nTemplate: %s\n", t.Name)
-				fmt.Printf("Description: %s\n", t.Description)
-				fmt.Printf("Command: %s\n", t.Command)
-				fmt.Println("Parameters:")
-				for _, p := range t.Parameters {
-					fmt.Printf("  %s (required: %t) - %s\n",
-						p.Name, p.Required, p.Description)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		set, err := loadTemplateSet()
+		if err != nil {
+			return err
+		}
+		t, ok := set.Get(args[0])
+		if !ok {
+			return fmt.Errorf("template '%s' not found", args[0])
+		}
+
+		fmt.Printf("\nTemplate: %s\n", t.Name)
+		fmt.Printf("Description: %s\n", t.Description)
+		fmt.Printf("Source: %s (%s)\n", t.Path, t.Scope)
+		if t.IsRunbook() {
+			fmt.Println("Steps:")
+			for i, step := range t.Steps {
+				fmt.Printf("  %d. %s: %s\n", i+1, step.Name, step.Command)
+				if step.When != "" {
+					fmt.Printf("     when %s\n", step.When)
+				}
+				if step.Wait != nil {
+					fmt.Printf("     until %s\n", step.Wait.Until)
 				}
-				return
+				for _, name := range step.CaptureNames() {
+					fmt.Printf("     capture %s = %s\n", name, step.Capture[name])
+				}
+			}
+		} else {
+			fmt.Printf("Command: %s\n", t.Command)
+		}
+		fmt.Println("Parameters:")
+		for _, p := range t.Parameters {
+			fmt.Printf("  %s (%s) - %s\n", p.Name, describeParameter(p), p.Description)
+		}
+		for _, other := range set.Shadowed {
+			if other.Name == t.Name {
+				fmt.Printf("Overrides: %s (%s)\n", other.Path, other.Scope)
 			}
 		}
-		fmt.Printf("Template '%s' not found\n", args[0])
+		return nil
 	},
 }
 
-var templateRunCmd = &cobra.Command{
-	Use:   "run [name]",
-	Short: "Execute a template",
+// describeParameter summarizes a parameter's type, requirement and default
+func describeParameter(p templates.Parameter) string {
+	details := []string{p.Kind()}
+	if hint := p.Hint(); p.Kind() == templates.TypeEnum {
+		details[0] = "one of " + hint
+	}
+	if p.Required {
+		details = append(details, "required")
+	}
+	if p.Default != "" {
+		details = append(details, "default "+p.Default)
+	}
+	if p.Pattern != "" {
+		details = append(details, "matching "+p.Pattern)
+	}
+	if p.Sensitive {
+		details = append(details, "sensitive")
+	}
+	return strings.Join(details, ", ")
+}
+
+// templateSkeleton is the starting point for templates written in an editor
+const templateSkeleton = `name: %s
+description: ""
+# Go template syntax; every {{.field}} must be a declared parameter
+command: get pods -n {{.namespace}}
+parameters:
+  - name: namespace
+    description: Namespace to list
+    required: true
+`
+
+var templateAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Create a template",
+	Long: `Create a template in the user's templates, or with --project or --team in the
+project or shared team directory. Without --command the template is opened in
+$VISUAL or $EDITOR.`,
+	Example: `  oc-ai template add scale-app --command 'scale deployment/{{.name}} --replicas={{.replicas}}' \
+      --param name:resource-name="Deployment name" --param replicas:int --description "Scale a deployment"
+  oc-ai template add restart-app --project`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		command, _ := cmd.Flags().GetString("command")
+		description, _ := cmd.Flags().GetString("description")
+		params, _ := cmd.Flags().GetStringArray("param")
+		force, _ := cmd.Flags().GetBool("force")
+		asJSON, err := templateFormat(cmd, false)
+		if err != nil {
+			return err
+		}
+		scope, dir, err := templateTarget(cmd)
+		if err != nil {
+			return err
+		}
+		set, err := loadTemplateSet()
+		if err != nil {
+			return err
+		}
+
+		var t *templates.Template
+		if command == "" {
+			if existing, ok := set.InScope(args[0], scope); ok && !force {
+				return fmt.Errorf("template %q already exists in %s (use edit, or --force to replace it)", args[0], existing.Path)
+			}
+			t, err = editTemplate([]byte(fmt.Sprintf(templateSkeleton, args[0])), ".yaml")
+			if err != nil {
+				return err
+			}
+		} else {
+			t = &templates.Template{Name: args[0], Description: description, Command: command}
+			for _, param := range params {
+				spec, desc, _ := strings.Cut(param, "=")
+				name, kind, _ := strings.Cut(spec, ":")
+				t.Parameters = append(t.Parameters, templates.Parameter{Name: name, Type: kind, Description: desc, Required: true})
+			}
+		}
+
+		path, err := saveTemplate(set, t, scope, dir, asJSON, force)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Saved template %s to %s\n", t.Name, path)
+		return nil
+	},
+}
+
+var templateEditCmd = &cobra.Command{
+	Use:   "edit [name]",
+	Short: "Edit a template in $VISUAL or $EDITOR",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		templates, err := loadTemplates()
+		set, err := loadTemplateSet()
 		if err != nil {
-			return fmt.Errorf("error loading templates: %w", err)
+			return err
+		}
+		t, ok := set.Get(args[0])
+		if !ok {
+			return fmt.Errorf("template '%s' not found", args[0])
 		}
 
-		var selected *CommandTemplate
-		for _, t := range templates {
-			if t.Name == args[0] {
-				selected = &t
-				break
+		data, err := templates.MarshalTemplate(t, templates.IsJSON(t.Path))
+		if err != nil {
+			return err
+		}
+		edited, err := editTemplate(data, filepath.Ext(t.Path))
+		if err != nil {
+			return err
+		}
+		if edited.Name != t.Name {
+			if other, ok := set.InScope(edited.Name, t.Scope); ok {
+				return fmt.Errorf("cannot rename to %q: it already exists in %s", edited.Name, other.Path)
 			}
 		}
 
-		if selected == nil {
+		if err := templates.Replace(t, edited); err != nil {
+			return err
+		}
+		fmt.Printf("Saved template %s to %s\n", edited.Name, t.Path)
+		return nil
+	},
+}
+
+var templateDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a template",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		yes, _ := cmd.Flags().GetBool("yes")
+		set, err := loadTemplateSet()
+		if err != nil {
+			return err
+		}
+		t, ok := set.Get(args[0])
+		if !ok {
 			return fmt.Errorf("template '%s' not found", args[0])
 		}
 
-		params := make(map[string]string)
-		for _, p := range selected.Parameters {
-			value, err := cmd.Flags().GetString(p.Name)
+		if !yes && !confirm(fmt.Sprintf("Delete template %s from %s? [y/N] ", t.Name, t.Path), "y") {
+			fmt.Println("Template not deleted")
+			return nil
+		}
+		if err := templates.Remove(t); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted template %s from %s\n", t.Name, t.Path)
+
+		for _, other := range set.Shadowed {
+			if other.Name == t.Name {
+				fmt.Printf("Template %s now resolves to %s (%s)\n", t.Name, other.Path, other.Scope)
+				break
+			}
+		}
+		return nil
+	},
+}
+
+var templateValidateCmd = &cobra.Command{
+	Use:   "validate [file...]",
+	Short: "Check template files for errors",
+	Long: `Check template files for syntax errors, unknown fields, invalid names and
+undeclared parameters, reporting each problem as file:line:column. Without
+arguments every template directory is checked.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			// Check the directories together so conflicting definitions are
+			// reported too
+			dirs, err := templates.Dirs(cfg.TemplateDirs)
 			if err != nil {
-				return fmt.Errorf("error getting parameter %s: %w", p.Name, err)
+				return err
 			}
-			if p.Required && value == "" {
-				return fmt.Errorf("parameter %s is required", p.Name)
+			set := templates.Load(dirs)
+			for _, err := range set.Errors {
+				fmt.Println(err)
 			}
-			params[p.Name] = value
+			if len(set.Errors) > 0 {
+				return fmt.Errorf("%d problems found", len(set.Errors))
+			}
+			fmt.Printf("%d templates OK\n", len(set.List())+len(set.Shadowed))
+			return nil
 		}
 
-		tmpl, err := template.New("command").Parse(selected.Command)
+		problems := 0
+		for _, file := range args {
+			parsed, errs := templates.ParseFile(file)
+			for _, err := range errs {
+				fmt.Println(err)
+			}
+			problems += len(errs)
+			if len(errs) == 0 {
+				fmt.Printf("%s: %d templates OK\n", file, len(parsed))
+			}
+		}
+
+		if problems > 0 {
+			return fmt.Errorf("%d problems found", problems)
+		}
+		return nil
+	},
+}
+
+var templateImportCmd = &cobra.Command{
+	Use:   "import [file|-]",
+	Short: "Import templates from a YAML or JSON file",
+	Long: `Import the templates of a file (or stdin with -) into the user's templates, or
+with --project or --team into the project or shared team directory. Each template
+is saved to its own file. Nothing is imported if the file has errors.`,
+	Example: `  oc-ai template import team-templates.yaml --team
+  oc-ai template export --format json | oc-ai template import - --format json --project`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+		format, _ := cmd.Flags().GetString("format")
+
+		var data []byte
+		var err error
+		name := args[0]
+		if name == "-" {
+			data, err = io.ReadAll(os.Stdin)
+			name = "stdin.yaml"
+			if format == "json" {
+				name = "stdin.json"
+			}
+		} else {
+			data, err = os.ReadFile(name)
+		}
 		if err != nil {
-			return fmt.Errorf("error parsing template: %w", err)
+			return fmt.Errorf("failed to read templates: %w", err)
 		}
 
-		var buf strings.Builder
-		if err := tmpl.Execute(&buf, params); err != nil {
-			return fmt.Errorf("error executing template: %w", err)
+		parsed, errs := templates.Parse(data, name)
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%d problems found, nothing imported", len(errs))
+		}
+		if len(parsed) == 0 {
+			return fmt.Errorf("no templates found in %s", args[0])
 		}
 
-		generatedCmd := buf.String()
-		fmt.Printf("Generated command: %s %s\n", activeTool, generatedCmd)
+		asJSON, err := templateFormat(cmd, templates.IsJSON(name))
+		if err != nil {
+			return err
+		}
+		scope, dir, err := templateTarget(cmd)
+		if err != nil {
+			return err
+		}
+		set, err := loadTemplateSet()
+		if err != nil {
+			return err
+		}
+		if !force {
+			for _, t := range parsed {
+				if existing, ok := set.InScope(t.Name, scope); ok {
+					return fmt.Errorf("template %q already exists in %s (use --force to replace it), nothing imported", t.Name, existing.Path)
+				}
+			}
+		}
 
-		if cmd.Flag("dry-run").Value.String() == "true" {
-			fmt.Println("Dry run - command not executed")
-			return nil
+		for _, t := range parsed {
+			path, err := saveTemplate(set, t, scope, dir, asJSON, force)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Imported template %s to %s\n", t.Name, path)
 		}
+		return nil
+	},
+}
 
-		output, err := cliClient.Execute(generatedCmd)
+var templateExportCmd = &cobra.Command{
+	Use:   "export [name...]",
+	Short: "Export templates as YAML or JSON",
+	Example: `  oc-ai template export > templates.yaml
+  oc-ai template export scale-app restart-app --format json --output ops.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		asJSON, err := templateFormat(cmd, templates.IsJSON(output))
+		if err != nil {
+			return err
+		}
+		set, err := loadTemplateSet()
 		if err != nil {
-			return fmt.Errorf("error executing command: %v\nOutput: %s", err, output)
+			return err
 		}
 
-		if output != "" {
-			fmt.Println("Command output:")
-			fmt.Println(output)
+		selected := set.List()
+		if len(args) > 0 {
+			selected = nil
+			for _, name := range args {
+				t, ok := set.Get(name)
+				if !ok {
+					return fmt.Errorf("template '%s' not found", name)
+				}
+				selected = append(selected, t)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("no templates to export")
 		}
 
+		data, err := templates.Marshal(selected, asJSON)
+		if err != nil {
+			return err
+		}
+		if output == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		if err := os.WriteFile(output, data, 0644); err != nil {
+			return fmt.Errorf("failed to write export file: %w", err)
+		}
+		fmt.Printf("Exported %d templates to %s\n", len(selected), output)
 		return nil
 	},
 }
 
 func init() {
-	// Initialize template parameters for run command
-	templates, _ := loadTemplates()
-	for _, t := range templates {
-		for _, p := range t.Parameters {
-			templateRunCmd.Flags().String(p.Name, "", p.Description)
-		}
+	for _, c := range []*cobra.Command{templateAddCmd, templateImportCmd} {
+		c.Flags().Bool("project", false, "Save to the project's .oc-ai/templates directory")
+		c.Flags().Bool("team", false, "Save to the first shared team directory (template_dirs)")
+		c.Flags().Bool("force", false, "Replace existing templates of the same name")
+		c.Flags().String("format", "", "File format: yaml or json")
 	}
+	templateAddCmd.Flags().String("command", "", "Command template, e.g. 'get pods -n {{.namespace}}'")
+	templateAddCmd.Flags().String("description", "", "Template description")
+	templateAddCmd.Flags().StringArray("param", nil, "Required parameter as name[:type][=description] (repeatable)")
+	templateExportCmd.Flags().String("format", "", "Export format: yaml or json (default yaml, or json for a .json --output)")
+	templateExportCmd.Flags().String("output", "", "Write the export to a file instead of stdout")
 
 	templateCmd.AddCommand(templateListCmd)
 	templateCmd.AddCommand(templateShowCmd)
 	templateCmd.AddCommand(templateRunCmd)
+	templateCmd.AddCommand(templateAddCmd)
+	templateCmd.AddCommand(templateEditCmd)
+	templateCmd.AddCommand(templateDeleteCmd)
+	templateCmd.AddCommand(templateValidateCmd)
+	templateCmd.AddCommand(templateImportCmd)
+	templateCmd.AddCommand(templateExportCmd)
 	rootCmd.AddCommand(templateCmd)
 }
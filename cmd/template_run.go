@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"oc-ai/internal/ai"
+	"oc-ai/internal/config"
+	"oc-ai/internal/templates"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var templateRunCmd = &cobra.Command{
+	Use:   "run [template]",
+	Short: "Execute a template",
+	Long: `Execute a template. Every template is a subcommand of run with its own flags,
+one per parameter; see 'oc-ai template run <template> --help'.`,
+	Example: `  oc-ai template run scale-app --name web --replicas 3
+  oc-ai template run scale-app --help`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return cmd.Help()
+		}
+		// Templates are subcommands, so a name landing here isn't a valid
+		// template; loading the set reports why, e.g. a file with errors
+		if _, err := loadTemplateSet(); err != nil {
+			return err
+		}
+		return fmt.Errorf("template '%s' not found, see 'oc-ai template list'", args[0])
+	},
+}
+
+// addTemplateCommands adds a subcommand to template run for every template.
+// It runs before the command line is parsed, so the config isn't loaded yet.
+func addTemplateCommands() {
+	var teamDirs []string
+	if c, err := config.LoadConfig(); err == nil {
+		teamDirs = c.TemplateDirs
+	}
+	dirs, err := templates.Dirs(teamDirs)
+	if err != nil {
+		return
+	}
+	set := templates.Load(dirs)
+	for _, t := range set.List() {
+		templateRunCmd.AddCommand(templateCommand(t, set.Overridden(t.Name)))
+	}
+}
+
+// templateCommand builds the subcommand running a template, with a typed
+// flag per parameter. Parameters named namespace or context take the value
+// of the global flag.
+func templateCommand(t *templates.Template, overridden []*templates.Template) *cobra.Command {
+	long := t.Description
+	if long != "" {
+		long += "\n\n"
+	}
+	if t.IsRunbook() {
+		long += "Runbook steps:\n"
+		for i, step := range t.Steps {
+			long += fmt.Sprintf("  %d. %s: %s\n", i+1, step.Name, step.Command)
+		}
+	} else {
+		long += fmt.Sprintf("Command: %s\n", t.Command)
+	}
+	long += fmt.Sprintf("Source:  %s (%s)", t.Path, t.Scope)
+	for _, other := range overridden {
+		long += fmt.Sprintf("\nOverrides: %s (%s)", other.Path, other.Scope)
+	}
+	var global []string
+	for _, p := range t.Parameters {
+		if templates.FromGlobalFlag(p.Name) {
+			global = append(global, "--"+p.Name)
+		}
+	}
+	if len(global) > 0 {
+		long += "\nGlobal flags used as parameters: " + strings.Join(global, ", ")
+	}
+
+	short := t.Description
+	if short == "" && !t.IsRunbook() {
+		short = t.Command
+	}
+	c := &cobra.Command{
+		Use:               t.Name,
+		Short:             short,
+		Long:              long,
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: cobra.NoFileCompletions,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTemplate(cmd, t, overridden)
+		},
+	}
+
+	for _, p := range t.Parameters {
+		if templates.FromGlobalFlag(p.Name) {
+			continue
+		}
+		usage := parameterUsage(p)
+		switch p.Kind() {
+		case templates.TypeInt:
+			value, _ := strconv.Atoi(p.Default)
+			c.Flags().Int(p.Name, value, usage)
+		case templates.TypeBool:
+			value, _ := strconv.ParseBool(p.Default)
+			c.Flags().Bool(p.Name, value, usage)
+		default:
+			c.Flags().String(p.Name, p.Default, usage)
+		}
+
+		values := p.Values
+		c.RegisterFlagCompletionFunc(p.Name, func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			return values, cobra.ShellCompDirectiveNoFileComp
+		})
+	}
+	return c
+}
+
+// parameterUsage is the help text of a parameter's flag; defaults are
+// shown by the flag itself
+func parameterUsage(p templates.Parameter) string {
+	var details []string
+	switch p.Kind() {
+	case templates.TypeEnum:
+		details = append(details, "one of "+p.Hint())
+	case templates.TypeDuration, templates.TypeResourceName:
+		details = append(details, p.Kind())
+	}
+	if p.Required {
+		details = append(details, "required")
+	}
+	if p.Pattern != "" {
+		details = append(details, "matching "+p.Pattern)
+	}
+	if p.Sensitive {
+		details = append(details, "sensitive")
+	}
+
+	usage := p.Description
+	if len(details) > 0 {
+		usage = strings.TrimSpace(usage + " (" + strings.Join(details, ", ") + ")")
+	}
+	return usage
+}
+
+// confirmOverride shows where a project template comes from when it replaces
+// another definition, and asks before running it: project templates come
+// with the repository, so anyone with commit access can change them
+func confirmOverride(t *templates.Template, overridden []*templates.Template) bool {
+	if t.Scope != templates.ScopeProject || len(overridden) == 0 {
+		return true
+	}
+	fmt.Printf("⚠️ Warning: %s is the project template from %s, which overrides:\n", t.Name, t.Path)
+	for _, other := range overridden {
+		fmt.Printf("  %s (%s)\n", other.Path, other.Scope)
+	}
+	return confirm("Run the project template? [y/N]: ", "y")
+}
+
+func runTemplate(cmd *cobra.Command, selected *templates.Template, overridden []*templates.Template) error {
+	// Only flags set on the command line count as given, so defaults and
+	// prompting apply to the others
+	provided := make(map[string]string)
+	for _, p := range selected.Parameters {
+		if f := cmd.Flags().Lookup(p.Name); f != nil && f.Changed {
+			provided[p.Name] = f.Value.String()
+		}
+	}
+	params, err := selected.Resolve(provided, promptParameter)
+	if err != nil {
+		return err
+	}
+
+	ctx, _ := cliClient.GetContext()
+	builtins := templateBuiltins(cmd, ctx)
+
+	if selected.IsRunbook() {
+		printRunbook(selected, params, builtins, nil)
+		if cmd.Flag("dry-run").Value.String() == "true" {
+			fmt.Println("Dry run - runbook not executed")
+			return nil
+		}
+		if !confirmOverride(selected, overridden) {
+			fmt.Println("Runbook cancelled")
+			return nil
+		}
+		run := templates.NewRun(selected, activeTool, ctx["context"], runbookParams(selected, params))
+		return runRunbook(selected, params, builtins, run, ctx, cmd.Flag("yes").Value.String() == "true")
+	}
+
+	tmpl, err := templates.ParseCommand("command", selected.Command)
+	if err != nil {
+		return fmt.Errorf("error parsing template: %w", err)
+	}
+
+	generatedCmd, err := renderTemplate(tmpl, builtins.Data(params))
+	if err != nil {
+		return err
+	}
+	// Sensitive values are kept out of the terminal and the history
+	displayCmd := generatedCmd
+	if selected.Sensitive() {
+		if displayCmd, err = renderTemplate(tmpl, builtins.Data(selected.Mask(params))); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Generated command: %s %s\n", activeTool, displayCmd)
+
+	if cmd.Flag("dry-run").Value.String() == "true" {
+		fmt.Println("Dry run - command not executed")
+		return nil
+	}
+
+	entry := newHistoryEntry(SourceTemplate, displayCmd, ctx)
+	entry.Template, entry.Explanation = selected.Name, selected.Description
+	entry.Masked = selected.Sensitive()
+	entry.Safety = ai.AssessSafety(generatedCmd)
+	if !confirmOverride(selected, overridden) {
+		fmt.Println("Command not executed")
+		recordDeclined(entry)
+		return nil
+	}
+	// As for runbook steps, destructive commands are confirmed even with --yes
+	if entry.Safety >= 3 {
+		fmt.Printf("⚠️ Warning: This command may be destructive (Safety Level: %d/5)\n", entry.Safety)
+		if !confirm("Execute? [y/N]: ", "y") {
+			fmt.Println("Command not executed")
+			recordDeclined(entry)
+			return nil
+		}
+	}
+	output, err := cliClient.Execute(generatedCmd)
+	entry.SetOutcome(output, err)
+	if entry.Output != nil {
+		entry.Output.Excerpt = selected.Redact(entry.Output.Excerpt, params)
+	}
+	recordHistory(entry)
+	if err != nil {
+		return fmt.Errorf("error executing command: %v\nOutput: %s", err, output)
+	}
+
+	if output != "" {
+		fmt.Println("Command output:")
+		fmt.Println(output)
+	}
+
+	return nil
+}
+
+// templateBuiltins are the built-in variables of templates: the current kube
+// context, with the namespace given by --namespace if set
+func templateBuiltins(cmd *cobra.Command, ctx map[string]string) templates.Builtins {
+	namespace, _ := cmd.Flags().GetString("namespace")
+	return templates.NewBuiltins(activeTool, ctx, namespace)
+}
+
+func renderTemplate(tmpl *template.Template, params map[string]interface{}) (string, error) {
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return "", fmt.Errorf("error executing template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// promptParameter asks for a missing required parameter on a terminal,
+// without echo for sensitive ones
+func promptParameter(p templates.Parameter, invalid error) (string, bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", false, nil
+	}
+	if invalid != nil {
+		fmt.Printf("Invalid value: %v\n", invalid)
+	}
+
+	label := p.Name
+	if p.Description != "" {
+		label += " (" + p.Description + ")"
+	}
+	if hint := p.Hint(); hint != "" {
+		label += " [" + hint + "]"
+	}
+	fmt.Printf("%s: ", label)
+
+	if p.Sensitive {
+		value, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read %s: %w", p.Name, err)
+		}
+		return string(value), true, nil
+	}
+	value, err := stdin.ReadString('\n')
+	if err != nil && value == "" {
+		return "", false, fmt.Errorf("no value given for parameter %s", p.Name)
+	}
+	return strings.TrimSpace(value), true, nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"oc-ai/internal/ai"
+
+	"github.com/spf13/cobra"
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show AI token usage and budgets",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		days, _ := cmd.Flags().GetInt("days")
+
+		store, err := ai.OpenUsageStore()
+		if err != nil {
+			return fmt.Errorf("failed to open usage store: %w", err)
+		}
+
+		records, err := store.Records()
+		if err != nil {
+			return err
+		}
+
+		cutoff := time.Now().AddDate(0, 0, -days+1).Format("2006-01-02")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DAY\tMODEL\tCOMMAND\tREQUESTS\tPROMPT\tCOMPLETION\tTOTAL")
+		shown := 0
+		for _, r := range records {
+			if r.Day < cutoff {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%d\n",
+				r.Day, r.Model, r.Command, r.Requests, r.PromptTokens, r.CompletionTokens, r.TotalTokens)
+			shown++
+		}
+		if shown == 0 {
+			fmt.Println("No AI usage recorded.")
+		} else {
+			w.Flush()
+		}
+
+		today, err := store.DayTotal(time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nToday: %d tokens", today)
+		if cfg.DailyTokenBudget > 0 {
+			fmt.Printf(" of %d daily budget (%.0f%%)", cfg.DailyTokenBudget,
+				float64(today)*100/float64(cfg.DailyTokenBudget))
+		}
+		fmt.Println()
+		if cfg.SessionTokenBudget > 0 {
+			fmt.Printf("Session budget: %d tokens\n", cfg.SessionTokenBudget)
+		}
+		if cfg.DailyTokenBudget > 0 || cfg.SessionTokenBudget > 0 {
+			fmt.Printf("When exceeded: %s\n", cfg.BudgetAction)
+		}
+		return nil
+	},
+}
+
+func init() {
+	usageCmd.Flags().Int("days", 7, "Number of days to show")
+	rootCmd.AddCommand(usageCmd)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// The end-to-end tests run oc-ai in a child process replaying a cassette
+// from testdata/cassettes, so they need no cluster, CLI binary or API key.
+// Re-record a cassette after changing a prompt:
+//
+//	OC_AI_REPLAY=record OC_AI_CASSETTE=testdata/cassettes/ai-list-pods.json \
+//	  oc-ai ai "list the pods in my namespace" --yes
+func TestMain(m *testing.M) {
+	if os.Getenv("OC_AI_E2E") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// replay runs oc-ai with args against a cassette in a fresh config directory
+func replay(t *testing.T, cassette string, args ...string) (string, string, error) {
+	t.Helper()
+	home := t.TempDir()
+	output, err := replayIn(t, home, cassette, "", args...)
+	return output, home, err
+}
+
+// replayIn runs oc-ai with args against a cassette in an existing home,
+// with input as its standard input
+func replayIn(t *testing.T, home, cassette, input string, args ...string) (string, error) {
+	t.Helper()
+	path, err := filepath.Abs(filepath.Join("testdata", "cassettes", cassette))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runWithInput(home, []string{"OC_AI_REPLAY=replay", "OC_AI_CASSETTE=" + path}, input, args...)
+}
+
+// run runs oc-ai with args and extra environment variables, using home as
+// its home and config directory
+func run(home string, env []string, args ...string) (string, error) {
+	return runWithInput(home, env, "", args...)
+}
+
+// runWithInput is run with input as the standard input of oc-ai
+func runWithInput(home string, env []string, input string, args ...string) (string, error) {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = home
+	cmd.Env = append([]string{
+		"OC_AI_E2E=1",
+		"HOME=" + home,
+		"XDG_CONFIG_HOME=" + filepath.Join(home, ".config"),
+		"PATH=" + os.Getenv("PATH"),
+	}, env...)
+	cmd.Stdin = strings.NewReader(input)
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+func TestReplayAI(t *testing.T) {
+	output, home, err := replay(t, "ai-list-pods.json", "ai", "list the pods in my namespace", "--yes")
+	if err != nil {
+		t.Fatalf("oc-ai failed: %v\n%s", err, output)
+	}
+	for _, want := range []string{
+		"Command: oc get pods -n web",
+		"Safety Level: 1/5",
+		"web-7d4b9c6f5d-abcde   1/1     Running",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output is missing %q:\n%s", want, output)
+		}
+	}
+
+	history, err := os.ReadFile(filepath.Join(home, ".config", "oc-ai", "history.jsonl"))
+	if err != nil {
+		t.Fatalf("no history written: %v", err)
+	}
+	if !strings.Contains(string(history), `"command":"get pods -n web"`) {
+		t.Errorf("history doesn't record the command:\n%s", history)
+	}
+}
+
+func TestReplayAIUnrecordedPrompt(t *testing.T) {
+	output, _, err := replay(t, "ai-list-pods.json", "ai", "delete every pod", "--yes")
+	if err == nil {
+		t.Fatalf("oc-ai succeeded with a prompt missing from the cassette:\n%s", output)
+	}
+	if !strings.Contains(output, "replay: no recorded response") {
+		t.Errorf("unexpected error:\n%s", output)
+	}
+	if strings.Contains(output, "Command output:") {
+		t.Errorf("a command was executed:\n%s", output)
+	}
+}
+
+func TestReplayExplainStreaming(t *testing.T) {
+	output, _, err := replay(t, "explain-get-pods.json", "explain", "get pods -n web")
+	if err != nil {
+		t.Fatalf("oc-ai failed: %v\n%s", err, output)
+	}
+	for _, want := range []string{
+		"Command Explanation: oc get pods -n web",
+		"Namespace:  web",
+		// The explanation arrives in chunks and is printed as one text
+		"Lists the pods in the current namespace with their status.\nIt only reads from the cluster, so it is safe to run.",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output is missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestReplayInteractive(t *testing.T) {
+	// The first command is run; the second one follows cluster output, so it
+	// asks for "yes", and "n" declines it
+	home := t.TempDir()
+	input := "list the pods in my namespace\ny\nand again\nn\nexit\n"
+	output, err := replayIn(t, home, "interactive-list-pods.json", input, "interactive")
+	if err != nil {
+		t.Fatalf("oc-ai failed: %v\n%s", err, output)
+	}
+	for _, want := range []string{
+		"Command: oc get pods -n web",
+		"web-7d4b9c6f5d-abcde   1/1     Running",
+		"Execute? [yes/N/r (run/revise)]: Command not executed",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output is missing %q:\n%s", want, output)
+		}
+	}
+
+	history, err := os.ReadFile(filepath.Join(home, ".config", "oc-ai", "history.jsonl"))
+	if err != nil {
+		t.Fatalf("no history written: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(history)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"source":"interactive"`) || !strings.Contains(lines[1], `"declined":true`) {
+		t.Errorf("history doesn't record the executed and the declined command:\n%s", history)
+	}
+}
+
+func TestReplayTemplateRun(t *testing.T) {
+	home := t.TempDir()
+	dir := filepath.Join(home, ".config", "oc-ai", "templates")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	template := `name: list-pods
+description: List the pods of an app
+command: get pods -n {{.Namespace}} -l app={{.app}}
+parameters:
+  - name: app
+    required: true
+`
+	if err := os.WriteFile(filepath.Join(dir, "list-pods.yaml"), []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := replayIn(t, home, "template-run-list-pods.json", "", "template", "run", "list-pods", "--app", "web")
+	if err != nil {
+		t.Fatalf("oc-ai failed: %v\n%s", err, output)
+	}
+	for _, want := range []string{
+		"Generated command: oc get pods -n web -l app=web",
+		"fake oc: get pods -n web -l app=web",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output is missing %q:\n%s", want, output)
+		}
+	}
+
+	history, err := os.ReadFile(filepath.Join(home, ".config", "oc-ai", "history.jsonl"))
+	if err != nil {
+		t.Fatalf("no history written: %v", err)
+	}
+	if !strings.Contains(string(history), `"template":"list-pods"`) {
+		t.Errorf("history doesn't record the template:\n%s", history)
+	}
+}
+
+// evalEnv prepares a home for running eval against a fake model server: a
+// config pointing at baseURL, a dataset and a stand-in oc on PATH
+func evalEnv(t *testing.T, baseURL string) (home, dataset string, env []string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script as oc")
+	}
+	home = t.TempDir()
+	bin := filepath.Join(home, "bin")
+	configDir := filepath.Join(home, ".config", "oc-ai")
+	for _, dir := range []string{bin, configDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(bin, "oc"), []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := "default_model: test\ncache_enabled: false\n"
+	if baseURL != "" {
+		config += "openai_base_url: " + baseURL + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dataset = filepath.Join(home, "cases.jsonl")
+	cases := `{"prompt": "list pods in staging", "expected": "get pods -n staging", "safety": 1}
+{"prompt": "show the nodes", "expected": "get nodes", "safety": 1}
+`
+	if err := os.WriteFile(dataset, []byte(cases), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return home, dataset, []string{"PATH=" + bin + string(os.PathListSeparator) + os.Getenv("PATH")}
+}
+
+func TestEvalAgainstFakeModel(t *testing.T) {
+	// Answers the first case right and the second one wrong
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct{ Content string } `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		reply := "get nodes -o wide|||Lists nodes|||1"
+		if prompt := req.Messages[len(req.Messages)-1].Content; strings.Contains(prompt, "staging") {
+			reply = "get po --namespace=staging|||Lists pods|||1"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"model":"test","choices":[{"message":{"role":"assistant","content":%q},"finish_reason":"stop"}]}`, reply)
+	}))
+	defer server.Close()
+
+	home, dataset, env := evalEnv(t, server.URL+"/v1")
+	output, err := run(home, env, "eval", "--dataset", dataset, "--format", "json")
+	if err != nil {
+		t.Fatalf("oc-ai eval failed: %v\n%s", err, output)
+	}
+	var reports []struct {
+		Model   string `json:"model"`
+		Cases   int    `json:"cases"`
+		Matched int    `json:"matched"`
+		Errors  int    `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(output[strings.Index(output, "["):]), &reports); err != nil {
+		t.Fatalf("invalid report: %v\n%s", err, output)
+	}
+	if len(reports) != 1 || reports[0].Model != "test" || reports[0].Cases != 2 || reports[0].Matched != 1 || reports[0].Errors != 0 {
+		t.Errorf("unexpected report: %+v\n%s", reports, output)
+	}
+}
+
+func TestEvalWithoutModel(t *testing.T) {
+	home, dataset, env := evalEnv(t, "")
+	output, err := run(home, env, "eval", "--dataset", dataset)
+	if err == nil || !strings.Contains(output, "use --offline") {
+		t.Errorf("eval without a key or base URL didn't fail: %v\n%s", err, output)
+	}
+
+	output, err = run(home, env, "eval", "--dataset", dataset, "--offline", "--format", "markdown")
+	if err != nil {
+		t.Fatalf("oc-ai eval --offline failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(output, "| offline | 2 |") {
+		t.Errorf("offline results aren't labeled offline:\n%s", output)
+	}
+}
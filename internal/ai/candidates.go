@@ -0,0 +1,216 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"oc-ai/internal/cli"
+	"oc-ai/internal/config"
+	"oc-ai/internal/fsutil"
+)
+
+// maxCandidates bounds how many alternatives are requested from the model
+const maxCandidates = 5
+
+// Candidate is one of several commands generated for an ambiguous prompt,
+// with a local score explaining its rank
+type Candidate struct {
+	Command     string   `json:"command"`
+	Explanation string   `json:"explanation"`
+	Safety      string   `json:"safety"`
+	Score       int      `json:"score"`
+	Reasons     []string `json:"reasons,omitempty"`
+}
+
+// candidatesRule asks the model for several alternatives
+func candidatesRule(n int) string {
+	return fmt.Sprintf("The request may be ambiguous: respond with %d different candidate commands, one per line, "+
+		"each as COMMAND|||EXPLANATION|||SAFETY_LEVEL, most likely first. Use a single line per candidate.", n)
+}
+
+// parseCandidates reads one COMMAND|||EXPLANATION|||SAFETY line per candidate
+func parseCandidates(response string) []Candidate {
+	var candidates []Candidate
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(response, "\n") {
+		parts := strings.Split(line, "|||")
+		if len(parts) < 3 {
+			continue
+		}
+		command := strings.TrimSpace(parts[0])
+		// Models sometimes number their answers
+		command = strings.TrimSpace(strings.TrimLeft(command, "0123456789.)- "))
+		if command == "" || seen[command] {
+			continue
+		}
+		seen[command] = true
+		candidates = append(candidates, Candidate{
+			Command:     command,
+			Explanation: strings.TrimSpace(parts[1]),
+			Safety:      strings.TrimSpace(parts[2]),
+		})
+	}
+	return candidates
+}
+
+// rankCandidates scores the candidates of a result, sorts them best first
+// and makes the best one the result's command
+func rankCandidates(result *GenerateResult, prompt string, ctx map[string]string, resources *ResourceCatalog) {
+	if len(result.Candidates) == 0 {
+		return
+	}
+	for i := range result.Candidates {
+		scoreCandidate(&result.Candidates[i], prompt, ctx, resources)
+	}
+	sort.SliceStable(result.Candidates, func(i, j int) bool {
+		return result.Candidates[i].Score > result.Candidates[j].Score
+	})
+
+	best := result.Candidates[0]
+	result.Command, result.Explanation, result.Safety = best.Command, best.Explanation, best.Safety
+}
+
+// scoreCandidate favors commands that parse cleanly, use resource types that
+// exist, carry less risk and target the namespace the user means
+func scoreCandidate(c *Candidate, prompt string, ctx map[string]string, resources *ResourceCatalog) {
+	c.Score, c.Reasons = 0, nil
+	add := func(points int, reason string) {
+		c.Score += points
+		c.Reasons = append(c.Reasons, reason)
+	}
+
+	args := cli.ParseCommand(c.Command)
+	if balancedQuotes(c.Command) && len(PositionalArgs(args)) > 0 {
+		add(2, "parses cleanly")
+	} else {
+		add(-3, "does not parse")
+	}
+
+	if resources != nil {
+		var unknown *UnknownResourceError
+		if err := resources.ValidateCommand(c.Command); errors.As(err, &unknown) {
+			add(-5, fmt.Sprintf("unknown resource type %q", unknown.Resource))
+		} else if err == nil {
+			add(2, "valid resource types")
+		}
+	}
+
+	// Trust the more cautious of the model's and the local safety level
+	risk := AssessSafety(c.Command)
+	if level, err := strconv.Atoi(c.Safety); err == nil && level > risk {
+		risk = level
+	}
+	add(5-risk, fmt.Sprintf("risk %d/5", risk))
+
+	if ns := CommandNamespace(args); ns != "" {
+		switch {
+		case ns == ctx["namespace"]:
+			add(1, "current namespace")
+		case strings.Contains(strings.ToLower(prompt), strings.ToLower(ns)):
+			add(2, "namespace from prompt")
+		default:
+			add(-2, fmt.Sprintf("namespace %q not mentioned", ns))
+		}
+	}
+}
+
+func balancedQuotes(command string) bool {
+	var quote rune
+	for _, ch := range command {
+		switch {
+		case quote == 0 && (ch == '"' || ch == '\''):
+			quote = ch
+		case ch == quote:
+			quote = 0
+		}
+	}
+	return quote == 0
+}
+
+// CommandNamespace returns the value of -n/--namespace, if any
+func CommandNamespace(args []string) string {
+	for i, arg := range args {
+		switch {
+		case (arg == "-n" || arg == "--namespace") && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(arg, "--namespace="):
+			return strings.TrimPrefix(arg, "--namespace=")
+		case strings.HasPrefix(arg, "-n="):
+			return strings.TrimPrefix(arg, "-n=")
+		}
+	}
+	return ""
+}
+
+// ChoiceRecord stores which candidate the user picked. The prompt, expected
+// and safety fields make choices.jsonl usable as an eval dataset.
+type ChoiceRecord struct {
+	Time       time.Time         `json:"time"`
+	Prompt     string            `json:"prompt"`
+	Expected   string            `json:"expected"`
+	Safety     int               `json:"safety,omitempty"`
+	Model      string            `json:"model"`
+	Context    map[string]string `json:"context,omitempty"`
+	Chosen     int               `json:"chosen"`
+	Candidates []Candidate       `json:"candidates"`
+}
+
+// ChoicesPath returns the file candidate choices are appended to
+func ChoicesPath() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "choices.jsonl"), nil
+}
+
+// RecordChoice appends the user's pick among the candidates of a result
+func (c *Client) RecordChoice(prompt string, ctx map[string]string, result *GenerateResult, chosen int) error {
+	if chosen < 0 || chosen >= len(result.Candidates) {
+		return fmt.Errorf("invalid candidate %d", chosen+1)
+	}
+	pick := result.Candidates[chosen]
+	safety, _ := strconv.Atoi(pick.Safety)
+	rec := ChoiceRecord{
+		Time:       time.Now(),
+		Prompt:     prompt,
+		Expected:   pick.Command,
+		Safety:     safety,
+		Model:      c.model,
+		Context:    ctx,
+		Chosen:     chosen,
+		Candidates: result.Candidates,
+	}
+
+	path, err := ChoicesPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal choice: %w", err)
+	}
+
+	lock, err := fsutil.Lock(path)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open choices file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to record choice: %w", err)
+	}
+	return nil
+}
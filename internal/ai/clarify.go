@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// clarifyMarker starts a response asking the user for missing details
+const clarifyMarker = "CLARIFY"
+
+// Clarification is a question the model asks instead of guessing missing
+// details, e.g. which deployment to scale
+type Clarification struct {
+	Question string
+	// Choices are suggested answers
+	Choices []string
+	// ChoicesFrom names a resource type whose objects in the current
+	// namespace are the choices, e.g. "deployments"
+	ChoicesFrom string
+}
+
+// resourceTypePattern restricts ChoicesFrom to plain resource type names,
+// since it comes from the model and ends up in a CLI command
+var resourceTypePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*$`)
+
+// parseClarification parses a CLARIFY|||QUESTION|||CHOICES response
+func parseClarification(response string) (*Clarification, bool) {
+	response = strings.TrimSpace(response)
+	if !strings.HasPrefix(response, clarifyMarker+"|||") {
+		return nil, false
+	}
+
+	parts := strings.SplitN(response, "|||", 3)
+	c := &Clarification{Question: strings.TrimSpace(parts[1])}
+	if c.Question == "" {
+		return nil, false
+	}
+	if len(parts) < 3 {
+		return c, true
+	}
+
+	choices := strings.TrimSpace(parts[2])
+	if strings.HasPrefix(choices, "@") {
+		if t := strings.ToLower(strings.TrimPrefix(choices, "@")); resourceTypePattern.MatchString(t) {
+			c.ChoicesFrom = t
+		}
+		return c, true
+	}
+	for _, choice := range strings.Split(choices, ";") {
+		if choice = strings.TrimSpace(choice); choice != "" {
+			c.Choices = append(c.Choices, choice)
+		}
+	}
+	return c, true
+}
+
+// ClarifiedPrompt adds the user's answer to a clarifying question to the prompt
+func ClarifiedPrompt(prompt, question, answer string) string {
+	return fmt.Sprintf("%s\n(Asked: %s Answer: %s)", prompt, question, answer)
+}
+
+// ParseResourceNames extracts object names from "get <type> -o name" output
+func ParseResourceNames(output string) []string {
+	var names []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if idx := strings.LastIndex(line, "/"); idx >= 0 {
+			line = line[idx+1:]
+		}
+		names = append(names, line)
+	}
+	return names
+}
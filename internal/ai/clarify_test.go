@@ -0,0 +1,62 @@
+package ai
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseClarification(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     *Clarification
+	}{
+		{"choices", "CLARIFY|||Which deployment?|||web; api ;; worker", &Clarification{Question: "Which deployment?", Choices: []string{"web", "api", "worker"}}},
+		{"no choices", "  CLARIFY|||How many replicas?\n", &Clarification{Question: "How many replicas?"}},
+		{"empty choices", "CLARIFY|||How many replicas?|||", &Clarification{Question: "How many replicas?"}},
+		{"choices from a resource type", "CLARIFY|||Which deployment?|||@Deployments", &Clarification{Question: "Which deployment?", ChoicesFrom: "deployments"}},
+		{"qualified resource type", "CLARIFY|||Which route?|||@routes.route.openshift.io", &Clarification{Question: "Which route?", ChoicesFrom: "routes.route.openshift.io"}},
+		{"resource type with options", "CLARIFY|||Which pod?|||@pods -A", &Clarification{Question: "Which pod?"}},
+		{"resource type with a command", "CLARIFY|||Which pod?|||@pods;rm -rf /", &Clarification{Question: "Which pod?"}},
+		{"extra separators stay in the choices", "CLARIFY|||Scale web|||api?|||1;2", &Clarification{Question: "Scale web", Choices: []string{"api?|||1", "2"}}},
+		{"empty question", "CLARIFY||||||web", nil},
+		{"command", "get pods|||Lists pods|||1", nil},
+		{"marker in the text", "Not sure. CLARIFY|||Which one?", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseClarification(tt.response)
+			if ok != (tt.want != nil) {
+				t.Fatalf("parseClarification() ok = %v, want %v", ok, tt.want != nil)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseClarification() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseResourceNames(t *testing.T) {
+	tests := []struct {
+		output string
+		want   []string
+	}{
+		{"deployment.apps/web\ndeployment.apps/api\n", []string{"web", "api"}},
+		{"route.route.openshift.io/shop\n\n  pod/web-1  \n", []string{"shop", "web-1"}},
+		{"web\n", []string{"web"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseResourceNames(tt.output); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseResourceNames(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestClarifiedPrompt(t *testing.T) {
+	got := ClarifiedPrompt("scale the deployment to 3", "Which deployment?", "web")
+	if !strings.HasPrefix(got, "scale the deployment to 3\n") || !strings.HasSuffix(got, "(Asked: Which deployment? Answer: web)") {
+		t.Errorf("ClarifiedPrompt() = %q", got)
+	}
+}
@@ -2,7 +2,11 @@ package ai
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -11,10 +15,22 @@ import (
 )
 
 type Client struct {
-	client *openai.Client
-	tool   string
-	model  string
-	cache  *promptCache
+	apiKey    string
+	baseURL   string
+	tool      string
+	model     string
+	fallbacks []string
+	retry     RetryPolicy
+	cache     *promptCache
+	diskCache *DiskCache
+	offline   bool
+	usage     *usageTracker
+	redactor  *Redactor
+	transport func(HTTPDoer) HTTPDoer
+	prompts   *PromptRegistry
+
+	endpointsMu sync.Mutex
+	endpoints   map[string]*openai.Client
 }
 
 type promptCache struct {
@@ -26,6 +42,9 @@ type cachedResponse struct {
 	command     string
 	explanation string
 	safety      string
+	candidates  []Candidate
+	untrusted   bool
+	findings    []InjectionFinding
 	timestamp   time.Time
 }
 
@@ -33,117 +52,543 @@ const cacheDuration = 5 * time.Minute
 
 func NewClient(apiKey, tool, model string) *Client {
 	return &Client{
-		client: openai.NewClient(apiKey),
+		apiKey: apiKey,
 		tool:   tool,
 		model:  model,
+		retry:  DefaultRetryPolicy,
 		cache: &promptCache{
 			responses: make(map[string]cachedResponse),
 		},
+		offline:   apiKey == "",
+		prompts:   DefaultPrompts(nil),
+		endpoints: make(map[string]*openai.Client),
+	}
+}
+
+// SetPrompts replaces the built-in prompt templates, e.g. with a registry
+// that includes user overrides and organization instructions
+func (c *Client) SetPrompts(r *PromptRegistry) {
+	c.prompts = r
+}
+
+// SetBaseURL points the client at an OpenAI-compatible API other than api.openai.com
+func (c *Client) SetBaseURL(baseURL string) {
+	c.baseURL = baseURL
+	if baseURL != "" {
+		// Local OpenAI-compatible servers usually don't need a key
+		c.offline = false
 	}
 }
 
+// SetRetryPolicy configures retries, backoff and per-attempt timeouts
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// SetFallbackModels sets the models tried in order when the primary model
+// fails. A model may be given as "name@baseURL" to use another endpoint,
+// e.g. "llama3@http://localhost:11434/v1".
+func (c *Client) SetFallbackModels(models []string) {
+	c.fallbacks = models
+}
+
+// SetDiskCache enables the persistent cache shared between invocations
+func (c *Client) SetDiskCache(dc *DiskCache) {
+	c.diskCache = dc
+}
+
+// SetUsage records token usage of every response under the given oc-ai
+// command name and enforces budget before each AI call
+func (c *Client) SetUsage(store *UsageStore, budget Budget, command string) {
+	c.usage = &usageTracker{store: store, budget: budget, command: command}
+}
+
+// SetRedactor masks sensitive data in everything sent to the model and
+// restores the real values in responses
+func (c *Client) SetRedactor(r *Redactor) {
+	c.redactor = r
+}
+
+// HTTPDoer sends HTTP requests to the AI API
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// SetTransport wraps the HTTP client of every AI endpoint, e.g. to record
+// or replay requests
+func (c *Client) SetTransport(wrap func(HTTPDoer) HTTPDoer) {
+	c.transport = wrap
+}
+
+// SetOffline forces rule-based generation without contacting the model
+func (c *Client) SetOffline(offline bool) {
+	c.offline = c.offline || offline
+}
+
+// Offline reports whether commands are generated by local rules only,
+// either on request or because no API key or endpoint is configured
+func (c *Client) Offline() bool {
+	return c.offline
+}
+
+// GenerateRequest describes a single command generation call
+type GenerateRequest struct {
+	Prompt       string
+	Context      map[string]string
+	Conversation *Conversation
+	Resources    *ResourceCatalog
+	// Progress, if set, streams the response and is called with the text
+	// received so far. The result is still only returned once complete.
+	Progress func(partial string)
+	// Candidates asks for several alternative commands when greater than 1
+	Candidates int
+	// AllowClarification lets the model answer with a clarifying question
+	// instead of a command; the caller must then ask the user
+	AllowClarification bool
+	// AllowPlan lets the model answer with an ordered plan of commands
+	AllowPlan bool
+}
+
+// GenerateResult is the parsed model response for a GenerateRequest
+type GenerateResult struct {
+	Command     string
+	Explanation string
+	Safety      string
+	// Offline is set when the command was produced by local rules, not the model
+	Offline bool
+	// Untrusted is set when cluster data (e.g. command output) was part of the
+	// prompt; such commands always require explicit confirmation
+	Untrusted bool
+	// InjectionFindings lists instruction-like text found in that cluster data
+	InjectionFindings []InjectionFinding
+	// Candidates holds the ranked alternatives, best first, when several
+	// were requested; Command, Explanation and Safety are the best one
+	Candidates []Candidate
+	// Clarification is set instead of a command when the model needs more
+	// details; only returned if the request allows it
+	Clarification *Clarification
+	// Plan is set instead of a command when the request needs several
+	// commands; only returned if the request allows it
+	Plan *Plan
+}
+
 func (c *Client) GenerateCommand(prompt string, ctx map[string]string) (string, string, string, error) {
-	// Check cache first
-	cacheKey := fmt.Sprintf("%s:%s:%s:%s:%s",
+	result, err := c.Generate(context.Background(), GenerateRequest{Prompt: prompt, Context: ctx})
+	if err != nil {
+		return "", "", "", err
+	}
+	return result.Command, result.Explanation, result.Safety, nil
+}
+
+func (c *Client) Generate(apiCtx context.Context, req GenerateRequest) (*GenerateResult, error) {
+	prompt, ctx := req.Prompt, req.Context
+	if req.Candidates > maxCandidates {
+		req.Candidates = maxCandidates
+	}
+
+	if c.offline {
+		return GenerateOffline(prompt)
+	}
+
+	if c.redactor != nil {
+		c.redactor.ObserveContext(ctx)
+	}
+
+	var history []openai.ChatCompletionMessage
+	var untrusted bool
+	var findings []InjectionFinding
+	if req.Conversation != nil {
+		history = req.Conversation.messages()
+		untrusted, findings = req.Conversation.scanUntrusted()
+	}
+
+	// Check cache first; follow-up prompts depend on history so they are never cached
+	cacheKey := fmt.Sprintf("%s:%s:%s:%s:%s:%d",
 		prompt,
 		ctx["cluster"],
 		ctx["namespace"],
 		ctx["user"],
-		ctx["server"])
-
-	if resp, ok := c.cache.get(cacheKey); ok {
-		return resp.command, resp.explanation, resp.safety, nil
-	}
-
-	// Set timeout for API call
-	apiCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	systemPrompt := fmt.Sprintf(`You are an expert %s administrator.
-Current Context:
-- Cluster: %s
-- Namespace: %s
-- User: %s
-- Server: %s
-
-Rules:
-1. Respond ONLY with: COMMAND|||EXPLANATION|||SAFETY_LEVEL(1-5)
-2. SAFETY_LEVEL: 1=Safe, 3=Caution, 5=Dangerous
-3. Generate %s commands only
-4. Include all required flags
-5. Never include destructive commands without confirmation`,
-		strings.ToUpper(c.tool), ctx["cluster"], ctx["namespace"], ctx["user"], ctx["server"], c.tool)
-
-	resp, err := c.client.CreateChatCompletion(
-		apiCtx,
-		openai.ChatCompletionRequest{
-			Model: c.model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: systemPrompt,
+		ctx["server"],
+		req.Candidates)
+
+	diskKey := CacheKey(c.prompts.Version(TaskGenerate, c.tool), c.model, c.tool,
+		ctx["context"], ctx["cluster"], ctx["namespace"], ctx["user"], ctx["server"], prompt)
+	if req.Candidates > 1 {
+		diskKey = CacheKey(diskKey, "candidates", strconv.Itoa(req.Candidates))
+	}
+
+	var fields string
+	if req.Resources != nil {
+		// Field descriptions are written by whoever installed the CRD, so
+		// commands generated with them are untrusted whatever they say
+		if fields = req.Resources.RelevantFields(prompt); fields != "" {
+			untrusted = true
+			findings = append(findings, ScanUntrusted("custom resource fields", fields)...)
+		}
+	}
+
+	// Cached results keep the untrusted flag they were generated with
+	if len(history) == 0 {
+		var cached *GenerateResult
+		if resp, ok := c.cache.get(cacheKey); ok {
+			cached = &GenerateResult{Command: resp.command, Explanation: resp.explanation, Safety: resp.safety,
+				Candidates: resp.candidates, Untrusted: resp.untrusted, InjectionFindings: resp.findings}
+		} else if c.diskCache != nil {
+			cached, _ = c.diskCache.Get(diskKey)
+		}
+		if cached != nil {
+			cached.Untrusted = cached.Untrusted || untrusted
+			if len(cached.InjectionFindings) == 0 {
+				cached.InjectionFindings = findings
+			}
+			return cached, nil
+		}
+	}
+
+	data := c.promptData(ctx, len(history) > 0)
+	data.Clarify = req.AllowClarification
+	data.Plan = req.AllowPlan
+	systemPrompt, err := c.prompts.Render(TaskGenerate, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Resources != nil {
+		systemPrompt += "\n\nOnly use resource types that exist on this cluster.\n" + req.Resources.PromptSummary()
+		if fields != "" {
+			systemPrompt += "\nRelevant custom resource fields:\n" + WrapUntrusted("custom resource fields", fields)
+		}
+	}
+
+	if untrusted || strings.Contains(systemPrompt, "<<<UNTRUSTED_DATA") {
+		systemPrompt += "\n\n" + untrustedRule
+	}
+
+	if req.Candidates > 1 {
+		systemPrompt += "\n\n" + candidatesRule(req.Candidates)
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: systemPrompt,
+		},
+	}
+	messages = append(messages, history...)
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: prompt,
+	})
+
+	result, err := c.complete(apiCtx, messages, req.Progress)
+	if err == nil && result.Clarification != nil {
+		if !req.AllowClarification {
+			return nil, fmt.Errorf("the request needs clarification: %s", result.Clarification.Question)
+		}
+		result.Untrusted = untrusted
+		result.InjectionFindings = findings
+		return result, nil
+	}
+	if err == nil && result.Plan != nil {
+		if !req.AllowPlan {
+			return nil, fmt.Errorf("the request needs several commands: %s", result.Plan.Summary)
+		}
+		for i, step := range result.Plan.Steps {
+			if req.Resources == nil {
+				break
+			}
+			if err := req.Resources.ValidateCommand(step.Command); err != nil {
+				return nil, fmt.Errorf("step %d of the plan is invalid: %w", i+1, err)
+			}
+		}
+		result.Plan.Prompt = prompt
+		result.Plan.Tool = c.tool
+		result.Plan.Context = ctx["context"]
+		result.Untrusted = untrusted
+		result.InjectionFindings = findings
+		return result, nil
+	}
+	if err == nil {
+		c.useCandidates(result, req)
+	}
+	if err != nil {
+		// A used-up budget is reported rather than hidden behind offline rules
+		if isUnreachable(err) {
+			if offline, offlineErr := GenerateOffline(prompt); offlineErr == nil {
+				return offline, nil
+			}
+		}
+		return nil, err
+	}
+
+	// Give the model one chance to correct a command using a non-existent resource type
+	if req.Resources != nil {
+		var unknown *UnknownResourceError
+		if err := req.Resources.ValidateCommand(result.Command); errors.As(err, &unknown) {
+			messages = append(messages,
+				openai.ChatCompletionMessage{
+					Role:    openai.ChatMessageRoleAssistant,
+					Content: fmt.Sprintf("%s|||%s|||%s", result.Command, result.Explanation, result.Safety),
 				},
-				{
+				openai.ChatCompletionMessage{
 					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
+					Content: fmt.Sprintf("%s. Use one of the available resource types instead.", unknown.Error()),
 				},
-			},
-			Temperature: 0.3,
-		},
-	)
+			)
+			result, err = c.complete(apiCtx, messages, req.Progress)
+			if err != nil {
+				return nil, err
+			}
+			if result.Clarification != nil || result.Plan != nil {
+				return nil, fmt.Errorf("generated command is invalid: %w", unknown)
+			}
+			c.useCandidates(result, req)
+			if err := req.Resources.ValidateCommand(result.Command); err != nil {
+				return nil, fmt.Errorf("generated command is invalid: %w", err)
+			}
+		}
+	}
 
-	if err != nil {
-		return "", "", "", fmt.Errorf("AI error: %w", err)
+	result.Untrusted = untrusted
+	result.InjectionFindings = findings
+
+	// Cache the response
+	if len(history) == 0 {
+		c.cache.set(cacheKey, cachedResponse{
+			command:     result.Command,
+			explanation: result.Explanation,
+			safety:      result.Safety,
+			candidates:  result.Candidates,
+			untrusted:   result.Untrusted,
+			findings:    result.InjectionFindings,
+			timestamp:   time.Now(),
+		})
+		if c.diskCache != nil {
+			if err := c.diskCache.Set(diskKey, result); err != nil {
+				fmt.Printf("Warning: Failed to write prompt cache: %v\n", err)
+			}
+		}
 	}
 
-	if len(resp.Choices) == 0 {
-		return "", "", "", fmt.Errorf("no response from AI")
+	return result, nil
+}
+
+// complete sends the messages and parses a COMMAND|||EXPLANATION|||SAFETY response
+func (c *Client) complete(ctx context.Context, messages []openai.ChatCompletionMessage, progress func(string)) (*GenerateResult, error) {
+	req := openai.ChatCompletionRequest{
+		Messages:    messages,
+		Temperature: 0.3,
+	}
+
+	var response string
+	if progress != nil {
+		var partial strings.Builder
+		content, err := c.createChatCompletionStream(ctx, req, func(delta string) {
+			partial.WriteString(delta)
+			progress(c.restore(partial.String()))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("AI error: %w", err)
+		}
+		response = c.restore(content)
+	} else {
+		resp, err := c.createChatCompletion(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("AI error: %w", err)
+		}
+
+		if len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("no response from AI")
+		}
+		response = c.restore(resp.Choices[0].Message.Content)
+	}
+
+	if clarification, ok := parseClarification(response); ok {
+		return &GenerateResult{Clarification: clarification}, nil
+	}
+	if plan, ok := parsePlan(response); ok {
+		return &GenerateResult{Plan: plan}, nil
+	}
+
+	if candidates := parseCandidates(response); len(candidates) > 1 {
+		first := candidates[0]
+		return &GenerateResult{
+			Command:     first.Command,
+			Explanation: first.Explanation,
+			Safety:      first.Safety,
+			Candidates:  candidates,
+		}, nil
 	}
 
-	response := resp.Choices[0].Message.Content
 	parts := strings.Split(response, "|||")
 	if len(parts) < 3 {
-		return "", "", "", fmt.Errorf("invalid response format")
+		return nil, fmt.Errorf("invalid response format")
 	}
 
-	command := strings.TrimSpace(parts[0])
-	explanation := strings.TrimSpace(parts[1])
-	safety := strings.TrimSpace(parts[2])
+	return &GenerateResult{
+		Command:     strings.TrimSpace(parts[0]),
+		Explanation: strings.TrimSpace(parts[1]),
+		Safety:      strings.TrimSpace(parts[2]),
+	}, nil
+}
 
-	// Cache the response
-	c.cache.set(cacheKey, cachedResponse{
-		command:     command,
-		explanation: explanation,
-		safety:      safety,
-		timestamp:   time.Now(),
-	})
+// useCandidates ranks the candidates of a response when they were requested
+// and drops them otherwise
+func (c *Client) useCandidates(result *GenerateResult, req GenerateRequest) {
+	if req.Candidates <= 1 {
+		result.Candidates = nil
+		return
+	}
+	if len(result.Candidates) == 0 {
+		result.Candidates = []Candidate{{Command: result.Command, Explanation: result.Explanation, Safety: result.Safety}}
+	}
+	if len(result.Candidates) > req.Candidates {
+		result.Candidates = result.Candidates[:req.Candidates]
+	}
+	rankCandidates(result, req.Prompt, req.Context, req.Resources)
+}
+
+// createChatCompletion sends req to the primary model and then to each
+// fallback model in order until one succeeds.
+func (c *Client) createChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if c.usage != nil {
+		if err := c.usage.check(); err != nil {
+			return openai.ChatCompletionResponse{}, err
+		}
+	}
+
+	if c.redactor != nil {
+		req.Messages = c.redactor.redactMessages(req.Messages)
+	}
+
+	models := append([]string{c.model}, c.fallbacks...)
+
+	var lastErr error
+	for i, spec := range models {
+		name, endpoint := c.endpoint(spec)
+		req.Model = name
+
+		resp, err := endpoint.CreateChatCompletion(ctx, req)
+		if err == nil {
+			if c.usage != nil {
+				c.usage.record(name, resp.Usage)
+			}
+			return resp, nil
+		}
+
+		lastErr = classifyError(name, err)
+		if i == len(models)-1 || !shouldFallback(lastErr) {
+			break
+		}
+		fmt.Printf("Warning: %v; falling back to %s\n", lastErr, models[i+1])
+	}
+	return openai.ChatCompletionResponse{}, lastErr
+}
+
+// createChatCompletionStream streams the response to req, calling onDelta
+// with each fragment. Fallback models are only tried while nothing has been
+// streamed yet.
+func (c *Client) createChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest, onDelta func(string)) (string, error) {
+	if c.usage != nil {
+		if err := c.usage.check(); err != nil {
+			return "", err
+		}
+	}
+
+	if c.redactor != nil {
+		req.Messages = c.redactor.redactMessages(req.Messages)
+	}
+
+	req.Stream = true
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	models := append([]string{c.model}, c.fallbacks...)
+
+	var lastErr error
+	for i, spec := range models {
+		name, endpoint := c.endpoint(spec)
+		req.Model = name
+
+		content, err := c.stream(ctx, name, endpoint, req, onDelta)
+		if err == nil {
+			if content == "" {
+				return "", fmt.Errorf("no response from AI")
+			}
+			return content, nil
+		}
+
+		lastErr = classifyError(name, err)
+		if content != "" || i == len(models)-1 || !shouldFallback(lastErr) {
+			break
+		}
+		fmt.Printf("Warning: %v; falling back to %s\n", lastErr, models[i+1])
+	}
+	return "", lastErr
+}
+
+func (c *Client) stream(ctx context.Context, name string, endpoint *openai.Client, req openai.ChatCompletionRequest, onDelta func(string)) (string, error) {
+	stream, err := endpoint.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var content strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return content.String(), nil
+		}
+		if err != nil {
+			return content.String(), err
+		}
+
+		if chunk.Usage != nil && c.usage != nil {
+			c.usage.record(name, *chunk.Usage)
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				onDelta(choice.Delta.Content)
+			}
+		}
+	}
+}
+
+// endpoint resolves a "name[@baseURL]" model spec to its model name and API client
+func (c *Client) endpoint(spec string) (string, *openai.Client) {
+	name, baseURL := spec, c.baseURL
+	if idx := strings.Index(spec, "@"); idx >= 0 {
+		name, baseURL = spec[:idx], spec[idx+1:]
+	}
+
+	c.endpointsMu.Lock()
+	defer c.endpointsMu.Unlock()
 
-	return command, explanation, safety, nil
+	if client, ok := c.endpoints[baseURL]; ok {
+		return name, client
+	}
+
+	config := openai.DefaultConfig(c.apiKey)
+	if baseURL != "" {
+		config.BaseURL = baseURL
+	}
+	var doer HTTPDoer = newRetryDoer(c.retry)
+	if c.transport != nil {
+		doer = c.transport(doer)
+	}
+	config.HTTPClient = doer
+	client := openai.NewClientWithConfig(config)
+	c.endpoints[baseURL] = client
+	return name, client
 }
 
 func (c *Client) ExplainCommand(command string) (string, error) {
-	// Set timeout for API call
-	apiCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	resp, err := c.client.CreateChatCompletion(
-		apiCtx,
-		openai.ChatCompletionRequest{
-			Model: c.model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "Explain this command in simple terms. Include potential risks if any.",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: command,
-				},
-			},
-			Temperature: 0.7,
-		},
-	)
+	req, err := c.taskRequest(TaskExplain, command)
+	if err != nil {
+		return "", err
+	}
 
+	resp, err := c.createChatCompletion(context.Background(), req)
 	if err != nil {
 		return "", err
 	}
@@ -152,7 +597,84 @@ func (c *Client) ExplainCommand(command string) (string, error) {
 		return "", fmt.Errorf("no response from AI")
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	return c.restore(resp.Choices[0].Message.Content), nil
+}
+
+// ExplainCommandStream writes the explanation to w as it is generated and
+// returns the full text. Cancelling ctx aborts the request.
+func (c *Client) ExplainCommandStream(ctx context.Context, command string, w io.Writer) (string, error) {
+	req, err := c.taskRequest(TaskExplain, command)
+	if err != nil {
+		return "", err
+	}
+	return c.streamTo(ctx, req, w)
+}
+
+// Diagnose streams an analysis of a command's output to w and returns the
+// full text. The output is treated as untrusted cluster data.
+func (c *Client) Diagnose(ctx context.Context, command, output string, w io.Writer) (string, error) {
+	req, err := c.taskRequest(TaskDiagnose, "Command: "+command+"\n\nOutput:\n"+WrapUntrusted("output of "+command, output))
+	if err != nil {
+		return "", err
+	}
+	req.Messages[0].Content += "\n\n" + untrustedRule
+	return c.streamTo(ctx, req, w)
+}
+
+// streamTo writes the response to w as it arrives, restoring redacted values
+func (c *Client) streamTo(ctx context.Context, req openai.ChatCompletionRequest, w io.Writer) (string, error) {
+	if c.redactor == nil {
+		return c.createChatCompletionStream(ctx, req, func(delta string) {
+			fmt.Fprint(w, delta)
+		})
+	}
+
+	write, flush := c.redactor.restoreWriter(func(text string) {
+		fmt.Fprint(w, text)
+	})
+	content, err := c.createChatCompletionStream(ctx, req, write)
+	flush()
+	return c.restore(content), err
+}
+
+// restore maps redaction placeholders in a response back to real values
+func (c *Client) restore(text string) string {
+	if c.redactor == nil {
+		return text
+	}
+	return c.redactor.Restore(text)
+}
+
+// taskRequest builds a request with the task's system prompt and one user message
+func (c *Client) taskRequest(task, content string) (openai.ChatCompletionRequest, error) {
+	systemPrompt, err := c.prompts.Render(task, c.promptData(nil, false))
+	if err != nil {
+		return openai.ChatCompletionRequest{}, err
+	}
+	return openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: systemPrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: content,
+			},
+		},
+		Temperature: 0.7,
+	}, nil
+}
+
+func (c *Client) promptData(ctx map[string]string, conversation bool) PromptData {
+	return PromptData{
+		Tool:         c.tool,
+		Cluster:      ctx["cluster"],
+		Namespace:    ctx["namespace"],
+		User:         ctx["user"],
+		Server:       ctx["server"],
+		Conversation: conversation,
+	}
 }
 
 func (pc *promptCache) get(key string) (cachedResponse, bool) {
@@ -0,0 +1,198 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultMaxTurns     = 10
+	defaultMaxTokens    = 2000
+	outputSummaryLines  = 10
+	outputSummaryLength = 800
+)
+
+// Turn is a single prompt/command exchange in an interactive session
+type Turn struct {
+	Prompt      string
+	Command     string
+	Explanation string
+	Safety      string
+	Output      string
+	// Note is what oc-ai itself reports about the turn, e.g. that the
+	// command wasn't run. Unlike Output it isn't cluster data.
+	Note string
+}
+
+// Conversation keeps a bounded history of turns so follow-up prompts
+// like "now do the same for staging" have something to refer to.
+type Conversation struct {
+	mu        sync.Mutex
+	turns     []Turn
+	maxTurns  int
+	maxTokens int
+}
+
+func NewConversation(maxTurns, maxTokens int) *Conversation {
+	if maxTurns <= 0 {
+		maxTurns = defaultMaxTurns
+	}
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	return &Conversation{
+		maxTurns:  maxTurns,
+		maxTokens: maxTokens,
+	}
+}
+
+// Add records a new turn, dropping the oldest one once the limit is reached
+func (c *Conversation) Add(turn Turn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.turns = append(c.turns, turn)
+	if len(c.turns) > c.maxTurns {
+		c.turns = c.turns[len(c.turns)-c.maxTurns:]
+	}
+}
+
+// SetOutput attaches a summary of the command output to the latest turn
+func (c *Conversation) SetOutput(output string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.turns) == 0 {
+		return
+	}
+	c.turns[len(c.turns)-1].Output = SummarizeOutput(output)
+}
+
+// SetNote attaches a note of oc-ai to the latest turn
+func (c *Conversation) SetNote(note string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.turns) == 0 {
+		return
+	}
+	c.turns[len(c.turns)-1].Note = note
+}
+
+// SetCommand replaces the command of the latest turn, e.g. after a revision
+func (c *Conversation) SetCommand(command string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.turns) == 0 {
+		return
+	}
+	c.turns[len(c.turns)-1].Command = command
+}
+
+func (c *Conversation) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.turns = nil
+}
+
+func (c *Conversation) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.turns)
+}
+
+// messages renders the history as chat messages, dropping the oldest turns
+// until the estimated token count fits within maxTokens.
+func (c *Conversation) messages() []openai.ChatCompletionMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var rendered [][]openai.ChatCompletionMessage
+	total := 0
+	for i := len(c.turns) - 1; i >= 0; i-- {
+		msgs := renderTurn(c.turns[i])
+		tokens := 0
+		for _, m := range msgs {
+			tokens += estimateTokens(m.Content)
+		}
+		if total+tokens > c.maxTokens {
+			break
+		}
+		total += tokens
+		rendered = append(rendered, msgs)
+	}
+
+	var result []openai.ChatCompletionMessage
+	for i := len(rendered) - 1; i >= 0; i-- {
+		result = append(result, rendered[i]...)
+	}
+	return result
+}
+
+func renderTurn(t Turn) []openai.ChatCompletionMessage {
+	msgs := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: t.Prompt},
+		{Role: openai.ChatMessageRoleAssistant, Content: fmt.Sprintf("%s|||%s|||%s", t.Command, t.Explanation, t.Safety)},
+	}
+	if t.Output != "" {
+		msgs = append(msgs, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleUser,
+			Content: "Output of the previous command (summary):\n" + WrapUntrusted("output of "+t.Command, t.Output),
+		})
+	}
+	if t.Note != "" {
+		msgs = append(msgs, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: "Note: " + t.Note})
+	}
+	return msgs
+}
+
+// scanUntrusted reports whether the history contains cluster output and
+// any instruction-like text found in it
+func (c *Conversation) scanUntrusted() (bool, []InjectionFinding) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	untrusted := false
+	var findings []InjectionFinding
+	for _, t := range c.turns {
+		if t.Output == "" {
+			continue
+		}
+		untrusted = true
+		findings = append(findings, ScanUntrusted("output of "+t.Command, t.Output)...)
+	}
+	return untrusted, findings
+}
+
+// estimateTokens approximates the token count using ~4 characters per token
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// SummarizeOutput keeps the first lines of command output, bounded in length
+func SummarizeOutput(output string) string {
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return ""
+	}
+
+	lines := strings.Split(output, "\n")
+	truncated := false
+	if len(lines) > outputSummaryLines {
+		lines = lines[:outputSummaryLines]
+		truncated = true
+	}
+	summary := strings.Join(lines, "\n")
+	if len(summary) > outputSummaryLength {
+		summary = summary[:outputSummaryLength]
+		truncated = true
+	}
+	if truncated {
+		summary += "\n... (truncated)"
+	}
+	return summary
+}
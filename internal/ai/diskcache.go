@@ -0,0 +1,328 @@
+package ai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"oc-ai/internal/config"
+	"oc-ai/internal/fsutil"
+)
+
+const (
+	defaultDiskCacheTTL        = 24 * time.Hour
+	defaultDiskCacheMaxEntries = 1000
+	defaultDiskCacheMaxBytes   = 10 << 20
+	diskCacheEntrySuffix       = ".json"
+)
+
+// DiskCache persists generated commands across oc-ai invocations. Each entry
+// is its own file written atomically, so concurrent processes never read a
+// partial entry; eviction and hit counters are guarded by a lock file.
+// Hits and misses are counted in memory and written by Set and Flush, so a
+// lookup never writes to disk.
+type DiskCache struct {
+	dir        string
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int64
+
+	mu      sync.Mutex
+	pending diskCacheCounters
+}
+
+type diskCacheEntry struct {
+	CreatedAt   time.Time `json:"created_at"`
+	Command     string    `json:"command"`
+	Explanation string    `json:"explanation"`
+	Safety      string    `json:"safety"`
+	// Candidates are the ranked alternatives when several were requested
+	Candidates []Candidate `json:"candidates,omitempty"`
+	// Untrusted results keep requiring confirmation when served from cache
+	Untrusted         bool               `json:"untrusted,omitempty"`
+	InjectionFindings []InjectionFinding `json:"injection_findings,omitempty"`
+}
+
+type diskCacheCounters struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// CacheStats summarizes the contents of a DiskCache
+type CacheStats struct {
+	Dir        string
+	Entries    int
+	Expired    int
+	Bytes      int64
+	Oldest     time.Time
+	Newest     time.Time
+	Hits       int64
+	Misses     int64
+	TTL        time.Duration
+	MaxEntries int
+	MaxBytes   int64
+}
+
+// NewDiskCache opens the cache in the oc-ai config directory
+func NewDiskCache(ttl time.Duration, maxEntries int, maxBytes int64) (*DiskCache, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(configDir, "cache")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	if ttl <= 0 {
+		ttl = defaultDiskCacheTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultDiskCacheMaxEntries
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultDiskCacheMaxBytes
+	}
+
+	return &DiskCache{
+		dir:        dir,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+	}, nil
+}
+
+// CacheKey hashes the inputs that determine a generated command
+func CacheKey(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (dc *DiskCache) Get(key string) (*GenerateResult, bool) {
+	data, err := os.ReadFile(dc.entryPath(key))
+	if err != nil {
+		dc.count(false)
+		return nil, false
+	}
+
+	var entry diskCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || time.Since(entry.CreatedAt) > dc.ttl {
+		os.Remove(dc.entryPath(key))
+		dc.count(false)
+		return nil, false
+	}
+
+	dc.count(true)
+	return &GenerateResult{
+		Command:           entry.Command,
+		Explanation:       entry.Explanation,
+		Safety:            entry.Safety,
+		Candidates:        entry.Candidates,
+		Untrusted:         entry.Untrusted,
+		InjectionFindings: entry.InjectionFindings,
+	}, true
+}
+
+func (dc *DiskCache) Set(key string, result *GenerateResult) error {
+	data, err := json.Marshal(diskCacheEntry{
+		CreatedAt:         time.Now(),
+		Command:           result.Command,
+		Explanation:       result.Explanation,
+		Safety:            result.Safety,
+		Candidates:        result.Candidates,
+		Untrusted:         result.Untrusted,
+		InjectionFindings: result.InjectionFindings,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(dc.entryPath(key), data, 0600); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := dc.evict(); err != nil {
+		return err
+	}
+	return dc.Flush()
+}
+
+// Flush adds the hits and misses counted since the last flush to the
+// stored counters
+func (dc *DiskCache) Flush() error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if dc.pending == (diskCacheCounters{}) {
+		return nil
+	}
+
+	lock, err := fsutil.Lock(dc.countersPath())
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	counters := dc.loadCounters()
+	counters.Hits += dc.pending.Hits
+	counters.Misses += dc.pending.Misses
+	data, err := json.Marshal(counters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache stats: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(dc.countersPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache stats: %w", err)
+	}
+	dc.pending = diskCacheCounters{}
+	return nil
+}
+
+// Stats scans the cache directory
+func (dc *DiskCache) Stats() (CacheStats, error) {
+	stats := CacheStats{
+		Dir:        dc.dir,
+		TTL:        dc.ttl,
+		MaxEntries: dc.maxEntries,
+		MaxBytes:   dc.maxBytes,
+	}
+
+	files, err := dc.entries()
+	if err != nil {
+		return stats, err
+	}
+	for _, f := range files {
+		stats.Entries++
+		stats.Bytes += f.Size()
+		if time.Since(f.ModTime()) > dc.ttl {
+			stats.Expired++
+		}
+		if stats.Oldest.IsZero() || f.ModTime().Before(stats.Oldest) {
+			stats.Oldest = f.ModTime()
+		}
+		if f.ModTime().After(stats.Newest) {
+			stats.Newest = f.ModTime()
+		}
+	}
+
+	counters := dc.loadCounters()
+	dc.mu.Lock()
+	stats.Hits = counters.Hits + dc.pending.Hits
+	stats.Misses = counters.Misses + dc.pending.Misses
+	dc.mu.Unlock()
+	return stats, nil
+}
+
+// Clear removes every entry and resets the hit counters
+func (dc *DiskCache) Clear() (int, error) {
+	lock, err := fsutil.Lock(filepath.Join(dc.dir, "cache"))
+	if err != nil {
+		return 0, err
+	}
+	defer lock.Unlock()
+
+	files, err := dc.entries()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, f := range files {
+		if err := os.Remove(filepath.Join(dc.dir, f.Name())); err == nil {
+			removed++
+		}
+	}
+	os.Remove(dc.countersPath())
+	dc.mu.Lock()
+	dc.pending = diskCacheCounters{}
+	dc.mu.Unlock()
+	return removed, nil
+}
+
+// evict drops expired entries, then the oldest ones until the cache fits
+// within its entry and size limits.
+func (dc *DiskCache) evict() error {
+	lock, err := fsutil.Lock(filepath.Join(dc.dir, "cache"))
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	files, err := dc.entries()
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	var total int64
+	for _, f := range files {
+		total += f.Size()
+	}
+
+	count := len(files)
+	for _, f := range files {
+		expired := time.Since(f.ModTime()) > dc.ttl
+		if !expired && count <= dc.maxEntries && total <= dc.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(dc.dir, f.Name())); err == nil || os.IsNotExist(err) {
+			count--
+			total -= f.Size()
+		}
+	}
+	return nil
+}
+
+func (dc *DiskCache) entries() ([]os.FileInfo, error) {
+	dirEntries, err := os.ReadDir(dc.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	var files []os.FileInfo
+	for _, e := range dirEntries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), diskCacheEntrySuffix) || e.Name() == filepath.Base(dc.countersPath()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+	}
+	return files, nil
+}
+
+// count records a hit or miss until the next flush
+func (dc *DiskCache) count(hit bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if hit {
+		dc.pending.Hits++
+	} else {
+		dc.pending.Misses++
+	}
+}
+
+func (dc *DiskCache) loadCounters() diskCacheCounters {
+	var counters diskCacheCounters
+	if data, err := os.ReadFile(dc.countersPath()); err == nil {
+		json.Unmarshal(data, &counters)
+	}
+	return counters
+}
+
+func (dc *DiskCache) entryPath(key string) string {
+	return filepath.Join(dc.dir, key+diskCacheEntrySuffix)
+}
+
+func (dc *DiskCache) countersPath() string {
+	return filepath.Join(dc.dir, "stats.json")
+}
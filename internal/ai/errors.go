@@ -0,0 +1,138 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// AuthError means the API rejected the credentials (HTTP 401/403)
+type AuthError struct {
+	Model string
+	Err   error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("AI authentication failed for model %s: %v", e.Model, e.Err)
+}
+
+func (e *AuthError) Unwrap() error { return e.Err }
+
+// QuotaError means the account has run out of quota or credits
+type QuotaError struct {
+	Model string
+	Err   error
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("AI quota exceeded for model %s: %v", e.Model, e.Err)
+}
+
+func (e *QuotaError) Unwrap() error { return e.Err }
+
+// TimeoutError means the model did not answer in time, including retries
+type TimeoutError struct {
+	Model string
+	Err   error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("AI request to model %s timed out: %v", e.Model, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// UnavailableError means the API could not be reached, kept rate limiting,
+// or returned server errors after all retries. StatusCode is 0 for network errors.
+type UnavailableError struct {
+	Model      string
+	StatusCode int
+	Err        error
+}
+
+func (e *UnavailableError) Error() string {
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("AI service unavailable for model %s (HTTP %d): %v", e.Model, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("AI service unreachable for model %s: %v", e.Model, e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error { return e.Err }
+
+// classifyError maps errors from the OpenAI client onto the error types above.
+// Errors that don't fit (e.g. invalid requests) are returned unchanged.
+func classifyError(model string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &TimeoutError{Model: model, Err: err}
+	}
+
+	statusCode, code := 0, ""
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		statusCode = apiErr.HTTPStatusCode
+		code = fmt.Sprint(apiErr.Code) + " " + apiErr.Type
+	case errors.As(err, &reqErr):
+		statusCode = reqErr.HTTPStatusCode
+		code = string(reqErr.Body)
+	}
+
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return &AuthError{Model: model, Err: err}
+	case statusCode == http.StatusTooManyRequests && isQuotaCode(code):
+		return &QuotaError{Model: model, Err: err}
+	case statusCode == http.StatusTooManyRequests || statusCode >= 500:
+		return &UnavailableError{Model: model, StatusCode: statusCode, Err: err}
+	case statusCode != 0:
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return &TimeoutError{Model: model, Err: err}
+		}
+		return &UnavailableError{Model: model, Err: err}
+	}
+	return err
+}
+
+func isQuotaCode(code string) bool {
+	return strings.Contains(code, "insufficient_quota") || strings.Contains(code, "billing")
+}
+
+// isUnreachable reports whether err means no model could be reached at all
+// (timeout or network/server failure), as opposed to a rejected request.
+func isUnreachable(err error) bool {
+	var timeoutErr *TimeoutError
+	var unavailableErr *UnavailableError
+	return errors.As(err, &timeoutErr) || errors.As(err, &unavailableErr)
+}
+
+// shouldFallback reports whether the next model in the fallback chain should
+// be tried after err. Invalid requests and cancellation are final.
+func shouldFallback(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var authErr *AuthError
+	var quotaErr *QuotaError
+	if errors.As(err, &authErr) || errors.As(err, &quotaErr) || isUnreachable(err) {
+		return true
+	}
+
+	// Unknown model names on a fallback endpoint
+	var apiErr *openai.APIError
+	return errors.As(err, &apiErr) && apiErr.HTTPStatusCode == http.StatusNotFound
+}
@@ -0,0 +1,298 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"oc-ai/internal/cli"
+)
+
+// EvalCase is one line of an evaluation dataset
+type EvalCase struct {
+	Prompt string `json:"prompt"`
+	// Expected is the reference command; Alternatives are also accepted
+	Expected     string   `json:"expected"`
+	Alternatives []string `json:"alternatives,omitempty"`
+	// Safety is the expected safety level, 0 to skip scoring it
+	Safety  int               `json:"safety,omitempty"`
+	Context map[string]string `json:"context,omitempty"`
+}
+
+// EvalResult is the outcome of one case for one model
+type EvalResult struct {
+	Prompt         string        `json:"prompt"`
+	Expected       string        `json:"expected"`
+	Command        string        `json:"command"`
+	Match          bool          `json:"match"`
+	ExpectedSafety int           `json:"expected_safety,omitempty"`
+	Safety         int           `json:"safety,omitempty"`
+	Error          string        `json:"error,omitempty"`
+	Duration       time.Duration `json:"duration"`
+}
+
+// EvalReport summarizes a model's results on a dataset
+type EvalReport struct {
+	Model   string `json:"model"`
+	Cases   int    `json:"cases"`
+	Matched int    `json:"matched"`
+	Errors  int    `json:"errors"`
+	// SafetyScored counts cases with an expected safety level and a response
+	SafetyScored int           `json:"safety_scored"`
+	SafetyExact  int           `json:"safety_exact"`
+	SafetyNear   int           `json:"safety_within_one"`
+	AvgDuration  time.Duration `json:"avg_duration"`
+	Results      []EvalResult  `json:"results"`
+}
+
+// Accuracy is the share of cases whose command matched
+func (r *EvalReport) Accuracy() float64 {
+	return ratio(r.Matched, r.Cases)
+}
+
+// SafetyAgreement is the share of scored cases with the expected safety level
+func (r *EvalReport) SafetyAgreement() float64 {
+	return ratio(r.SafetyExact, r.SafetyScored)
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// LoadEvalCases reads a JSONL dataset, skipping blank lines and # comments
+func LoadEvalCases(path string) ([]EvalCase, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+	defer file.Close()
+
+	var cases []EvalCase
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var c EvalCase
+		if err := json.Unmarshal([]byte(text), &c); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid case: %w", path, line, err)
+		}
+		if c.Prompt == "" || c.Expected == "" {
+			return nil, fmt.Errorf("%s:%d: prompt and expected are required", path, line)
+		}
+		cases = append(cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+	return cases, nil
+}
+
+// RunEval generates a command for every case and compares it to the
+// expected one. progress, if set, is called after each case.
+func RunEval(ctx context.Context, client *Client, cases []EvalCase, progress func(done int, result EvalResult)) *EvalReport {
+	report := &EvalReport{Model: client.model, Cases: len(cases)}
+	if client.offline {
+		report.Model = "offline"
+	}
+	var total time.Duration
+
+	for i, c := range cases {
+		if ctx.Err() != nil {
+			report.Cases = i
+			break
+		}
+
+		result := EvalResult{Prompt: c.Prompt, Expected: c.Expected, ExpectedSafety: c.Safety}
+		start := time.Now()
+		generated, err := client.Generate(ctx, GenerateRequest{Prompt: c.Prompt, Context: c.Context})
+		result.Duration = time.Since(start)
+		total += result.Duration
+
+		// Falling back to local rules would score the rules, not the model
+		if err == nil && generated.Offline && !client.offline {
+			err = fmt.Errorf("model unavailable, offline fallback used")
+		}
+
+		if err != nil {
+			result.Error = err.Error()
+			report.Errors++
+		} else {
+			result.Command = generated.Command
+			result.Safety, _ = strconv.Atoi(strings.TrimSpace(generated.Safety))
+			for _, expected := range append([]string{c.Expected}, c.Alternatives...) {
+				if CommandsEquivalent(generated.Command, expected) {
+					result.Match = true
+					break
+				}
+			}
+			if result.Match {
+				report.Matched++
+			}
+			if c.Safety > 0 {
+				report.SafetyScored++
+				diff := result.Safety - c.Safety
+				if diff == 0 {
+					report.SafetyExact++
+				}
+				if diff >= -1 && diff <= 1 {
+					report.SafetyNear++
+				}
+			}
+		}
+
+		report.Results = append(report.Results, result)
+		if progress != nil {
+			progress(i+1, result)
+		}
+	}
+
+	if len(report.Results) > 0 {
+		report.AvgDuration = total / time.Duration(len(report.Results))
+	}
+	return report
+}
+
+// CommandsEquivalent reports whether two commands do the same thing,
+// ignoring flag order, short vs long flags and resource type aliases
+func CommandsEquivalent(a, b string) bool {
+	return NormalizeCommand(a) == NormalizeCommand(b)
+}
+
+// longFlags maps short flags to their long form
+var longFlags = map[string]string{
+	"-n": "--namespace", "-o": "--output", "-l": "--selector", "-f": "--filename",
+	"-c": "--container", "-A": "--all-namespaces", "-w": "--watch", "-p": "--patch",
+	"-R": "--recursive", "-i": "--stdin", "-t": "--tty",
+}
+
+// logsFlags overrides short flags whose meaning differs for logs
+var logsFlags = map[string]string{
+	"-f": "--follow", "-p": "--previous", "-c": "--container",
+}
+
+// resourceAliases maps short and singular resource names to the plural form
+var resourceAliases = map[string]string{
+	"po": "pods", "pod": "pods",
+	"deploy": "deployments", "deployment": "deployments",
+	"svc": "services", "service": "services",
+	"ns": "namespaces", "namespace": "namespaces",
+	"no": "nodes", "node": "nodes",
+	"cm": "configmaps", "configmap": "configmaps",
+	"secret": "secrets",
+	"route":  "routes",
+	"rs":     "replicasets", "replicaset": "replicasets",
+	"sts": "statefulsets", "statefulset": "statefulsets",
+	"ds": "daemonsets", "daemonset": "daemonsets",
+	"pvc": "persistentvolumeclaims", "persistentvolumeclaim": "persistentvolumeclaims",
+	"pv": "persistentvolumes", "persistentvolume": "persistentvolumes",
+	"sa": "serviceaccounts", "serviceaccount": "serviceaccounts",
+	"ing": "ingresses", "ingress": "ingresses",
+	"ev": "events", "event": "events",
+	"dc": "deploymentconfigs", "deploymentconfig": "deploymentconfigs",
+	"is": "imagestreams", "imagestream": "imagestreams",
+	"bc": "buildconfigs", "buildconfig": "buildconfigs",
+	"job": "jobs", "cj": "cronjobs", "cronjob": "cronjobs",
+	"project": "projects",
+}
+
+// NormalizeCommand rewrites a command into a canonical form: the tool name
+// is dropped, flags use their long form with "=" values and are sorted after
+// the positional arguments, and "type/name" targets are split with the
+// resource type in its plural form.
+func NormalizeCommand(command string) string {
+	args := cli.ParseCommand(strings.TrimSpace(command))
+	if len(args) > 0 && (args[0] == "oc" || args[0] == "kubectl") {
+		args = args[1:]
+	}
+
+	verb := ""
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			verb = arg
+			break
+		}
+	}
+
+	var positional, flags []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		if verb == "logs" && logsFlags[name] != "" {
+			name = logsFlags[name]
+		} else if long, ok := longFlags[name]; ok {
+			name = long
+		}
+		if !hasValue && valueFlags[name] && i+1 < len(args) {
+			value, hasValue = args[i+1], true
+			i++
+		}
+		if !hasValue || value == "true" {
+			flags = append(flags, name)
+		} else {
+			flags = append(flags, name+"="+value)
+		}
+	}
+
+	positional = normalizeTargets(positional)
+	sort.Strings(flags)
+	return strings.Join(append(positional, flags...), " ")
+}
+
+// normalizeTargets canonicalizes the resource type argument of a command
+func normalizeTargets(positional []string) []string {
+	if len(positional) < 2 {
+		return positional
+	}
+
+	idx := -1
+	switch verb := positional[0]; {
+	case verb == "rollout" && len(positional) >= 3:
+		idx = 2
+	case resourceVerbs[verb] || verb == "logs":
+		idx = 1
+	}
+	if idx < 0 {
+		return positional
+	}
+
+	target := positional[idx]
+	var rest []string
+	if typ, name, ok := strings.Cut(target, "/"); ok && !strings.Contains(target, ",") {
+		target, rest = typ, []string{name}
+	} else if positional[0] == "logs" {
+		// logs takes a pod name unless given as type/name
+		return positional
+	}
+
+	types := strings.Split(target, ",")
+	for i, t := range types {
+		if alias, ok := resourceAliases[strings.ToLower(t)]; ok {
+			types[i] = alias
+		}
+	}
+
+	normalized := append([]string{}, positional[:idx]...)
+	// "logs pod/web" is the same as "logs web"
+	if !(positional[0] == "logs" && len(types) == 1 && types[0] == "pods") {
+		normalized = append(normalized, strings.Join(types, ","))
+	}
+	normalized = append(normalized, rest...)
+	return append(normalized, positional[idx+1:]...)
+}
@@ -0,0 +1,56 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRunEval(t *testing.T) {
+	server := newFakeModelServer(t, map[string][]fakeReply{"model": {{status: http.StatusBadRequest}}})
+	server.byPrompt = map[string]fakeReply{
+		"list pods in staging": {content: "get po --namespace=staging|||Lists pods|||1"},
+		"restart api":          {content: "oc rollout restart deployment/api|||Restarts api|||2"},
+		"clean up":             {content: "delete pods --all|||Deletes pods|||5"},
+	}
+	cases := []EvalCase{
+		{Prompt: "list pods in staging", Expected: "get pods -n staging", Safety: 1},
+		{Prompt: "restart api", Expected: "rollout restart deploy api", Alternatives: []string{"rollout restart deployment/api"}, Safety: 3},
+		{Prompt: "clean up", Expected: "delete pod old", Safety: 4},
+		{Prompt: "scale web", Expected: "scale deployment web --replicas=2"},
+	}
+
+	report := RunEval(context.Background(), testClient(server.URL, "model", 0), cases, nil)
+
+	if report.Model != "model" || report.Cases != 4 || report.Matched != 2 || report.Errors != 1 {
+		t.Errorf("report = %s/%d cases, %d matched, %d errors; want model/4, 2, 1",
+			report.Model, report.Cases, report.Matched, report.Errors)
+	}
+	if report.SafetyScored != 3 || report.SafetyExact != 1 || report.SafetyNear != 3 {
+		t.Errorf("safety scored %d, exact %d, near %d; want 3, 1, 3", report.SafetyScored, report.SafetyExact, report.SafetyNear)
+	}
+	want := []bool{true, true, false, false}
+	for i, result := range report.Results {
+		if result.Match != want[i] {
+			t.Errorf("%q: match = %v, want %v (got %q, error %q)", result.Prompt, result.Match, want[i], result.Command, result.Error)
+		}
+	}
+}
+
+func TestRunEvalOffline(t *testing.T) {
+	cases := []EvalCase{{Prompt: "list pods", Expected: "get pods"}}
+
+	// A model that can't be reached must not be scored with the local rules
+	server := newFakeModelServer(t, map[string][]fakeReply{"model": {{status: http.StatusServiceUnavailable}}})
+	report := RunEval(context.Background(), testClient(server.URL, "model", 0), cases, nil)
+	if report.Errors != 1 || !strings.Contains(report.Results[0].Error, "offline fallback") {
+		t.Errorf("unreachable model: %+v", report.Results)
+	}
+
+	// The local rules on request are reported as such
+	report = RunEval(context.Background(), NewClient("", "oc", "model"), cases, nil)
+	if report.Model != "offline" || report.Errors != 0 {
+		t.Errorf("offline client: model %q, %d errors", report.Model, report.Errors)
+	}
+}
@@ -0,0 +1,272 @@
+package ai
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const offlineLabel = "[offline]"
+
+// offline resource aliases mapped to the canonical resource type
+var offlineResources = map[string]string{
+	"pod": "pods", "pods": "pods", "po": "pods",
+	"deployment": "deployments", "deployments": "deployments", "deploy": "deployments",
+	"route": "routes", "routes": "routes",
+	"service": "services", "services": "services", "svc": "services",
+}
+
+// words that can follow a resource type without being its name
+var offlineStopWords = map[string]bool{
+	"in": true, "to": true, "with": true, "for": true, "from": true, "the": true,
+	"of": true, "on": true, "and": true, "all": true, "that": true, "which": true,
+	"named": true, "called": true, "replicas": true, "logs": true, "log": true,
+	"events": true, "please": true, "namespace": true, "project": true, "a": true,
+	"is": true, "are": true, "back": true, "now": true, "across": true, "by": true,
+	"using": true, "at": true, "where": true, "whose": true, "my": true, "our": true,
+	"its": true, "their": true, "running": true, "failing": true, "every": true,
+	"restart": true, "rollout": true, "redeploy": true, "bounce": true, "scale": true,
+	"resize": true, "describe": true, "details": true, "detail": true, "inspect": true,
+	"list": true, "show": true, "get": true, "display": true, "what": true, "me": true,
+}
+
+var (
+	offlineWordPattern      = regexp.MustCompile(`-*[a-z0-9][a-z0-9./-]*`)
+	offlineNamedPattern     = regexp.MustCompile(`(?:named|called)\s+["']?([a-z0-9][a-z0-9.-]*)`)
+	offlineNamespacePattern = []*regexp.Regexp{
+		regexp.MustCompile(`(?:in|from|of)\s+(?:the\s+)?(?:namespace|project)\s+["']?([a-z0-9][a-z0-9-]*)`),
+		regexp.MustCompile(`(?:in|from|of)\s+(?:the\s+)?["']?([a-z0-9][a-z0-9-]*)["']?\s+(?:namespace|project)`),
+		regexp.MustCompile(`(?:-n|--namespace)[\s=]+([a-z0-9][a-z0-9-]*)`),
+	}
+	offlineReplicasPattern = []*regexp.Regexp{
+		regexp.MustCompile(`(\d+)\s+replicas?`),
+		regexp.MustCompile(`(?:to|=)\s*(\d+)`),
+	}
+	offlineTailPattern = regexp.MustCompile(`last\s+(\d+)\s+lines?`)
+)
+
+// offlineRequest is what the rule-based matcher extracted from a prompt
+type offlineRequest struct {
+	intent        string
+	resource      string
+	name          string
+	namespace     string
+	allNamespaces bool
+	replicas      int
+	tail          int
+	previous      bool
+}
+
+// GenerateOffline turns a prompt into a command using deterministic rules.
+// It covers the most common requests so oc-ai stays useful when no model is
+// reachable; anything else returns an error.
+func GenerateOffline(prompt string) (*GenerateResult, error) {
+	req, err := parseOfflineRequest(prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	command, explanation, err := req.build()
+	if err != nil {
+		return nil, err
+	}
+
+	return &GenerateResult{
+		Command:     command,
+		Explanation: offlineLabel + " " + explanation,
+		Safety:      strconv.Itoa(AssessSafety(command)),
+		Offline:     true,
+	}, nil
+}
+
+func parseOfflineRequest(prompt string) (*offlineRequest, error) {
+	text := strings.ToLower(prompt)
+	words := offlineWordPattern.FindAllString(text, -1)
+	req := &offlineRequest{replicas: -1}
+
+	has := func(keywords ...string) bool {
+		for _, w := range words {
+			for _, k := range keywords {
+				if w == k {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	switch {
+	case has("restart", "rollout", "redeploy", "bounce"):
+		req.intent = "restart"
+	case has("scale", "resize"):
+		req.intent = "scale"
+	case has("log", "logs"):
+		req.intent = "logs"
+	case has("event", "events"):
+		req.intent = "events"
+	case has("describe", "details", "detail", "inspect"):
+		req.intent = "describe"
+	case has("list", "show", "get", "display", "what", "which"):
+		req.intent = "list"
+	}
+
+	isName := func(i int) bool {
+		if i < 0 || i >= len(words) {
+			return false
+		}
+		w := words[i]
+		return !strings.HasPrefix(w, "-") && !offlineStopWords[w] && offlineResources[w] == ""
+	}
+
+	// Resource type, and the name around it (pod web-1, the web deployment, deployment/web)
+	for i, w := range words {
+		if idx := strings.Index(w, "/"); idx > 0 {
+			if r, ok := offlineResources[w[:idx]]; ok {
+				req.resource, req.name = r, w[idx+1:]
+				break
+			}
+		}
+		if r, ok := offlineResources[w]; ok {
+			req.resource = r
+			switch {
+			case isName(i + 1):
+				req.name = words[i+1]
+			case isName(i - 1):
+				req.name = words[i-1]
+			}
+			break
+		}
+	}
+	if m := offlineNamedPattern.FindStringSubmatch(text); m != nil {
+		req.name = m[1]
+	}
+
+	for _, p := range offlineNamespacePattern {
+		if m := p.FindStringSubmatch(text); m != nil && m[1] != "all" && m[1] != "this" && m[1] != "current" {
+			req.namespace = m[1]
+			break
+		}
+	}
+	req.allNamespaces = strings.Contains(text, "all namespaces") || strings.Contains(text, "all projects")
+
+	for _, p := range offlineReplicasPattern {
+		if m := p.FindStringSubmatch(text); m != nil {
+			req.replicas, _ = strconv.Atoi(m[1])
+			break
+		}
+	}
+	if m := offlineTailPattern.FindStringSubmatch(text); m != nil {
+		req.tail, _ = strconv.Atoi(m[1])
+	}
+	req.previous = has("previous", "crashed", "prior")
+
+	if req.intent == "" {
+		if req.resource == "" {
+			return nil, fmt.Errorf("no AI model available and the prompt was not understood offline; " +
+				"offline mode supports listing, describing, logs, events, scaling and restarting pods, deployments, routes and services")
+		}
+		req.intent = "list"
+	}
+
+	// Defaults that keep common phrasings working
+	switch req.intent {
+	case "restart", "scale":
+		if req.resource == "" || req.resource == "pods" {
+			req.resource = "deployments"
+		}
+	case "logs":
+		if req.resource == "" {
+			req.resource = "pods"
+		}
+	}
+
+	return req, nil
+}
+
+func (r *offlineRequest) build() (string, string, error) {
+	var args []string
+	var explanation string
+	singular := strings.TrimSuffix(r.resource, "s")
+
+	switch r.intent {
+	case "list":
+		if r.resource == "" {
+			return "", "", fmt.Errorf("offline mode needs a resource type to list (pods, deployments, routes or services)")
+		}
+		args = []string{"get", r.resource}
+		explanation = fmt.Sprintf("Lists %s", r.resource)
+		if r.name != "" {
+			args = append(args, r.name)
+			explanation = fmt.Sprintf("Shows the %s %s", singular, r.name)
+		}
+
+	case "describe":
+		if r.resource == "" {
+			return "", "", fmt.Errorf("offline mode needs a resource type to describe")
+		}
+		args = []string{"describe", r.resource}
+		explanation = fmt.Sprintf("Describes %s", r.resource)
+		if r.name != "" {
+			args = append(args, r.name)
+			explanation = fmt.Sprintf("Describes the %s %s", singular, r.name)
+		}
+
+	case "logs":
+		if r.name == "" {
+			return "", "", fmt.Errorf("offline mode needs a pod or deployment name to show logs")
+		}
+		target := r.name
+		if r.resource != "pods" {
+			target = singular + "/" + r.name
+		}
+		args = []string{"logs", target}
+		explanation = fmt.Sprintf("Shows logs of %s", target)
+		if r.tail > 0 {
+			args = append(args, fmt.Sprintf("--tail=%d", r.tail))
+		}
+		if r.previous {
+			args = append(args, "--previous")
+			explanation += " from the previous container instance"
+		}
+
+	case "events":
+		args = []string{"get", "events", "--sort-by=.lastTimestamp"}
+		explanation = "Lists recent events sorted by time"
+		if r.name != "" {
+			args = append(args, "--field-selector=involvedObject.name="+r.name)
+			explanation = fmt.Sprintf("Lists events for %s sorted by time", r.name)
+		}
+
+	case "scale":
+		if r.name == "" || r.replicas < 0 {
+			return "", "", fmt.Errorf("offline mode needs a deployment name and replica count to scale")
+		}
+		if r.resource != "deployments" {
+			return "", "", fmt.Errorf("offline mode can only scale deployments")
+		}
+		args = []string{"scale", "deployment", r.name, fmt.Sprintf("--replicas=%d", r.replicas)}
+		explanation = fmt.Sprintf("Scales deployment %s to %d replicas", r.name, r.replicas)
+
+	case "restart":
+		if r.name == "" {
+			return "", "", fmt.Errorf("offline mode needs a deployment name to restart")
+		}
+		if r.resource != "deployments" {
+			return "", "", fmt.Errorf("offline mode can only restart deployments")
+		}
+		args = []string{"rollout", "restart", "deployment/" + r.name}
+		explanation = fmt.Sprintf("Restarts the pods of deployment %s with a rolling update", r.name)
+	}
+
+	switch {
+	case r.allNamespaces && r.intent != "scale" && r.intent != "restart" && r.intent != "logs":
+		args = append(args, "--all-namespaces")
+		explanation += " across all namespaces"
+	case r.namespace != "":
+		args = append(args, "-n", r.namespace)
+		explanation += " in namespace " + r.namespace
+	}
+
+	return strings.Join(args, " "), explanation, nil
+}
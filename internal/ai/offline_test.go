@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateOffline(t *testing.T) {
+	tests := []struct {
+		prompt  string
+		command string
+		safety  string
+		err     string
+	}{
+		{prompt: "list pods", command: "get pods", safety: "1"},
+		{prompt: "show me the pods in namespace staging", command: "get pods -n staging", safety: "1"},
+		{prompt: "get deployments in the prod project", command: "get deployments -n prod", safety: "1"},
+		{prompt: "list services across all namespaces", command: "get services --all-namespaces", safety: "1"},
+		{prompt: "show pod web-1 -n shop", command: "get pods web-1 -n shop", safety: "1"},
+		{prompt: "routes", command: "get routes", safety: "1"},
+		{prompt: "describe deployment/api", command: "describe deployments api", safety: "1"},
+		{prompt: "inspect the web deployment", command: "describe deployments web", safety: "1"},
+		{prompt: "logs of pod web-1, last 50 lines", command: "logs web-1 --tail=50", safety: "1"},
+		{prompt: "logs of the crashed pod named api-2", command: "logs api-2 --previous", safety: "1"},
+		{prompt: "logs of deployment api in namespace shop", command: "logs deployment/api -n shop", safety: "1"},
+		{prompt: "events", command: "get events --sort-by=.lastTimestamp", safety: "1"},
+		{prompt: "events for pod web-1", command: "get events --sort-by=.lastTimestamp --field-selector=involvedObject.name=web-1", safety: "1"},
+		{prompt: "scale deployment web to 3 replicas", command: "scale deployment web --replicas=3", safety: "3"},
+		{prompt: "scale the web deployment to 0", command: "scale deployment web --replicas=0", safety: "3"},
+		{prompt: "scale web to 2", err: "needs a deployment name and replica count"},
+		{prompt: "restart the api deployment in namespace shop", command: "rollout restart deployment/api -n shop", safety: "3"},
+		{prompt: "bounce pod web", command: "rollout restart deployment/web", safety: "3"},
+		{prompt: "delete everything", err: "not understood offline"},
+		{prompt: "list", err: "needs a resource type to list"},
+		{prompt: "logs please", err: "needs a pod or deployment name"},
+		{prompt: "scale deployment web", err: "needs a deployment name and replica count"},
+		{prompt: "scale service web to 2", err: "can only scale deployments"},
+		{prompt: "restart route web", err: "can only restart deployments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prompt, func(t *testing.T) {
+			result, err := GenerateOffline(tt.prompt)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Command != tt.command {
+				t.Errorf("command = %q, want %q", result.Command, tt.command)
+			}
+			if result.Safety != tt.safety {
+				t.Errorf("safety = %s, want %s", result.Safety, tt.safety)
+			}
+			if !result.Offline || !strings.HasPrefix(result.Explanation, offlineLabel+" ") {
+				t.Errorf("result isn't labeled offline: %+v", result)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package ai
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"oc-ai/internal/config"
+	"oc-ai/internal/fsutil"
+)
+
+// Markers of a response describing several commands to run in order
+const (
+	planMarker = "PLAN"
+	stepMarker = "STEP"
+)
+
+const (
+	StepPending   = "pending"
+	StepSucceeded = "succeeded"
+	StepFailed    = "failed"
+	StepSkipped   = "skipped"
+)
+
+// maxStepOutput bounds the output stored for each step of a plan
+const maxStepOutput = 4096
+
+// Plan is an ordered list of commands generated for a single request. It is
+// checkpointed after every step so a partially executed plan can be resumed.
+type Plan struct {
+	ID      string `json:"id"`
+	Prompt  string `json:"prompt"`
+	Summary string `json:"summary"`
+	Tool    string `json:"tool"`
+	Context string `json:"context,omitempty"`
+	// Untrusted plans were generated from cluster data and keep requiring
+	// confirmation of every step when resumed
+	Untrusted bool       `json:"untrusted,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Steps     []PlanStep `json:"steps"`
+
+	path string
+}
+
+// PlanStep is one command of a plan with its execution state
+type PlanStep struct {
+	Command     string     `json:"command"`
+	Explanation string     `json:"explanation"`
+	Safety      string     `json:"safety"`
+	Status      string     `json:"status"`
+	Output      string     `json:"output,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// parsePlan parses a PLAN|||SUMMARY line followed by
+// STEP|||COMMAND|||EXPLANATION|||SAFETY lines
+func parsePlan(response string) (*Plan, bool) {
+	lines := strings.Split(strings.TrimSpace(response), "\n")
+	if len(lines) == 0 || !strings.HasPrefix(strings.TrimSpace(lines[0]), planMarker+"|||") {
+		return nil, false
+	}
+
+	plan := &Plan{Summary: strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[0]), planMarker+"|||"))}
+	for _, line := range lines[1:] {
+		parts := strings.Split(strings.TrimSpace(line), "|||")
+		if len(parts) < 4 || parts[0] != stepMarker {
+			continue
+		}
+		plan.Steps = append(plan.Steps, PlanStep{
+			Command:     strings.TrimSpace(parts[1]),
+			Explanation: strings.TrimSpace(parts[2]),
+			Safety:      strings.TrimSpace(parts[3]),
+			Status:      StepPending,
+		})
+	}
+	if len(plan.Steps) == 0 {
+		return nil, false
+	}
+	return plan, true
+}
+
+// NextStep returns the index of the first step that hasn't succeeded or
+// been skipped, or -1 if the plan is complete
+func (p *Plan) NextStep() int {
+	for i, step := range p.Steps {
+		if step.Status != StepSucceeded && step.Status != StepSkipped {
+			return i
+		}
+	}
+	return -1
+}
+
+// Status summarizes the plan's progress, e.g. "2/3 done" or "failed at step 2"
+func (p *Plan) Status() string {
+	done := 0
+	for i, step := range p.Steps {
+		switch step.Status {
+		case StepSucceeded, StepSkipped:
+			done++
+		case StepFailed:
+			return fmt.Sprintf("failed at step %d", i+1)
+		}
+	}
+	if done == len(p.Steps) {
+		return "completed"
+	}
+	return fmt.Sprintf("%d/%d done", done, len(p.Steps))
+}
+
+// Finish records the outcome of a step and checkpoints the plan
+func (p *Plan) Finish(i int, output string, err error) error {
+	now := time.Now()
+	step := &p.Steps[i]
+	step.Status = StepSucceeded
+	step.Error = ""
+	if err != nil {
+		step.Status = StepFailed
+		step.Error = err.Error()
+	}
+	if len(output) > maxStepOutput {
+		output = output[:maxStepOutput] + "\n... (truncated)"
+	}
+	step.Output = output
+	step.FinishedAt = &now
+	return p.Save()
+}
+
+// Skip marks a step as skipped and checkpoints the plan
+func (p *Plan) Skip(i int) error {
+	now := time.Now()
+	p.Steps[i].Status = StepSkipped
+	p.Steps[i].FinishedAt = &now
+	return p.Save()
+}
+
+// Save writes the plan to <config dir>/plans/<id>.json, assigning an id on
+// first save
+func (p *Plan) Save() error {
+	if p.path == "" {
+		dir, err := plansDir()
+		if err != nil {
+			return err
+		}
+		if p.ID == "" {
+			suffix := make([]byte, 2)
+			rand.Read(suffix)
+			p.ID = time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+		}
+		if p.CreatedAt.IsZero() {
+			p.CreatedAt = time.Now()
+		}
+		p.path = filepath.Join(dir, p.ID+".json")
+	}
+
+	p.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %w", err)
+	}
+	// Outputs may contain cluster data, so the plan is only readable by the user
+	return fsutil.WriteFileAtomic(p.path, data, 0600)
+}
+
+// LoadPlan reads a saved plan by id
+func LoadPlan(id string) (*Plan, error) {
+	dir, err := plansDir()
+	if err != nil {
+		return nil, err
+	}
+	if id != filepath.Base(id) {
+		return nil, fmt.Errorf("invalid plan id %q", id)
+	}
+
+	path := filepath.Join(dir, id+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("plan %s not found", id)
+		}
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse plan %s: %w", id, err)
+	}
+	p.path = path
+	return &p, nil
+}
+
+// ListPlans returns all saved plans, most recently updated first
+func ListPlans() ([]*Plan, error) {
+	dir, err := plansDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+
+	var plans []*Plan
+	for _, path := range paths {
+		p, err := LoadPlan(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			continue
+		}
+		plans = append(plans, p)
+	}
+	sort.Slice(plans, func(i, j int) bool {
+		return plans[i].UpdatedAt.After(plans[j].UpdatedAt)
+	})
+	return plans, nil
+}
+
+func plansDir() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(configDir, "plans")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create plans directory: %w", err)
+	}
+	return dir, nil
+}
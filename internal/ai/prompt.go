@@ -1,47 +1,256 @@
 package ai
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"text/template"
+
+	"oc-ai/internal/config"
 )
 
+// PromptVersion identifies the built-in prompt templates; bump it whenever
+// they change so persisted cache entries produced by older prompts are not
+// reused.
+const PromptVersion = "4"
+
 const (
-	SystemPromptTemplate = `You are an expert %s administrator.
+	TaskGenerate = "generate"
+	TaskExplain  = "explain"
+	TaskDiagnose = "diagnose"
+)
+
+// builtinPrompts are the default templates, keyed by task or "task.tool".
+// A "task.tool" template takes precedence over the generic task template.
+var builtinPrompts = map[string]string{
+	TaskGenerate: `You are an expert {{.ToolUpper}} administrator.
 Current Context:
-- Cluster: %s
-- Namespace: %s
-- User: %s
-- Server: %s
+- Cluster: {{.Cluster}}
+- Namespace: {{.Namespace}}
+- User: {{.User}}
+- Server: {{.Server}}
 
 Rules:
 1. Respond ONLY with: COMMAND|||EXPLANATION|||SAFETY_LEVEL(1-5)
 2. SAFETY_LEVEL: 1=Safe, 3=Caution, 5=Dangerous
-3. Generate commands for %s but NEVER include '%s' at the start of the command
-4. For example, if the tool is 'oc', and the command is 'get pods', respond with just 'get pods' not 'oc get pods'
-5. Include all required flags
-6. Never include destructive commands without confirmation`
+3. Generate {{.Tool}} commands but NEVER include '{{.Tool}}' at the start of the command, e.g. respond with 'get pods' not '{{.Tool}} get pods'
+4. Include all required flags
+5. Never include destructive commands without confirmation
+{{- if .Conversation}}
+6. Use the earlier messages of this conversation to resolve references such as "it", "the same" or "back"
+{{- end}}
+{{- if .Clarify}}
+
+If the request leaves out details you cannot infer from the context or conversation (for example which deployment, or how many replicas), do not guess. Respond instead with: CLARIFY|||QUESTION|||CHOICES
+CHOICES is a ;-separated list of likely answers, @RESOURCE_TYPE (e.g. @deployments) to offer the matching resources of the current namespace, or empty.
+{{- end}}
+{{- if .Plan}}
 
-	ExplainPromptTemplate = `Explain what this %s command does in simple terms. 
+If the request needs several commands run in order, respond instead with a first line PLAN|||SUMMARY followed by one line per step: STEP|||COMMAND|||EXPLANATION|||SAFETY_LEVEL
+Only use a plan when a single command is not enough.
+{{- end}}`,
+
+	TaskGenerate + ".oc": `{{template "generate" .}}
+
+OpenShift notes: prefer projects, routes and deploymentconfigs where they apply, and 'oc adm' for cluster administration.`,
+
+	TaskExplain: `Explain what the {{.Tool}} command sent by the user does in simple terms.
 Include:
 1. What resources it affects
 2. Potential risks
 3. Common use cases
-4. Any safer alternatives if applicable
+4. Any safer alternatives if applicable`,
 
-Command: %s`
-)
+	TaskDiagnose: `You are an expert {{.ToolUpper}} administrator troubleshooting a problem.
+The user sends a {{.Tool}} command and its output.
+1. Summarize what the output shows
+2. Identify the most likely causes of any problem
+3. Suggest the next read-only {{.Tool}} commands to confirm the cause, without the '{{.Tool}}' prefix
+4. Suggest a fix, clearly marking any command that changes the cluster`,
+}
+
+// PromptData is the data available to prompt templates
+type PromptData struct {
+	Tool      string
+	ToolUpper string
+	Cluster   string
+	Namespace string
+	User      string
+	Server    string
+	// Conversation is set when earlier turns are included in the request
+	Conversation bool
+	// Clarify allows the model to ask a clarifying question
+	Clarify bool
+	// Plan allows the model to answer with several steps
+	Plan bool
+}
+
+// PromptInfo describes the template used for a task and tool
+type PromptInfo struct {
+	Name    string
+	Version string
+	// Source is "built-in" or the path of a user override
+	Source string
+	Text   string
+}
+
+// PromptRegistry renders the prompts for every task. Templates from
+// <config dir>/prompts/<name>.tmpl override the built-in ones with the same
+// name, and organization instructions are appended to every prompt.
+type PromptRegistry struct {
+	templates map[string]string
+	sources   map[string]string
+	org       []string
+	parsed    *template.Template
+}
+
+// DefaultPrompts returns a registry with the built-in templates and the
+// given organization instructions
+func DefaultPrompts(orgInstructions []string) *PromptRegistry {
+	r, err := newPromptRegistry(nil, nil, orgInstructions)
+	if err != nil {
+		// The built-in templates are known to parse
+		panic(err)
+	}
+	return r
+}
+
+// LoadPromptRegistry loads user overrides from the config dir and appends
+// the given organization instructions to every prompt
+func LoadPromptRegistry(orgInstructions []string) (*PromptRegistry, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]string)
+	sources := make(map[string]string)
+	paths, _ := filepath.Glob(filepath.Join(configDir, "prompts", "*.tmpl"))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt %s: %w", path, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		overrides[name] = string(data)
+		sources[name] = path
+	}
+	return newPromptRegistry(overrides, sources, orgInstructions)
+}
+
+func newPromptRegistry(overrides, sources map[string]string, org []string) (*PromptRegistry, error) {
+	r := &PromptRegistry{
+		templates: make(map[string]string),
+		sources:   make(map[string]string),
+		parsed:    template.New("prompts").Option("missingkey=error"),
+	}
+	for name, text := range builtinPrompts {
+		r.templates[name] = text
+		r.sources[name] = "built-in"
+	}
+	for name, text := range overrides {
+		r.templates[name] = text
+		r.sources[name] = sources[name]
+	}
+	for _, instruction := range org {
+		if instruction = strings.TrimSpace(instruction); instruction != "" {
+			r.org = append(r.org, instruction)
+		}
+	}
+
+	for name, text := range r.templates {
+		if _, err := r.parsed.New(name).Parse(text); err != nil {
+			return nil, fmt.Errorf("invalid prompt template %s (%s): %w", name, r.sources[name], err)
+		}
+	}
+	return r, nil
+}
+
+// lookup returns the template name used for a task and tool
+func (r *PromptRegistry) lookup(task, tool string) (string, error) {
+	if _, ok := r.templates[task+"."+tool]; ok {
+		return task + "." + tool, nil
+	}
+	if _, ok := r.templates[task]; ok {
+		return task, nil
+	}
+	return "", fmt.Errorf("no prompt template for task %q", task)
+}
+
+// Render produces the system prompt for a task, with the organization
+// instructions appended
+func (r *PromptRegistry) Render(task string, data PromptData) (string, error) {
+	name, err := r.lookup(task, data.Tool)
+	if err != nil {
+		return "", err
+	}
+	if data.ToolUpper == "" {
+		data.ToolUpper = strings.ToUpper(data.Tool)
+	}
+
+	var buf bytes.Buffer
+	if err := r.parsed.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s: %w", name, err)
+	}
+
+	prompt := strings.TrimSpace(buf.String())
+	if len(r.org) > 0 {
+		prompt += "\n\nOrganization instructions (always follow these):\n- " + strings.Join(r.org, "\n- ")
+	}
+	return prompt, nil
+}
+
+// Version identifies the effective prompt for a task and tool. Built-in
+// templates use PromptVersion; overrides and organization instructions add
+// a hash of their content so caches notice when they change.
+func (r *PromptRegistry) Version(task, tool string) string {
+	name, err := r.lookup(task, tool)
+	if err != nil {
+		return PromptVersion
+	}
+
+	customized := len(r.org) > 0
+	h := sha256.New()
+	names := make([]string, 0, len(r.templates))
+	for n := range r.templates {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	// A template may include others, so hash every override
+	for _, n := range names {
+		if r.sources[n] != "built-in" {
+			customized = true
+			fmt.Fprintf(h, "%s\x00%s\x00", n, r.templates[n])
+		}
+	}
+	if !customized {
+		return PromptVersion
+	}
+	fmt.Fprintf(h, "%s\x00%s", name, strings.Join(r.org, "\x00"))
+	return PromptVersion + "-" + hex.EncodeToString(h.Sum(nil))[:8]
+}
 
-func BuildSystemPrompt(tool string, ctx ClusterContext) string {
-	return fmt.Sprintf(SystemPromptTemplate,
-		strings.ToUpper(tool),
-		ctx.Cluster,
-		ctx.Namespace,
-		ctx.User,
-		ctx.Server,
-		tool,
-		tool)
+// Prompts lists the templates in the registry by name
+func (r *PromptRegistry) Prompts() []PromptInfo {
+	var infos []PromptInfo
+	for name, text := range r.templates {
+		version := PromptVersion
+		if r.sources[name] != "built-in" {
+			sum := sha256.Sum256([]byte(text))
+			version = "user-" + hex.EncodeToString(sum[:])[:8]
+		}
+		infos = append(infos, PromptInfo{Name: name, Version: version, Source: r.sources[name], Text: text})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
 }
 
-func BuildExplainPrompt(tool, command string) string {
-	return fmt.Sprintf(ExplainPromptTemplate, tool, command)
+// OrgInstructions returns the instructions appended to every prompt
+func (r *PromptRegistry) OrgInstructions() []string {
+	return r.org
 }
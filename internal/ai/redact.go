@@ -0,0 +1,317 @@
+package ai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// redactionRule masks the given capture group of each match (0 = whole match)
+type redactionRule struct {
+	kind    string
+	pattern *regexp.Regexp
+	group   int
+}
+
+var builtinRedactionRules = []redactionRule{
+	{"CERT", regexp.MustCompile(`-----BEGIN [A-Z ]+-----[\s\S]*?-----END [A-Z ]+-----`), 0},
+	{"TOKEN", regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`), 1},
+	{"TOKEN", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), 0},
+	{"TOKEN", regexp.MustCompile(`\bsha256~[A-Za-z0-9_-]{20,}`), 0},
+	{"TOKEN", regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`), 0},
+	{"SECRET", regexp.MustCompile(`(?i)\b[A-Z0-9_]*(?:PASSWORD|PASSWD|PWD|SECRET|TOKEN|API_?KEY|ACCESS_?KEY|PRIVATE_?KEY)[A-Z0-9_]*["']?\s*[=:]\s*["']?([^\s"',}]+)`), 1},
+	{"SECRET", regexp.MustCompile(`--(?:password|token|client-secret)[= ]([^\s"']+)`), 1},
+	{"EMAIL", regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`), 0},
+	{"SECRET", regexp.MustCompile(`\b[A-Za-z0-9+/]{24,}={0,2}`), 0},
+}
+
+// Secret values are masked whatever their length, since short base64 values
+// don't look like secrets to the generic rule
+var (
+	secretKindPattern = regexp.MustCompile(`(?m)^[\s-]*kind:\s*Secret\s*$|\\?"kind\\?"\s*:\s*\\?"Secret\\?"`)
+	secretBlockYAML   = regexp.MustCompile(`^(\s*)(?:data|stringData):\s*$`)
+	secretBlockJSON   = regexp.MustCompile(`\\?"(?:data|stringData)\\?"\s*:\s*\{[^{}]*\}`)
+	secretFieldJSON   = regexp.MustCompile(`(\\?"[^"\\]+\\?"\s*:\s*\\?")([^"\\]*)(\\?")`)
+)
+
+// placeholderPattern matches every placeholder the Redactor produces
+var placeholderPattern = regexp.MustCompile(`REDACTED_[A-Z]+_\d+|(?:cluster|ns|ctx)-[0-9a-f]{8}`)
+
+// Redactor masks secrets and identifying cluster details before anything is
+// sent to the model. Masked values are replaced by stable placeholders and
+// remembered, so responses can be mapped back to the real values locally.
+type Redactor struct {
+	rules     []redactionRule
+	hashNames bool
+
+	mu           sync.Mutex
+	placeholders map[string]string // placeholder -> original
+	originals    map[string]string // original -> placeholder
+	literals     []string          // known values masked wherever they appear
+	counters     map[string]int
+}
+
+// NewRedactor builds a redactor with the built-in rules plus extra regular
+// expressions whose whole match is masked. With hashNames, cluster, context
+// and namespace names are replaced by short hashes.
+func NewRedactor(patterns []string, hashNames bool) (*Redactor, error) {
+	r := &Redactor{
+		rules:        append([]redactionRule{}, builtinRedactionRules...),
+		hashNames:    hashNames,
+		placeholders: make(map[string]string),
+		originals:    make(map[string]string),
+		counters:     make(map[string]int),
+	}
+
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		r.rules = append(r.rules, redactionRule{kind: "CUSTOM", pattern: re})
+	}
+	return r, nil
+}
+
+// ObserveContext registers the cluster context values to mask: the server
+// URL and user always, cluster, context and namespace names when hashing.
+func (r *Redactor) ObserveContext(ctx map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.addLiteral(ctx["server"], func() string { return r.nextPlaceholder("SERVER") })
+	r.addLiteral(ctx["user"], func() string { return r.nextPlaceholder("USER") })
+	if r.hashNames {
+		r.addLiteral(ctx["context"], func() string { return hashedName("ctx", ctx["context"]) })
+		r.addLiteral(ctx["cluster"], func() string { return hashedName("cluster", ctx["cluster"]) })
+		r.addLiteral(ctx["namespace"], func() string { return hashedName("ns", ctx["namespace"]) })
+	}
+}
+
+// Redact masks all sensitive data in text
+func (r *Redactor) Redact(text string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, literal := range r.literals {
+		text = replaceWord(text, literal, r.originals[literal])
+	}
+	text = r.redactSecretData(text)
+
+	for _, rule := range r.rules {
+		text = rule.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			sub := rule.pattern.FindStringSubmatchIndex(match)
+			start, end := 0, len(match)
+			if rule.group > 0 && len(sub) > 2*rule.group+1 && sub[2*rule.group] >= 0 {
+				start, end = sub[2*rule.group], sub[2*rule.group+1]
+			}
+			value := match[start:end]
+			if placeholderPattern.MatchString(value) || (rule.kind == "SECRET" && rule.group == 0 && !looksLikeBase64Secret(value)) {
+				return match
+			}
+			return match[:start] + r.placeholderFor(rule.kind, value) + match[end:]
+		})
+	}
+	return text
+}
+
+// redactSecretData masks the values of the data and stringData fields of
+// Secrets in YAML or JSON output
+func (r *Redactor) redactSecretData(text string) string {
+	if !secretKindPattern.MatchString(text) {
+		return text
+	}
+
+	text = secretBlockJSON.ReplaceAllStringFunc(text, func(block string) string {
+		return secretFieldJSON.ReplaceAllStringFunc(block, func(field string) string {
+			m := secretFieldJSON.FindStringSubmatch(field)
+			if m[2] == "" || placeholderPattern.MatchString(m[2]) {
+				return field
+			}
+			return m[1] + r.placeholderFor("SECRET", m[2]) + m[3]
+		})
+	})
+
+	lines := strings.Split(text, "\n")
+	blockIndent, keyIndent := -1, -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if blockIndent >= 0 && trimmed != "" {
+			if indent <= blockIndent {
+				blockIndent = -1
+			} else {
+				if keyIndent < 0 {
+					keyIndent = indent
+				}
+				lines[i] = r.redactSecretLine(line, indent > keyIndent)
+				continue
+			}
+		}
+		if m := secretBlockYAML.FindStringSubmatch(line); m != nil {
+			blockIndent, keyIndent = len(m[1]), -1
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// redactSecretLine masks the value of a "key: value" line of a Secret's
+// data, or the whole line when it continues a multi-line value
+func (r *Redactor) redactSecretLine(line string, continuation bool) string {
+	start := len(line) - len(strings.TrimLeft(line, " "))
+	if !continuation {
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			return line
+		}
+		start = idx + 1
+		for start < len(line) && line[start] == ' ' {
+			start++
+		}
+	}
+	value := strings.TrimRight(line[start:], " \r")
+	switch value {
+	case "", "|", "|-", "|+", ">", ">-", ">+":
+		return line
+	}
+	if placeholderPattern.MatchString(value) {
+		return line
+	}
+	return line[:start] + r.placeholderFor("SECRET", value) + line[start+len(value):]
+}
+
+// Restore replaces placeholders in text with the original values
+func (r *Redactor) Restore(text string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if original, ok := r.placeholders[placeholder]; ok {
+			return original
+		}
+		return placeholder
+	})
+}
+
+// redactMessages returns a copy of messages with every content redacted
+func (r *Redactor) redactMessages(messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	redacted := make([]openai.ChatCompletionMessage, len(messages))
+	for i, m := range messages {
+		m.Content = r.Redact(m.Content)
+		redacted[i] = m
+	}
+	return redacted
+}
+
+// restoreWriter restores placeholders in streamed text. Placeholders never
+// contain whitespace, so text is held back until the next whitespace.
+func (r *Redactor) restoreWriter(emit func(string)) (write func(string), flush func()) {
+	var pending strings.Builder
+	write = func(delta string) {
+		pending.WriteString(delta)
+		text := pending.String()
+		idx := strings.LastIndexFunc(text, unicode.IsSpace)
+		if idx < 0 {
+			return
+		}
+		emit(r.Restore(text[:idx+1]))
+		pending.Reset()
+		pending.WriteString(text[idx+1:])
+	}
+	flush = func() {
+		if pending.Len() > 0 {
+			emit(r.Restore(pending.String()))
+			pending.Reset()
+		}
+	}
+	return write, flush
+}
+
+func (r *Redactor) placeholderFor(kind, value string) string {
+	if placeholder, ok := r.originals[value]; ok {
+		return placeholder
+	}
+	placeholder := r.nextPlaceholder(kind)
+	r.placeholders[placeholder] = value
+	r.originals[value] = placeholder
+	return placeholder
+}
+
+func (r *Redactor) nextPlaceholder(kind string) string {
+	r.counters[kind]++
+	return fmt.Sprintf("REDACTED_%s_%d", kind, r.counters[kind])
+}
+
+func (r *Redactor) addLiteral(value string, newPlaceholder func() string) {
+	if value == "" {
+		return
+	}
+	if _, ok := r.originals[value]; ok {
+		return
+	}
+	placeholder := newPlaceholder()
+	r.placeholders[placeholder] = value
+	r.originals[value] = placeholder
+	r.literals = append(r.literals, value)
+
+	// Replace longer values first so a namespace inside a server URL doesn't break it
+	sort.Slice(r.literals, func(i, j int) bool {
+		return len(r.literals[i]) > len(r.literals[j])
+	})
+}
+
+// hashedName produces a stable, valid Kubernetes name for value
+func hashedName(prefix, value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return prefix + "-" + hex.EncodeToString(sum[:])[:8]
+}
+
+// replaceWord replaces value in text when it is not part of a longer name
+func replaceWord(text, value, replacement string) string {
+	var sb strings.Builder
+	last := 0
+	for start := 0; start < len(text); {
+		idx := strings.Index(text[start:], value)
+		if idx < 0 {
+			break
+		}
+		idx += start
+		end := idx + len(value)
+		if (idx == 0 || !isNameChar(text[idx-1])) && (end == len(text) || !isNameChar(text[end])) {
+			sb.WriteString(text[last:idx])
+			sb.WriteString(replacement)
+			last = end
+		}
+		start = end
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+func isNameChar(ch byte) bool {
+	return ch == '-' || ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+// looksLikeBase64Secret filters the generic base64 rule down to values that
+// mix upper case, lower case and digits, which names and hashes rarely do.
+func looksLikeBase64Secret(value string) bool {
+	var upper, lower, digit bool
+	for _, ch := range value {
+		switch {
+		case unicode.IsUpper(ch):
+			upper = true
+		case unicode.IsLower(ch):
+			lower = true
+		case unicode.IsDigit(ch):
+			digit = true
+		}
+	}
+	return upper && lower && digit
+}
@@ -0,0 +1,95 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactSecretData(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		masked []string
+		kept   []string
+	}{
+		{
+			name: "yaml with short values",
+			input: `apiVersion: v1
+data:
+  password: aHVudGVyMg==
+  user: YWRt
+kind: Secret
+metadata:
+  name: db
+type: Opaque`,
+			masked: []string{"aHVudGVyMg==", "YWRt"},
+			kept:   []string{"password:", "user:", "name: db", "type: Opaque"},
+		},
+		{
+			name: "stringData with a multi-line value",
+			input: `kind: Secret
+stringData:
+  pin: "1234"
+  config: |
+    user=admin
+    pass=abc
+metadata:
+  name: app`,
+			masked: []string{`"1234"`, "user=admin", "pass=abc"},
+			kept:   []string{"pin:", "config: |", "name: app"},
+		},
+		{
+			name: "list of secrets",
+			input: `apiVersion: v1
+items:
+- apiVersion: v1
+  data:
+    token: dG9r
+  kind: Secret
+kind: List`,
+			masked: []string{"dG9r"},
+			kept:   []string{"token:", "kind: List"},
+		},
+		{
+			name:   "json",
+			input:  `{"apiVersion": "v1", "data": {"password": "aHVudGVyMg==", "user": "YWRt"}, "kind": "Secret"}`,
+			masked: []string{"aHVudGVyMg==", "YWRt"},
+			kept:   []string{`"password": "REDACTED_SECRET_`, `"kind": "Secret"`},
+		},
+		{
+			name:   "escaped json in an annotation",
+			input:  `{"kind": "Secret", "metadata": {"annotations": {"last-applied": "{\"data\":{\"password\":\"cXdl\"},\"kind\":\"Secret\"}"}}}`,
+			masked: []string{"cXdl"},
+		},
+		{
+			name: "config maps are left alone",
+			input: `apiVersion: v1
+data:
+  mode: debug
+kind: ConfigMap`,
+			kept: []string{"mode: debug"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRedactor(nil, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			redacted := r.Redact(tt.input)
+			for _, value := range tt.masked {
+				if strings.Contains(redacted, value) {
+					t.Errorf("%q was not masked:\n%s", value, redacted)
+				}
+			}
+			for _, value := range tt.kept {
+				if !strings.Contains(redacted, value) {
+					t.Errorf("%q is missing:\n%s", value, redacted)
+				}
+			}
+			if restored := r.Restore(redacted); restored != tt.input {
+				t.Errorf("restored text differs:\n%s", restored)
+			}
+		})
+	}
+}
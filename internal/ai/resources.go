@@ -0,0 +1,423 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"oc-ai/internal/cli"
+	"oc-ai/internal/config"
+	"oc-ai/internal/fsutil"
+)
+
+const (
+	defaultResourceCacheTTL = time.Hour
+	maxCRDsPerPrompt        = 3
+	maxCRDFields            = 25
+)
+
+// APIResource is one row of `api-resources` output
+type APIResource struct {
+	Name       string   `json:"name"`
+	ShortNames []string `json:"short_names,omitempty"`
+	Group      string   `json:"group,omitempty"`
+	Version    string   `json:"version,omitempty"`
+	Namespaced bool     `json:"namespaced"`
+	Kind       string   `json:"kind"`
+}
+
+var (
+	wordPattern         = regexp.MustCompile(`[A-Za-z0-9.-]+`)
+	explainFieldPattern = regexp.MustCompile(`^\s{2,3}(\S+)\s+<([^>]+)>`)
+	unsafeFileChars     = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+)
+
+// ResourceCatalog lists the resource types available in one kube context.
+// It is cached on disk so the cluster is only queried once per TTL.
+type ResourceCatalog struct {
+	Context   string              `json:"context"`
+	FetchedAt time.Time           `json:"fetched_at"`
+	Resources []APIResource       `json:"resources"`
+	Fields    map[string][]string `json:"fields,omitempty"`
+
+	cli  cli.CLI
+	path string
+}
+
+// UnknownResourceError is returned when a generated command references a
+// resource type that does not exist on the cluster.
+type UnknownResourceError struct {
+	Resource string
+}
+
+func (e *UnknownResourceError) Error() string {
+	return fmt.Sprintf("resource type %q does not exist on this cluster", e.Resource)
+}
+
+// LoadResourceCatalog returns the catalog for contextName, refreshing it from
+// the cluster when the cached copy is missing or older than ttl.
+func LoadResourceCatalog(c cli.CLI, contextName string, ttl time.Duration) (*ResourceCatalog, error) {
+	if ttl <= 0 {
+		ttl = defaultResourceCacheTTL
+	}
+
+	configDir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	dirPath := filepath.Join(configDir, "resources")
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create resource cache directory: %w", err)
+	}
+
+	path := filepath.Join(dirPath, sanitizeFileName(contextName)+".json")
+	if data, err := os.ReadFile(path); err == nil {
+		var rc ResourceCatalog
+		if err := json.Unmarshal(data, &rc); err == nil && time.Since(rc.FetchedAt) < ttl {
+			rc.cli = c
+			rc.path = path
+			return &rc, nil
+		}
+	}
+
+	output, err := c.Execute("api-resources")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api resources: %w", err)
+	}
+
+	rc := &ResourceCatalog{
+		Context:   contextName,
+		FetchedAt: time.Now(),
+		Resources: ParseAPIResources(output),
+		cli:       c,
+		path:      path,
+	}
+	if len(rc.Resources) == 0 {
+		return nil, fmt.Errorf("no api resources found")
+	}
+
+	if err := rc.save(); err != nil {
+		fmt.Printf("Warning: Failed to cache api resources: %v\n", err)
+	}
+	return rc, nil
+}
+
+// ParseAPIResources parses the tabular output of `api-resources`, using the
+// header positions since the SHORTNAMES column is often empty.
+func ParseAPIResources(output string) []APIResource {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return nil
+	}
+
+	header := lines[0]
+	columns := []string{"NAME", "SHORTNAMES", "APIVERSION", "APIGROUP", "NAMESPACED", "KIND"}
+	offsets := make(map[string]int)
+	for _, col := range columns {
+		if idx := strings.Index(header, col); idx >= 0 {
+			offsets[col] = idx
+		}
+	}
+
+	field := func(line, col string) string {
+		start, ok := offsets[col]
+		if !ok || start >= len(line) {
+			return ""
+		}
+		end := len(line)
+		for _, other := range offsets {
+			if other > start && other < end {
+				end = other
+			}
+		}
+		if end > len(line) {
+			end = len(line)
+		}
+		return strings.TrimSpace(line[start:end])
+	}
+
+	var resources []APIResource
+	for _, line := range lines[1:] {
+		name := field(line, "NAME")
+		if name == "" {
+			continue
+		}
+
+		group, version := field(line, "APIGROUP"), ""
+		if apiVersion := field(line, "APIVERSION"); apiVersion != "" {
+			version = apiVersion
+			if idx := strings.LastIndex(apiVersion, "/"); idx >= 0 {
+				group, version = apiVersion[:idx], apiVersion[idx+1:]
+			}
+		}
+
+		var shortNames []string
+		if sn := field(line, "SHORTNAMES"); sn != "" {
+			shortNames = strings.Split(sn, ",")
+		}
+
+		resources = append(resources, APIResource{
+			Name:       name,
+			ShortNames: shortNames,
+			Group:      group,
+			Version:    version,
+			Namespaced: field(line, "NAMESPACED") == "true",
+			Kind:       field(line, "KIND"),
+		})
+	}
+	return resources
+}
+
+// Lookup resolves a resource type as typed on the command line: plural,
+// singular, kind, short name or fully qualified name.group.
+func (rc *ResourceCatalog) Lookup(name string) (*APIResource, bool) {
+	name = strings.ToLower(name)
+	for i := range rc.Resources {
+		r := &rc.Resources[i]
+		if r.matches(name) {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+func (r *APIResource) matches(name string) bool {
+	candidates := []string{r.Name, strings.ToLower(r.Kind), strings.TrimSuffix(r.Name, "s")}
+	candidates = append(candidates, r.ShortNames...)
+	for _, c := range candidates {
+		if name == c {
+			return true
+		}
+		// Allow fully or partially qualified groups, e.g. routes.route.openshift.io or routes.route
+		if r.Group != "" && strings.HasPrefix(name, c+".") && strings.HasPrefix(r.Group, strings.TrimPrefix(name, c+".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCustom reports whether the resource comes from a CRD rather than a
+// built-in Kubernetes or OpenShift API group.
+func (r *APIResource) IsCustom() bool {
+	if r.Group == "" || !strings.Contains(r.Group, ".") {
+		return false
+	}
+	return !strings.HasSuffix(r.Group, ".k8s.io") && !strings.HasSuffix(r.Group, ".openshift.io")
+}
+
+// PromptSummary renders a compact list of available kinds for the system prompt
+func (rc *ResourceCatalog) PromptSummary() string {
+	var builtin, custom []string
+	for _, r := range rc.Resources {
+		entry := r.Name
+		if r.IsCustom() {
+			entry += "." + r.Group
+		}
+		if len(r.ShortNames) > 0 {
+			entry += "(" + strings.Join(r.ShortNames, ",") + ")"
+		}
+		if r.IsCustom() {
+			custom = append(custom, entry)
+		} else {
+			builtin = append(builtin, entry)
+		}
+	}
+	sort.Strings(builtin)
+	sort.Strings(custom)
+
+	var sb strings.Builder
+	sb.WriteString("Available resource types (short names in parentheses): ")
+	sb.WriteString(strings.Join(builtin, " "))
+	if len(custom) > 0 {
+		sb.WriteString("\nCustom resources (CRDs): ")
+		sb.WriteString(strings.Join(custom, " "))
+	}
+	return sb.String()
+}
+
+// RelevantFields returns the spec fields of custom resources mentioned in the
+// prompt, fetched with `explain` and cached alongside the catalog.
+func (rc *ResourceCatalog) RelevantFields(prompt string) string {
+	words := make(map[string]bool)
+	for _, w := range wordPattern.FindAllString(strings.ToLower(prompt), -1) {
+		words[w] = true
+	}
+
+	var sb strings.Builder
+	found, updated := 0, false
+	for i := range rc.Resources {
+		r := &rc.Resources[i]
+		if !r.IsCustom() || found >= maxCRDsPerPrompt {
+			continue
+		}
+
+		mentioned := false
+		for w := range words {
+			if r.matches(w) {
+				mentioned = true
+				break
+			}
+		}
+		if !mentioned {
+			continue
+		}
+		found++
+
+		qualified := r.Name + "." + r.Group
+		fields, ok := rc.Fields[qualified]
+		if !ok && rc.cli != nil {
+			explainCmd := "explain " + r.Name + ".spec"
+			if r.Version != "" {
+				explainCmd += " --api-version=" + r.Group + "/" + r.Version
+			}
+			output, err := rc.cli.Execute(explainCmd)
+			if err != nil {
+				continue
+			}
+			fields = parseExplainFields(output)
+			if rc.Fields == nil {
+				rc.Fields = make(map[string][]string)
+			}
+			rc.Fields[qualified] = fields
+			updated = true
+		}
+		if len(fields) > 0 {
+			fmt.Fprintf(&sb, "- %s (%s) spec fields: %s\n", qualified, r.Kind, strings.Join(fields, ", "))
+		}
+	}
+
+	if updated {
+		if err := rc.save(); err != nil {
+			fmt.Printf("Warning: Failed to cache resource fields: %v\n", err)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// parseExplainFields extracts "name <type>" pairs from the FIELDS section of `explain`
+func parseExplainFields(output string) []string {
+	var fields []string
+	inFields := false
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "FIELDS:") {
+			inFields = true
+			continue
+		}
+		if !inFields {
+			continue
+		}
+		if m := explainFieldPattern.FindStringSubmatch(line); m != nil {
+			fields = append(fields, m[1]+" <"+m[2]+">")
+			if len(fields) >= maxCRDFields {
+				break
+			}
+		}
+	}
+	return fields
+}
+
+// ValidateCommand checks that every resource type referenced by the command
+// exists in the catalog.
+func (rc *ResourceCatalog) ValidateCommand(command string) error {
+	for _, resource := range ReferencedResourceTypes(command) {
+		if _, ok := rc.Lookup(resource); !ok {
+			return &UnknownResourceError{Resource: resource}
+		}
+	}
+	return nil
+}
+
+// verbs whose first positional argument is a resource type
+var resourceVerbs = map[string]bool{
+	"get": true, "describe": true, "delete": true, "edit": true, "patch": true,
+	"label": true, "annotate": true, "scale": true, "explain": true, "wait": true,
+	"autoscale": true, "expose": true,
+}
+
+// flags that consume the following argument as their value
+var valueFlags = map[string]bool{
+	"-n": true, "--namespace": true, "-o": true, "--output": true, "-l": true,
+	"--selector": true, "-f": true, "--filename": true, "-c": true, "--container": true,
+	"--context": true, "--field-selector": true, "--sort-by": true, "--replicas": true,
+	"-p": true, "--patch": true, "--type": true, "--for": true, "--timeout": true,
+	"--kubeconfig": true, "--tail": true, "--since": true, "--as": true,
+}
+
+// PositionalArgs returns the command arguments that are not flags or flag values
+func PositionalArgs(args []string) []string {
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if strings.HasPrefix(arg, "-") {
+			if !strings.Contains(arg, "=") && valueFlags[arg] {
+				i++
+			}
+			continue
+		}
+		positional = append(positional, arg)
+	}
+	return positional
+}
+
+// ReferencedResourceTypes returns the resource types named by a command,
+// e.g. "get pods,svc" yields [pods svc] and "rollout restart deploy/web" yields [deploy].
+func ReferencedResourceTypes(command string) []string {
+	positional := PositionalArgs(cli.ParseCommand(command))
+	if len(positional) < 2 {
+		return nil
+	}
+
+	verb := positional[0]
+	var target string
+	switch {
+	case verb == "rollout" && len(positional) >= 3:
+		target = positional[2]
+	case verb == "logs":
+		// logs takes a pod name unless given as type/name
+		if !strings.Contains(positional[1], "/") {
+			return nil
+		}
+		target = positional[1]
+	case resourceVerbs[verb]:
+		target = positional[1]
+	default:
+		return nil
+	}
+
+	var types []string
+	for _, t := range strings.Split(target, ",") {
+		if idx := strings.Index(t, "/"); idx >= 0 {
+			t = t[:idx]
+		}
+		if t == "" || t == "all" {
+			continue
+		}
+		// explain accepts field paths such as pods.spec.containers
+		if verb == "explain" {
+			t = strings.SplitN(t, ".", 2)[0]
+		}
+		types = append(types, t)
+	}
+	return types
+}
+
+func (rc *ResourceCatalog) save() error {
+	data, err := json.MarshalIndent(rc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal resource catalog: %w", err)
+	}
+
+	return fsutil.WriteFileAtomic(rc.path, data, 0644)
+}
+
+func sanitizeFileName(name string) string {
+	if name == "" {
+		return "default"
+	}
+	return unsafeFileChars.ReplaceAllString(name, "_")
+}
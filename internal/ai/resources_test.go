@@ -0,0 +1,100 @@
+package ai
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const apiResourcesOutput = `NAME                     SHORTNAMES   APIVERSION                    NAMESPACED   KIND
+pods                     po           v1                            true         Pod
+nodes                    no           v1                            false        Node
+deployments              deploy       apps/v1                       true         Deployment
+routes                                route.openshift.io/v1         true         Route
+kafkatopics              kt,topic     kafka.strimzi.io/v1beta2      true         KafkaTopic
+`
+
+func TestParseAPIResources(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []APIResource
+	}{
+		{
+			name:   "apiversion column",
+			output: apiResourcesOutput,
+			want: []APIResource{
+				{Name: "pods", ShortNames: []string{"po"}, Version: "v1", Namespaced: true, Kind: "Pod"},
+				{Name: "nodes", ShortNames: []string{"no"}, Version: "v1", Kind: "Node"},
+				{Name: "deployments", ShortNames: []string{"deploy"}, Group: "apps", Version: "v1", Namespaced: true, Kind: "Deployment"},
+				{Name: "routes", Group: "route.openshift.io", Version: "v1", Namespaced: true, Kind: "Route"},
+				{Name: "kafkatopics", ShortNames: []string{"kt", "topic"}, Group: "kafka.strimzi.io", Version: "v1beta2", Namespaced: true, Kind: "KafkaTopic"},
+			},
+		},
+		{
+			name: "apigroup column of older clients",
+			output: `NAME          SHORTNAMES   APIGROUP   NAMESPACED   KIND
+services      svc                     true         Service
+deployments   deploy       apps       true         Deployment
+`,
+			want: []APIResource{
+				{Name: "services", ShortNames: []string{"svc"}, Namespaced: true, Kind: "Service"},
+				{Name: "deployments", ShortNames: []string{"deploy"}, Group: "apps", Namespaced: true, Kind: "Deployment"},
+			},
+		},
+		{name: "header only", output: "NAME   SHORTNAMES   APIVERSION   NAMESPACED   KIND\n", want: nil},
+		{name: "empty", output: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseAPIResources(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAPIResources() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCommand(t *testing.T) {
+	catalog := &ResourceCatalog{Resources: ParseAPIResources(apiResourcesOutput)}
+	tests := []struct {
+		command string
+		unknown string
+	}{
+		{"get pods -n web", ""},
+		{"get po,deploy", ""},
+		{"get pod", ""},
+		{"get Deployment web", ""},
+		{"get routes.route.openshift.io", ""},
+		{"get routes.route", ""},
+		{"get kt -n kafka", ""},
+		{"describe topic orders", ""},
+		{"rollout restart deploy/web", ""},
+		{"logs web-1", ""},
+		{"logs deployment/web", ""},
+		{"explain pods.spec.containers", ""},
+		{"get all -n web", ""},
+		{"scale deployment web --replicas=3", ""},
+		{"get -n web -o wide pods", ""},
+		{"api-resources", ""},
+		{"get virtualmachines", "virtualmachines"},
+		{"get pods,widgets", "widgets"},
+		{"logs widget/web", "widget"},
+		{"rollout restart dc/web", "dc"},
+		{"get routes.networking.k8s.io", "routes.networking.k8s.io"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			err := catalog.ValidateCommand(tt.command)
+			if tt.unknown == "" {
+				if err != nil {
+					t.Errorf("ValidateCommand() = %v, want no error", err)
+				}
+				return
+			}
+			var unknownErr *UnknownResourceError
+			if !errors.As(err, &unknownErr) || unknownErr.Resource != tt.unknown {
+				t.Errorf("ValidateCommand() = %v, want unknown resource %q", err, tt.unknown)
+			}
+		})
+	}
+}
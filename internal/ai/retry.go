@@ -0,0 +1,156 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy controls how AI requests are retried on rate limits (429),
+// server errors (5xx) and network failures.
+type RetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout bounds each attempt until the response headers arrive
+	Timeout time.Duration
+}
+
+// DefaultRetryPolicy is used when no policy is configured
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     2,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	Timeout:        10 * time.Second,
+}
+
+// retryDoer wraps an HTTP client with RetryPolicy. It sits below the OpenAI
+// client so it can see status codes and the Retry-After header.
+type retryDoer struct {
+	client *http.Client
+	policy RetryPolicy
+}
+
+func newRetryDoer(policy RetryPolicy) *retryDoer {
+	return &retryDoer{client: &http.Client{}, policy: policy}
+}
+
+func (d *retryDoer) Do(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		resp, err := d.attempt(req)
+		if attempt >= d.policy.MaxRetries || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			wait = d.backoff(attempt)
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			// Running out of quota won't be fixed by waiting
+			if isQuotaCode(string(body)) {
+				resp.Body = io.NopCloser(bytes.NewReader(body))
+				return resp, nil
+			}
+			wait = retryAfter(resp.Header.Get("Retry-After"))
+			// Waiting longer than the backoff cap is left to the fallback models
+			if d.policy.MaxBackoff > 0 && wait > d.policy.MaxBackoff {
+				resp.Body = io.NopCloser(bytes.NewReader(body))
+				return resp, nil
+			}
+			if wait <= 0 {
+				wait = d.backoff(attempt)
+			}
+		default:
+			return resp, nil
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt performs a single request, cancelling it if the headers don't
+// arrive within the policy timeout. The body stays readable until closed so
+// streamed responses are not cut off.
+func (d *retryDoer) attempt(req *http.Request) (*http.Response, error) {
+	if d.policy.Timeout <= 0 {
+		return d.client.Do(req)
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(d.policy.Timeout, func() {
+		timedOut.Store(true)
+		cancel()
+	})
+
+	resp, err := d.client.Do(req.WithContext(ctx))
+	timer.Stop()
+	if err != nil {
+		cancel()
+		if timedOut.Load() {
+			return nil, fmt.Errorf("no response after %s: %w", d.policy.Timeout, context.DeadlineExceeded)
+		}
+		return nil, err
+	}
+
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// backoff returns the exponential delay for an attempt with up to 20% jitter
+func (d *retryDoer) backoff(attempt int) time.Duration {
+	wait := d.policy.InitialBackoff << attempt
+	if wait <= 0 || (d.policy.MaxBackoff > 0 && wait > d.policy.MaxBackoff) {
+		wait = d.policy.MaxBackoff
+	}
+	if wait > 0 {
+		wait += time.Duration(rand.Int63n(int64(wait)/5 + 1))
+	}
+	return wait
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
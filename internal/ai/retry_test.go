@@ -0,0 +1,325 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// fakeReply is one scripted response of fakeModelServer
+type fakeReply struct {
+	status     int
+	retryAfter string
+	code       string
+	content    string
+	delay      time.Duration
+}
+
+// fakeModelServer is an OpenAI-compatible server answering chat completions
+// with scripted replies per model; the last reply of a model repeats. A
+// reply in byPrompt, keyed by the last user message, takes precedence.
+type fakeModelServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	replies  map[string][]fakeReply
+	byPrompt map[string]fakeReply
+	calls    []string
+}
+
+func newFakeModelServer(t *testing.T, replies map[string][]fakeReply) *fakeModelServer {
+	t.Helper()
+	s := &fakeModelServer{replies: replies}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeModelServer) handle(w http.ResponseWriter, r *http.Request) {
+	var req openai.ChatCompletionRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	s.mu.Lock()
+	s.calls = append(s.calls, req.Model)
+	script := s.replies[req.Model]
+	reply := fakeReply{status: http.StatusNotFound, code: "model_not_found"}
+	if len(script) > 0 {
+		reply = script[0]
+		if len(script) > 1 {
+			s.replies[req.Model] = script[1:]
+		}
+	}
+	if last := len(req.Messages) - 1; last >= 0 {
+		if r, ok := s.byPrompt[req.Messages[last].Content]; ok {
+			reply = r
+		}
+	}
+	s.mu.Unlock()
+
+	if reply.delay > 0 {
+		select {
+		case <-time.After(reply.delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if reply.retryAfter != "" {
+		w.Header().Set("Retry-After", reply.retryAfter)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if reply.status != 0 && reply.status != http.StatusOK {
+		w.WriteHeader(reply.status)
+		fmt.Fprintf(w, `{"error":{"message":"scripted failure","type":"error","code":%q}}`, reply.code)
+		return
+	}
+	json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+		Model: req.Model,
+		Choices: []openai.ChatCompletionChoice{{
+			Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply.content},
+			FinishReason: openai.FinishReasonStop,
+		}},
+	})
+}
+
+func (s *fakeModelServer) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.calls...)
+}
+
+// testClient returns a client of the fake server with fast retries
+func testClient(baseURL, model string, retries int) *Client {
+	c := NewClient("test-key", "oc", model)
+	c.SetBaseURL(baseURL)
+	c.SetRetryPolicy(RetryPolicy{
+		MaxRetries:     retries,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Timeout:        2 * time.Second,
+	})
+	return c
+}
+
+func testRequest() openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "list pods"}},
+	}
+}
+
+func TestRetries(t *testing.T) {
+	ok := fakeReply{content: "get pods|||Lists pods|||1"}
+	tests := []struct {
+		name    string
+		replies []fakeReply
+		retries int
+		calls   int
+		wantErr bool
+	}{
+		{"success", []fakeReply{ok}, 2, 1, false},
+		{"rate limited then success", []fakeReply{{status: 429}, ok}, 2, 2, false},
+		{"server errors then success", []fakeReply{{status: 503}, {status: 502}, ok}, 2, 3, false},
+		{"retries exhausted", []fakeReply{{status: 500}}, 2, 3, true},
+		{"no retries", []fakeReply{{status: 500}}, 0, 1, true},
+		{"quota is not retried", []fakeReply{{status: 429, code: "insufficient_quota"}, ok}, 2, 1, true},
+		{"bad request is not retried", []fakeReply{{status: 400}, ok}, 2, 1, true},
+		{"auth is not retried", []fakeReply{{status: 401}, ok}, 2, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeModelServer(t, map[string][]fakeReply{"primary": tt.replies})
+			client := testClient(server.URL, "primary", tt.retries)
+
+			resp, err := client.createChatCompletion(context.Background(), testRequest())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && resp.Choices[0].Message.Content != ok.content {
+				t.Errorf("content = %q", resp.Choices[0].Message.Content)
+			}
+			if calls := len(server.Calls()); calls != tt.calls {
+				t.Errorf("calls = %d, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestRetryAfterIsHonored(t *testing.T) {
+	server := newFakeModelServer(t, map[string][]fakeReply{
+		"primary": {{status: 429, retryAfter: "1"}, {content: "get pods|||Lists pods|||1"}},
+	})
+	client := testClient(server.URL, "primary", 2)
+	client.SetRetryPolicy(RetryPolicy{MaxRetries: 2, MaxBackoff: 2 * time.Second, Timeout: 2 * time.Second})
+
+	start := time.Now()
+	if _, err := client.createChatCompletion(context.Background(), testRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least the 1s of Retry-After", elapsed)
+	}
+}
+
+func TestRetryAfterOverMaxBackoff(t *testing.T) {
+	server := newFakeModelServer(t, map[string][]fakeReply{
+		"primary": {{status: 429, retryAfter: "60"}},
+		"second":  {{content: "get pods|||Lists pods|||1"}},
+	})
+	client := testClient(server.URL, "primary", 2)
+
+	start := time.Now()
+	_, err := client.createChatCompletion(context.Background(), testRequest())
+	var unavailable *UnavailableError
+	if !errors.As(err, &unavailable) {
+		t.Fatalf("err = %v, want an UnavailableError", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %s, want no wait for a Retry-After over MaxBackoff", elapsed)
+	}
+
+	client.SetFallbackModels([]string{"second"})
+	if _, err := client.createChatCompletion(context.Background(), testRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if calls := server.Calls(); strings.Join(calls, ",") != "primary,primary,second" {
+		t.Errorf("calls = %v, want one attempt of primary per request, then second", calls)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"3", 3 * time.Second, 3 * time.Second},
+		{"soon", 0, 0},
+		{time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("retryAfter(%q) = %s, want between %s and %s", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply fakeReply
+		check func(error) bool
+	}{
+		{"401", fakeReply{status: 401}, func(err error) bool { var e *AuthError; return errors.As(err, &e) }},
+		{"403", fakeReply{status: 403}, func(err error) bool { var e *AuthError; return errors.As(err, &e) }},
+		{"quota", fakeReply{status: 429, code: "insufficient_quota"}, func(err error) bool { var e *QuotaError; return errors.As(err, &e) }},
+		{"429", fakeReply{status: 429}, func(err error) bool {
+			var e *UnavailableError
+			return errors.As(err, &e) && e.StatusCode == 429
+		}},
+		{"503", fakeReply{status: 503}, func(err error) bool {
+			var e *UnavailableError
+			return errors.As(err, &e) && e.StatusCode == 503
+		}},
+		{"timeout", fakeReply{delay: time.Second}, func(err error) bool { var e *TimeoutError; return errors.As(err, &e) }},
+		{"400", fakeReply{status: 400}, func(err error) bool { return !isUnreachable(err) && !shouldFallback(err) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeModelServer(t, map[string][]fakeReply{"primary": {tt.reply}})
+			client := testClient(server.URL, "primary", 0)
+			client.SetRetryPolicy(RetryPolicy{Timeout: 100 * time.Millisecond})
+
+			_, err := client.createChatCompletion(context.Background(), testRequest())
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !tt.check(err) {
+				t.Errorf("unexpected error type %T: %v", err, err)
+			}
+		})
+	}
+}
+
+func TestFallbackChain(t *testing.T) {
+	ok := fakeReply{content: "get pods|||Lists pods|||1"}
+	tests := []struct {
+		name      string
+		replies   map[string][]fakeReply
+		fallbacks []string
+		calls     []string
+		wantErr   bool
+	}{
+		{
+			name:      "primary works",
+			replies:   map[string][]fakeReply{"primary": {ok}},
+			fallbacks: []string{"second"},
+			calls:     []string{"primary"},
+		},
+		{
+			name:      "unavailable falls back",
+			replies:   map[string][]fakeReply{"primary": {{status: 503}}, "second": {ok}},
+			fallbacks: []string{"second"},
+			calls:     []string{"primary", "primary", "second"},
+		},
+		{
+			name:      "unknown model and auth fall back in order",
+			replies:   map[string][]fakeReply{"second": {{status: 401}}, "third": {ok}},
+			fallbacks: []string{"second", "third"},
+			calls:     []string{"primary", "second", "third"},
+		},
+		{
+			name:      "bad request is final",
+			replies:   map[string][]fakeReply{"primary": {{status: 400}}, "second": {ok}},
+			fallbacks: []string{"second"},
+			calls:     []string{"primary"},
+			wantErr:   true,
+		},
+		{
+			name:      "every model fails",
+			replies:   map[string][]fakeReply{"primary": {{status: 500}}, "second": {{status: 429, code: "insufficient_quota"}}},
+			fallbacks: []string{"second"},
+			calls:     []string{"primary", "primary", "second"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeModelServer(t, tt.replies)
+			client := testClient(server.URL, "primary", 1)
+			client.SetFallbackModels(tt.fallbacks)
+
+			_, err := client.createChatCompletion(context.Background(), testRequest())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if calls := server.Calls(); strings.Join(calls, ",") != strings.Join(tt.calls, ",") {
+				t.Errorf("calls = %v, want %v", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestFallbackToOtherEndpoint(t *testing.T) {
+	primary := newFakeModelServer(t, map[string][]fakeReply{"primary": {{status: 502}}})
+	local := newFakeModelServer(t, map[string][]fakeReply{"llama3": {{content: "get pods|||Lists pods|||1"}}})
+	client := testClient(primary.URL, "primary", 0)
+	client.SetFallbackModels([]string{"llama3@" + local.URL})
+
+	command, _, _, err := client.GenerateCommand("list pods", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command != "get pods" {
+		t.Errorf("command = %q, want %q", command, "get pods")
+	}
+	if len(primary.Calls()) != 1 || len(local.Calls()) != 1 {
+		t.Errorf("calls: primary %v, fallback %v", primary.Calls(), local.Calls())
+	}
+}
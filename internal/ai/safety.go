@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"strings"
+
+	"oc-ai/internal/cli"
+)
+
+// verbSafety is the baseline risk of each CLI verb, using the same 1-5 scale
+// the model is asked to produce.
+var verbSafety = map[string]int{
+	"get": 1, "describe": 1, "logs": 1, "explain": 1, "top": 1, "events": 1,
+	"api-resources": 1, "version": 1, "whoami": 1, "status": 1, "projects": 1,
+	"port-forward": 1, "auth": 1, "diff": 1, "wait": 1,
+	"label": 2, "annotate": 2, "create": 2, "expose": 2, "new-app": 2,
+	"new-project": 2, "project": 2, "config": 2, "autoscale": 2,
+	"scale": 3, "rollout": 3, "set": 3, "patch": 3, "edit": 3, "apply": 3,
+	"exec": 3, "rsh": 3, "debug": 3, "cp": 3, "start-build": 3, "tag": 3,
+	"replace": 4, "delete": 4, "cordon": 4, "taint": 4,
+	"drain": 5, "adm": 4,
+}
+
+// resources whose deletion affects more than a single workload
+var criticalResources = map[string]bool{
+	"namespace": true, "namespaces": true, "ns": true, "project": true, "projects": true,
+	"node": true, "nodes": true, "no": true, "pv": true, "persistentvolume": true,
+	"persistentvolumes": true, "crd": true, "crds": true, "customresourcedefinition": true,
+	"customresourcedefinitions": true, "clusterrole": true, "clusterroles": true,
+	"clusterrolebinding": true, "clusterrolebindings": true,
+}
+
+// AssessSafety computes a safety level (1-5) for a command locally, without
+// asking the model. Unknown verbs are treated as caution.
+func AssessSafety(command string) int {
+	args := cli.ParseCommand(command)
+	positional := PositionalArgs(args)
+	if len(positional) == 0 {
+		return 3
+	}
+
+	level, ok := verbSafety[positional[0]]
+	if !ok {
+		level = 3
+	}
+
+	if positional[0] == "delete" {
+		for _, t := range ReferencedResourceTypes(command) {
+			if criticalResources[strings.ToLower(t)] {
+				level = 5
+			}
+		}
+		for _, arg := range args {
+			if arg == "--all" || arg == "-A" || arg == "--all-namespaces" || strings.HasPrefix(arg, "--force") {
+				level = 5
+			}
+		}
+	}
+
+	return level
+}
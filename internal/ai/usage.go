@@ -0,0 +1,218 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"oc-ai/internal/config"
+	"oc-ai/internal/fsutil"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	BudgetActionWarn  = "warn"
+	BudgetActionBlock = "block"
+)
+
+// UsageRecord aggregates the tokens consumed on one day by one model for
+// one oc-ai command
+type UsageRecord struct {
+	Day              string `json:"day"`
+	Model            string `json:"model"`
+	Command          string `json:"command"`
+	Requests         int    `json:"requests"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+}
+
+// UsageStore persists token usage in the oc-ai config directory. Updates
+// are serialized with a lock file so concurrent invocations don't lose counts.
+type UsageStore struct {
+	path string
+}
+
+// Budget limits token usage per day and per session (one oc-ai invocation
+// or interactive session). Zero means unlimited.
+type Budget struct {
+	Daily   int
+	Session int
+	// Action is BudgetActionWarn or BudgetActionBlock
+	Action string
+}
+
+// BudgetExceededError is returned instead of calling the model once a budget
+// with the block action is used up
+type BudgetExceededError struct {
+	Scope string
+	Used  int
+	Limit int
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("%s token budget exceeded: %d of %d tokens used (use --offline to generate with local rules)", e.Scope, e.Used, e.Limit)
+}
+
+// usageTracker records usage for a client and enforces its budget
+type usageTracker struct {
+	store   *UsageStore
+	budget  Budget
+	command string
+
+	mu      sync.Mutex
+	session int
+	warned  bool
+}
+
+func OpenUsageStore() (*UsageStore, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return &UsageStore{path: filepath.Join(configDir, "usage.json")}, nil
+}
+
+// Add records the usage of one AI response
+func (s *UsageStore) Add(day time.Time, model, command string, usage openai.Usage) error {
+	lock, err := fsutil.Lock(s.path)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	key := day.Format("2006-01-02")
+	found := false
+	for i := range records {
+		r := &records[i]
+		if r.Day == key && r.Model == model && r.Command == command {
+			r.add(usage)
+			found = true
+			break
+		}
+	}
+	if !found {
+		r := UsageRecord{Day: key, Model: model, Command: command}
+		r.add(usage)
+		records = append(records, r)
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage: %w", err)
+	}
+	return fsutil.WriteFileAtomic(s.path, data, 0644)
+}
+
+// Records returns all usage records, newest day first
+func (s *UsageStore) Records() ([]UsageRecord, error) {
+	records, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Day != records[j].Day {
+			return records[i].Day > records[j].Day
+		}
+		if records[i].Model != records[j].Model {
+			return records[i].Model < records[j].Model
+		}
+		return records[i].Command < records[j].Command
+	})
+	return records, nil
+}
+
+// DayTotal returns the total tokens used on the given day
+func (s *UsageStore) DayTotal(day time.Time) (int, error) {
+	records, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+
+	key := day.Format("2006-01-02")
+	total := 0
+	for _, r := range records {
+		if r.Day == key {
+			total += r.TotalTokens
+		}
+	}
+	return total, nil
+}
+
+func (s *UsageStore) load() ([]UsageRecord, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read usage file: %w", err)
+	}
+
+	var records []UsageRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse usage file: %w", err)
+	}
+	return records, nil
+}
+
+func (r *UsageRecord) add(usage openai.Usage) {
+	r.Requests++
+	r.PromptTokens += usage.PromptTokens
+	r.CompletionTokens += usage.CompletionTokens
+	r.TotalTokens += usage.TotalTokens
+}
+
+// check returns an error if a blocking budget is used up, and prints a
+// warning once per session for a warning budget.
+func (t *usageTracker) check() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.budget.Session > 0 && t.session >= t.budget.Session {
+		if err := t.exceeded(&BudgetExceededError{Scope: "session", Used: t.session, Limit: t.budget.Session}); err != nil {
+			return err
+		}
+	}
+
+	if t.budget.Daily > 0 {
+		used, err := t.store.DayTotal(time.Now())
+		if err != nil {
+			return nil
+		}
+		if used >= t.budget.Daily {
+			return t.exceeded(&BudgetExceededError{Scope: "daily", Used: used, Limit: t.budget.Daily})
+		}
+	}
+	return nil
+}
+
+func (t *usageTracker) exceeded(err *BudgetExceededError) error {
+	if t.budget.Action == BudgetActionBlock {
+		return err
+	}
+	if !t.warned {
+		fmt.Printf("Warning: %v\n", err)
+		t.warned = true
+	}
+	return nil
+}
+
+func (t *usageTracker) record(model string, usage openai.Usage) {
+	t.mu.Lock()
+	t.session += usage.TotalTokens
+	t.mu.Unlock()
+
+	if err := t.store.Add(time.Now(), model, t.command, usage); err != nil {
+		fmt.Printf("Warning: Failed to record token usage: %v\n", err)
+	}
+}
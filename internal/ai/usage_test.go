@@ -0,0 +1,48 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestBlockingBudgetIsReported(t *testing.T) {
+	server := newFakeModelServer(t, map[string][]fakeReply{"primary": {{content: "get pods|||Lists pods|||1"}}})
+	store := &UsageStore{path: filepath.Join(t.TempDir(), "usage.json")}
+	if err := store.Add(time.Now(), "primary", "ai", openai.Usage{TotalTokens: 500}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		action  string
+		wantErr bool
+	}{
+		{BudgetActionBlock, true},
+		{BudgetActionWarn, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			client := testClient(server.URL, "primary", 0)
+			client.SetUsage(store, Budget{Daily: 100, Action: tt.action}, "ai")
+
+			result, err := client.Generate(context.Background(), GenerateRequest{Prompt: "list pods"})
+			var budgetErr *BudgetExceededError
+			if tt.wantErr {
+				if !errors.As(err, &budgetErr) {
+					t.Fatalf("err = %v, want a BudgetExceededError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Offline || result.Command != "get pods" {
+				t.Errorf("result = %+v, want the model's command", result)
+			}
+		})
+	}
+}
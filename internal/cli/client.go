@@ -90,6 +90,7 @@ func (c *BaseCLI) GetContext() (map[string]string, error) {
 	}
 
 	result := make(map[string]string)
+	result["context"] = config.CurrentContext
 	for _, c := range config.Contexts {
 		if c.Name == config.CurrentContext {
 			result["namespace"] = c.Context.Namespace
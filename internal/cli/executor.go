@@ -119,3 +119,30 @@ func IsInteractiveCommand(command string) bool {
 
 	return interactiveCommands[parts[0]]
 }
+
+// ShellQuote quotes an argument for a POSIX shell, leaving plain words as they are
+func ShellQuote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	safe := true
+	for _, ch := range arg {
+		if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || strings.ContainsRune("-_./:=,@%+", ch)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+}
+
+// ShellJoin quotes each argument and joins them into a shell command line
+func ShellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = ShellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -14,6 +15,40 @@ type Config struct {
 	ConfirmExecute bool   `mapstructure:"confirm_execute"`
 	HistoryLimit   int    `mapstructure:"history_limit"`
 	PreferredCLI   string `mapstructure:"preferred_cli"`
+
+	ConversationMaxTurns  int `mapstructure:"conversation_max_turns"`
+	ConversationMaxTokens int `mapstructure:"conversation_max_tokens"`
+
+	GroundResources  bool          `mapstructure:"ground_resources"`
+	ResourceCacheTTL time.Duration `mapstructure:"resource_cache_ttl"`
+
+	CacheEnabled    bool          `mapstructure:"cache_enabled"`
+	CacheTTL        time.Duration `mapstructure:"cache_ttl"`
+	CacheMaxEntries int           `mapstructure:"cache_max_entries"`
+	CacheMaxSizeMB  int           `mapstructure:"cache_max_size_mb"`
+
+	OpenAIBaseURL    string        `mapstructure:"openai_base_url"`
+	FallbackModels   []string      `mapstructure:"fallback_models"`
+	AITimeout        time.Duration `mapstructure:"ai_timeout"`
+	AIMaxRetries     int           `mapstructure:"ai_max_retries"`
+	AIInitialBackoff time.Duration `mapstructure:"ai_initial_backoff"`
+	AIMaxBackoff     time.Duration `mapstructure:"ai_max_backoff"`
+
+	DailyTokenBudget   int    `mapstructure:"daily_token_budget"`
+	SessionTokenBudget int    `mapstructure:"session_token_budget"`
+	BudgetAction       string `mapstructure:"budget_action"`
+
+	StreamResponses bool `mapstructure:"stream_responses"`
+
+	RedactEnabled   bool     `mapstructure:"redact_enabled"`
+	RedactPatterns  []string `mapstructure:"redact_patterns"`
+	RedactHashNames bool     `mapstructure:"redact_hash_names"`
+
+	OrgInstructions []string `mapstructure:"org_instructions"`
+
+	Candidates int `mapstructure:"candidates"`
+
+	TemplateDirs []string `mapstructure:"template_dirs"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -35,12 +70,29 @@ func LoadConfig() (*Config, error) {
 	viper.BindEnv("openai_key")
 	viper.BindEnv("default_model")
 	viper.BindEnv("preferred_cli")
+	viper.BindEnv("openai_base_url")
 
 	// Defaults
 	viper.SetDefault("default_model", "gpt-4-turbo")
 	viper.SetDefault("confirm_execute", true)
 	viper.SetDefault("history_limit", 100)
 	viper.SetDefault("preferred_cli", "auto")
+	viper.SetDefault("conversation_max_turns", 10)
+	viper.SetDefault("conversation_max_tokens", 2000)
+	viper.SetDefault("ground_resources", true)
+	viper.SetDefault("resource_cache_ttl", "1h")
+	viper.SetDefault("cache_enabled", true)
+	viper.SetDefault("cache_ttl", "24h")
+	viper.SetDefault("cache_max_entries", 1000)
+	viper.SetDefault("cache_max_size_mb", 10)
+	viper.SetDefault("ai_timeout", "10s")
+	viper.SetDefault("ai_max_retries", 2)
+	viper.SetDefault("ai_initial_backoff", "500ms")
+	viper.SetDefault("ai_max_backoff", "10s")
+	viper.SetDefault("budget_action", "warn")
+	viper.SetDefault("stream_responses", true)
+	viper.SetDefault("redact_enabled", true)
+	viper.SetDefault("candidates", 1)
 
 	// Read config
 	if err := viper.ReadInConfig(); err != nil {
@@ -62,3 +114,17 @@ func LoadConfig() (*Config, error) {
 
 	return &cfg, nil
 }
+
+// Dir returns the oc-ai configuration directory, creating it if needed
+func Dir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user config directory: %w", err)
+	}
+
+	dirPath := filepath.Join(configDir, "oc-ai")
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %w", err)
+	}
+	return dirPath, nil
+}
@@ -0,0 +1,110 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	lockRetryInterval = 20 * time.Millisecond
+	lockTimeout       = 5 * time.Second
+	// A lock older than this is assumed to belong to a crashed process
+	staleLockAge = 30 * time.Second
+)
+
+// FileLock is an advisory lock shared between oc-ai processes, implemented
+// with an exclusively created lock file so it works on every platform.
+type FileLock struct {
+	path string
+}
+
+// Lock acquires the lock for path, waiting up to a few seconds for other
+// processes to release it.
+func Lock(path string) (*FileLock, error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return &FileLock{path: lockPath}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > staleLockAge {
+			breakStaleLock(lockPath, info)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s", lockPath)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// breakStaleLock removes a lock file left behind by a crashed process. The
+// file is renamed first, which only one process can do, and only removed if
+// it is still the stale file: another process may have broken the same lock
+// and created a new one in the meantime, which is put back.
+func breakStaleLock(lockPath string, stale os.FileInfo) {
+	claimed := fmt.Sprintf("%s.%d.stale", lockPath, os.Getpid())
+	if err := os.Rename(lockPath, claimed); err != nil {
+		return
+	}
+	if info, err := os.Stat(claimed); err == nil && os.SameFile(info, stale) {
+		os.Remove(claimed)
+		return
+	}
+	// Linking fails rather than replacing a lock created since the rename
+	if err := os.Link(claimed, lockPath); err != nil && !errors.Is(err, os.ErrExist) {
+		os.Rename(claimed, lockPath)
+		return
+	}
+	os.Remove(claimed)
+}
+
+// Unlock releases the lock
+func (l *FileLock) Unlock() error {
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	return nil
+}
+
+// WriteFileAtomic writes data to a temporary file and renames it over path,
+// so readers never observe a partially written file.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempPath := tempFile.Name()
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Chmod(tempPath, perm); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath) // Clean up temp file if rename fails
+		return fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+	return nil
+}
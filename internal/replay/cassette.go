@@ -0,0 +1,96 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"oc-ai/internal/fsutil"
+)
+
+const (
+	ModeRecord = "record"
+	ModeReplay = "replay"
+
+	defaultCassettePath = "oc-ai-cassette.json"
+)
+
+// Cassette holds recorded AI requests and CLI invocations. In record mode
+// every interaction is appended and saved immediately; in replay mode
+// interactions are served from the file and the network and cluster are
+// never touched.
+type Cassette struct {
+	Tool string             `json:"tool"`
+	HTTP []*HTTPInteraction `json:"http"`
+	CLI  []*CLIInteraction  `json:"cli"`
+
+	mode string
+	path string
+	mu   sync.Mutex
+}
+
+// FromEnv opens the cassette selected by OC_AI_REPLAY (record or replay) and
+// OC_AI_CASSETTE (defaults to oc-ai-cassette.json). It returns nil when
+// OC_AI_REPLAY is not set.
+func FromEnv() (*Cassette, error) {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("OC_AI_REPLAY")))
+	if mode == "" {
+		return nil, nil
+	}
+	path := os.Getenv("OC_AI_CASSETTE")
+	if path == "" {
+		path = defaultCassettePath
+	}
+
+	switch mode {
+	case ModeRecord:
+		return &Cassette{mode: mode, path: path}, nil
+	case ModeReplay:
+		return Load(path)
+	default:
+		return nil, fmt.Errorf("invalid OC_AI_REPLAY mode %q: must be %s or %s", mode, ModeRecord, ModeReplay)
+	}
+}
+
+// Load reads a cassette for replay
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	c := &Cassette{mode: ModeReplay, path: path}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c *Cassette) Mode() string {
+	return c.mode
+}
+
+func (c *Cassette) Replaying() bool {
+	return c.mode == ModeReplay
+}
+
+// SetTool records which CLI tool the interactions were captured with
+func (c *Cassette) SetTool(tool string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Tool = tool
+}
+
+// save writes the cassette; the caller must hold c.mu
+func (c *Cassette) save() error {
+	if c.mode != ModeRecord {
+		return nil
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+	return fsutil.WriteFileAtomic(c.path, data, 0644)
+}
@@ -0,0 +1,118 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"oc-ai/internal/cli"
+)
+
+const (
+	opExecute  = "execute"
+	opContext  = "context"
+	opVersion  = "version"
+	opSupports = "supports"
+)
+
+// CLIInteraction is one recorded call to the oc/kubectl CLI
+type CLIInteraction struct {
+	Op     string `json:"op"`
+	Input  string `json:"input,omitempty"`
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+
+	used bool
+}
+
+// replayCLI implements cli.CLI on top of a cassette
+type replayCLI struct {
+	cassette *Cassette
+	inner    cli.CLI
+}
+
+// WrapCLI returns a CLI that records the calls made to inner, or replays
+// recorded results without running any binary. inner may be nil when
+// replaying.
+func (c *Cassette) WrapCLI(inner cli.CLI) cli.CLI {
+	return &replayCLI{cassette: c, inner: inner}
+}
+
+func (r *replayCLI) Execute(command string) (string, error) {
+	return r.call(opExecute, command, func() (string, error) {
+		return r.inner.Execute(command)
+	})
+}
+
+func (r *replayCLI) GetContext() (map[string]string, error) {
+	output, err := r.call(opContext, "", func() (string, error) {
+		ctx, err := r.inner.GetContext()
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(ctx)
+		return string(data), err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ctx map[string]string
+	if err := json.Unmarshal([]byte(output), &ctx); err != nil {
+		return nil, fmt.Errorf("replay: invalid recorded context: %w", err)
+	}
+	return ctx, nil
+}
+
+func (r *replayCLI) GetVersion() (string, error) {
+	return r.call(opVersion, "", func() (string, error) {
+		return r.inner.GetVersion()
+	})
+}
+
+func (r *replayCLI) Supports(feature string) bool {
+	output, err := r.call(opSupports, feature, func() (string, error) {
+		if r.inner.Supports(feature) {
+			return "true", nil
+		}
+		return "false", nil
+	})
+	return err == nil && output == "true"
+}
+
+// call replays the first unused interaction for op and input, or runs fn and
+// records its result
+func (r *replayCLI) call(op, input string, fn func() (string, error)) (string, error) {
+	c := r.cassette
+	if c.Replaying() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for _, in := range c.CLI {
+			if in.used || in.Op != op || in.Input != input {
+				continue
+			}
+			in.used = true
+			if in.Error != "" {
+				return in.Output, errors.New(in.Error)
+			}
+			return in.Output, nil
+		}
+		return "", fmt.Errorf("replay: no recorded %s call for %q", op, input)
+	}
+
+	output, err := fn()
+	in := &CLIInteraction{Op: op, Input: input, Output: output}
+	if err != nil {
+		in.Error = err.Error()
+	}
+
+	c.mu.Lock()
+	c.CLI = append(c.CLI, in)
+	if saveErr := c.save(); saveErr != nil {
+		fmt.Printf("Warning: Failed to save cassette: %v\n", saveErr)
+	}
+	c.mu.Unlock()
+
+	return output, err
+}
@@ -0,0 +1,130 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+
+	"oc-ai/internal/ai"
+)
+
+// HTTPInteraction is one recorded AI API request and its response
+type HTTPInteraction struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Body        string `json:"body"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Response    string `json:"response,omitempty"`
+	Error       string `json:"error,omitempty"`
+
+	used bool
+}
+
+// nonceAttr matches the random ids of untrusted data markers, which differ
+// on every run
+var nonceAttr = regexp.MustCompile(`\bid=[0-9a-f]{8}\b`)
+
+// WrapHTTP returns a doer that records the responses of inner, or replays
+// recorded responses without calling inner at all
+func (c *Cassette) WrapHTTP(inner ai.HTTPDoer) ai.HTTPDoer {
+	return &httpDoer{cassette: c, inner: inner}
+}
+
+type httpDoer struct {
+	cassette *Cassette
+	inner    ai.HTTPDoer
+}
+
+func (d *httpDoer) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	key := HTTPInteraction{Method: req.Method, Path: req.URL.Path, Body: normalizeBody(body)}
+
+	if d.cassette.Replaying() {
+		return d.replay(req, key)
+	}
+	return d.record(req, key)
+}
+
+func (d *httpDoer) replay(req *http.Request, key HTTPInteraction) (*http.Response, error) {
+	c := d.cassette
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, in := range c.HTTP {
+		if in.used || in.Method != key.Method || in.Path != key.Path || in.Body != key.Body {
+			continue
+		}
+		in.used = true
+		if in.Error != "" {
+			return nil, errors.New(in.Error)
+		}
+		header := make(http.Header)
+		if in.ContentType != "" {
+			header.Set("Content-Type", in.ContentType)
+		}
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			StatusCode: in.Status,
+			Header:     header,
+			Body:       io.NopCloser(bytes.NewReader([]byte(in.Response))),
+			Request:    req,
+		}, nil
+	}
+	return nil, fmt.Errorf("replay: no recorded response for %s %s", key.Method, key.Path)
+}
+
+// record buffers the whole response, so streamed output only appears once
+// the response is complete while recording
+func (d *httpDoer) record(req *http.Request, key HTTPInteraction) (*http.Response, error) {
+	resp, err := d.inner.Do(req)
+	in := key
+	if err != nil {
+		in.Error = err.Error()
+	} else {
+		data, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", readErr)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		in.Status = resp.StatusCode
+		in.ContentType = resp.Header.Get("Content-Type")
+		in.Response = string(data)
+	}
+
+	c := d.cassette
+	c.mu.Lock()
+	c.HTTP = append(c.HTTP, &in)
+	if saveErr := c.save(); saveErr != nil {
+		fmt.Printf("Warning: Failed to save cassette: %v\n", saveErr)
+	}
+	c.mu.Unlock()
+
+	return resp, err
+}
+
+// normalizeBody makes request bodies comparable between runs: JSON is
+// re-encoded with sorted keys and random marker ids are blanked
+func normalizeBody(body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		if data, err := json.Marshal(v); err == nil {
+			body = data
+		}
+	}
+	return nonceAttr.ReplaceAllString(string(body), "id=00000000")
+}
@@ -0,0 +1,364 @@
+package templates
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"oc-ai/internal/cli"
+)
+
+// Builtins are the variables every template can use besides its parameters
+type Builtins struct {
+	Context   string
+	Namespace string
+	Cluster   string
+	Tool      string
+	User      string
+	Now       time.Time
+}
+
+var builtinNames = map[string]bool{
+	"Context": true, "Namespace": true, "Cluster": true, "Tool": true, "User": true, "Now": true,
+}
+
+// IsBuiltin reports whether name is a built-in variable
+func IsBuiltin(name string) bool {
+	return builtinNames[name]
+}
+
+// Global flags of oc-ai a parameter may be named after. Parameters named
+// namespace or context take the value of that flag; the other flags would
+// change how the template runs, so their names are reserved.
+var (
+	flagParams    = map[string]bool{"namespace": true, "context": true}
+	reservedNames = map[string]bool{
+		"yes": true, "dry-run": true, "ai-model": true, "offline": true,
+		"kubeconfig": true, "insecure-skip-tls-verify": true, "help": true,
+	}
+)
+
+// FromGlobalFlag reports whether a parameter takes the value of the global
+// flag of the same name rather than a flag of its own
+func FromGlobalFlag(name string) bool {
+	return flagParams[name]
+}
+
+// NewBuiltins describes the current kube context, as returned by
+// cli.CLI.GetContext. The namespace flag overrides the context's namespace,
+// and the user falls back to the local user when the context has none.
+func NewBuiltins(tool string, ctx map[string]string, namespace string) Builtins {
+	b := Builtins{
+		Context:   ctx["context"],
+		Namespace: namespace,
+		Cluster:   ctx["cluster"],
+		Tool:      tool,
+		User:      ctx["user"],
+		Now:       time.Now(),
+	}
+	if b.Namespace == "" {
+		b.Namespace = ctx["namespace"]
+	}
+	if b.Namespace == "" {
+		b.Namespace = "default"
+	}
+	if b.User == "" {
+		b.User = os.Getenv("USER")
+	}
+	return b
+}
+
+// Data merges the built-in variables with parameter values for rendering
+func (b Builtins) Data(values map[string]interface{}) map[string]interface{} {
+	data := map[string]interface{}{
+		"Context":   b.Context,
+		"Namespace": b.Namespace,
+		"Cluster":   b.Cluster,
+		"Tool":      b.Tool,
+		"User":      b.User,
+		"Now":       b.Now,
+	}
+	for k, v := range values {
+		data[k] = v
+	}
+	return data
+}
+
+// Safe is text that is already quoted for the command line, as returned by
+// quote and raw, and is substituted as is
+type Safe string
+
+// shellEscape quotes a substituted value so it stays a single argument,
+// whatever it contains. Empty values stay empty so optional parameters
+// disappear from the command.
+func shellEscape(value interface{}) Safe {
+	switch v := value.(type) {
+	case Safe:
+		return v
+	case nil:
+		return ""
+	}
+	s := fmt.Sprint(value)
+	if s == "" {
+		return ""
+	}
+	return Safe(cli.ShellQuote(s))
+}
+
+// funcs is the function library of templates. It has no access to the
+// environment, files or commands.
+var funcs = template.FuncMap{
+	"shellEscape":             shellEscape,
+	"shellEscapeSingleQuoted": escapeInQuotes('\''),
+	"shellEscapeDoubleQuoted": escapeInQuotes('"'),
+	"quote": func(value interface{}) Safe {
+		return Safe(cli.ShellQuote(toString(value)))
+	},
+	"raw": func(value interface{}) Safe {
+		return Safe(toString(value))
+	},
+	"default": func(fallback, value interface{}) interface{} {
+		if empty(value) {
+			return fallback
+		}
+		return value
+	},
+	"required": func(message string, value interface{}) (interface{}, error) {
+		if empty(value) {
+			return nil, fmt.Errorf("%s", message)
+		}
+		return value, nil
+	},
+	"lower":   func(s interface{}) string { return strings.ToLower(toString(s)) },
+	"upper":   func(s interface{}) string { return strings.ToUpper(toString(s)) },
+	"trim":    func(s interface{}) string { return strings.TrimSpace(toString(s)) },
+	"replace": func(old, new string, s interface{}) string { return strings.ReplaceAll(toString(s), old, new) },
+	"split":   func(sep string, s interface{}) []string { return strings.Split(toString(s), sep) },
+	"join": func(sep string, list interface{}) (string, error) {
+		items, err := toList(list)
+		return strings.Join(items, sep), err
+	},
+	"toJSON": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	"base64": func(value interface{}) string {
+		return base64.StdEncoding.EncodeToString([]byte(toString(value)))
+	},
+	"base64Decode": func(value interface{}) (string, error) {
+		data, err := base64.StdEncoding.DecodeString(toString(value))
+		return string(data), err
+	},
+	"atoi": func(value interface{}) (int, error) {
+		return strconv.Atoi(strings.TrimSpace(toString(value)))
+	},
+	"toString": toString,
+	"now":      time.Now,
+	"dateAdd": func(duration string, t time.Time) (time.Time, error) {
+		d, err := parseDuration(duration)
+		return t.Add(d), err
+	},
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"rfc3339": func(t time.Time) string {
+		return t.UTC().Format(time.RFC3339)
+	},
+	"unix": func(t time.Time) int64 {
+		return t.Unix()
+	},
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case Safe:
+		return string(v)
+	}
+	return fmt.Sprint(value)
+}
+
+// empty reports whether a value is missing or its type's zero value
+func empty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func toList(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case []string:
+		return v, nil
+	case string:
+		return strings.Fields(v), nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("join: expected a list, got %T", value)
+	}
+	items := make([]string, rv.Len())
+	for i := range items {
+		items[i] = toString(rv.Index(i).Interface())
+	}
+	return items, nil
+}
+
+// parseDuration extends time.ParseDuration with days (d) and weeks (w),
+// e.g. -7d or 1w2d
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	rest := strings.TrimLeft(s, "+-")
+
+	var total time.Duration
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}} {
+		if i := strings.Index(rest, unit.suffix); i > 0 {
+			n, err := strconv.Atoi(rest[:i])
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			total += time.Duration(n) * unit.size
+			rest = rest[i+1:]
+		}
+	}
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		total += d
+	}
+	if negative {
+		total = -total
+	}
+	return total, nil
+}
+
+// ParseCommand parses a command template. The value of every action is
+// shell-quoted, so substituted values can't add arguments or break quoting;
+// quote and raw mark values that are already safe. Actions inside quotes
+// written in the template, like -l 'app={{.name}}', only have the quote
+// character escaped, so the value stays inside them.
+func ParseCommand(name, text string) (*template.Template, error) {
+	// A missing variable, e.g. captured by a step that was skipped, fails
+	// rather than dropping an argument from the command
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := escapeActions(tmpl.Tree.Root, 0); err != nil {
+		return nil, fmt.Errorf("template: %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// ParseCondition parses a when or until condition, which isn't quoted
+func ParseCondition(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Parse(text)
+}
+
+// escapeInQuotes escapes a value substituted between quote characters of
+// the template the way cli.ShellQuote does: the quote is closed, the quote
+// character is added within the other kind of quotes, and it is reopened
+func escapeInQuotes(quote rune) func(interface{}) Safe {
+	q := string(quote)
+	other := "'"
+	if quote == '\'' {
+		other = `"`
+	}
+	return func(value interface{}) Safe {
+		if v, ok := value.(Safe); ok {
+			return v
+		}
+		return Safe(strings.ReplaceAll(toString(value), q, q+other+q+other+q))
+	}
+}
+
+// quoteEscapers are the escapers of escapeActions by quote context
+var quoteEscapers = map[rune]string{0: "shellEscape", '\'': "shellEscapeSingleQuoted", '"': "shellEscapeDoubleQuoted"}
+
+// escapeActions appends the escaper of its quote context to the pipeline of
+// every action that prints something, the way html/template adds its
+// escapers. quote is the quote character open before node, 0 for none, and
+// the one open after it is returned.
+func escapeActions(node parse.Node, quote rune) (rune, error) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return quote, nil
+		}
+		for _, child := range n.Nodes {
+			var err error
+			if quote, err = escapeActions(child, quote); err != nil {
+				return quote, err
+			}
+		}
+	case *parse.TextNode:
+		for _, ch := range string(n.Text) {
+			switch {
+			case quote == 0 && (ch == '\'' || ch == '"'):
+				quote = ch
+			case ch == quote:
+				quote = 0
+			}
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) == 0 {
+			n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+				NodeType: parse.NodeCommand,
+				Pos:      n.Pos,
+				Args:     []parse.Node{parse.NewIdentifier(quoteEscapers[quote]).SetTree(nil).SetPos(n.Pos)},
+			})
+		}
+	case *parse.IfNode:
+		return escapeBranches(&n.BranchNode, "if", quote)
+	case *parse.RangeNode:
+		return escapeBranches(&n.BranchNode, "range", quote)
+	case *parse.WithNode:
+		return escapeBranches(&n.BranchNode, "with", quote)
+	}
+	return quote, nil
+}
+
+// escapeBranches escapes both branches of an if, range or with, which must
+// leave the same quote open
+func escapeBranches(n *parse.BranchNode, keyword string, quote rune) (rune, error) {
+	after, err := escapeActions(n.List, quote)
+	if err != nil {
+		return after, err
+	}
+	if n.ElseList != nil {
+		afterElse, err := escapeActions(n.ElseList, quote)
+		if err != nil {
+			return afterElse, err
+		}
+		if afterElse != after {
+			return after, fmt.Errorf("the branches of {{%s}} at offset %d leave different quotes open", keyword, n.Pos)
+		}
+	} else if after != quote {
+		return after, fmt.Errorf("{{%s}} at offset %d opens a quote it doesn't close", keyword, n.Pos)
+	}
+	if keyword == "range" && after != quote {
+		return after, fmt.Errorf("{{range}} at offset %d opens a quote it doesn't close", n.Pos)
+	}
+	return after, nil
+}
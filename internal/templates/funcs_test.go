@@ -0,0 +1,82 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+
+	"oc-ai/internal/cli"
+)
+
+func TestEscapeActions(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		value   interface{}
+		want    string
+		args    []string
+	}{
+		{"plain value", "get pods -l app={{.v}}", "web", "get pods -l app=web", nil},
+		{"value with spaces", "get pods -l app={{.v}}", "web; rm -rf /", "get pods -l app='web; rm -rf /'",
+			[]string{"get", "pods", "-l", "app=web; rm -rf /"}},
+		{"empty value disappears", "get pods {{.v}}", "", "get pods ", []string{"get", "pods"}},
+		{"single quotes of the template", "get pods -l 'app={{.v}}'", "web", "get pods -l 'app=web'", nil},
+		{"single quote in single quotes", "get pods -l 'app={{.v}}'", "it's", `get pods -l 'app=it'"'"'s'`,
+			[]string{"get", "pods", "-l", "app=it's"}},
+		{"json in single quotes", `patch deploy/web -p '{"x":"{{.v}}"}'`, "a b", `patch deploy/web -p '{"x":"a b"}'`,
+			[]string{"patch", "deploy/web", "-p", `{"x":"a b"}`}},
+		{"double quotes of the template", `get pods --selector="app={{.v}}"`, `a"b c`, `get pods --selector="app=a"'"'"b c"`,
+			[]string{"get", "pods", `--selector=app=a"b c`}},
+		{"quotes inside a branch", "get pods{{if .v}} -l 'app={{.v}}'{{end}}", "x y", "get pods -l 'app=x y'", nil},
+		{"raw is kept", "get pods -l 'app={{raw .v}}'", "web", "get pods -l 'app=web'", nil},
+		{"quote outside quotes", "get pods -l {{quote .v}}", "app=web", "get pods -l app=web", nil},
+		{"number", "scale deploy/web --replicas={{.v}}", 3, "scale deploy/web --replicas=3", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseCommand("command", tt.command)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			if err := tmpl.Execute(&out, map[string]interface{}{"v": tt.value}); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("rendered %s, want %s", out.String(), tt.want)
+			}
+			if tt.args != nil {
+				if args := cli.ParseCommand(out.String()); strings.Join(args, "|") != strings.Join(tt.args, "|") {
+					t.Errorf("arguments %q, want %q", args, tt.args)
+				}
+			}
+		})
+	}
+}
+
+func TestEscapeActionsUnbalancedQuotes(t *testing.T) {
+	for _, command := range []string{
+		"get pods {{if .v}}-l 'app={{end}}{{.v}}'",
+		"get pods {{if .v}}'{{else}}\"{{end}}",
+		"get pods {{range .v}}'{{.}}{{end}}",
+	} {
+		if _, err := ParseCommand("command", command); err == nil {
+			t.Errorf("%s: expected an error", command)
+		}
+	}
+
+	_, errs := Parse([]byte("name: t\ncommand: get pods {{if .Namespace}}'{{end}}\n"), "t.yaml")
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "t.yaml:2:10: invalid command template: {{if}} at offset") {
+		t.Errorf("errors = %v", errs)
+	}
+}
+
+func TestCommandMissingKey(t *testing.T) {
+	tmpl, err := ParseCommand("command", "logs {{.pod}} -n {{.v}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, map[string]interface{}{"v": "web"}); err == nil {
+		t.Errorf("rendered %q, expected an error for the missing variable", out.String())
+	}
+}
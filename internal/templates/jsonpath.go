@@ -0,0 +1,282 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a compiled path expression in the kubectl dialect, such as
+// {.status.readyReplicas} or {.status.conditions[?(@.type=="Ready")].status}.
+// It supports fields, indexes, wildcards and simple filters.
+type JSONPath struct {
+	expr     string
+	segments []pathSegment
+}
+
+type segmentKind int
+
+const (
+	segField segmentKind = iota
+	segIndex
+	segWildcard
+	segFilter
+)
+
+type pathSegment struct {
+	kind  segmentKind
+	field string
+	index int
+	// filter compares the value at path with literal; exists-only when op is ""
+	path    []pathSegment
+	op      string
+	literal interface{}
+}
+
+// CompileJSONPath parses a path expression; the surrounding braces and the
+// leading $ are optional
+func CompileJSONPath(expr string) (*JSONPath, error) {
+	s := strings.TrimSpace(expr)
+	if strings.HasPrefix(s, "{") {
+		if !strings.HasSuffix(s, "}") {
+			return nil, fmt.Errorf("invalid JSONPath %q: missing closing '}'", expr)
+		}
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	s = strings.TrimPrefix(s, "$")
+
+	segments, rest, err := parsePath(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath %q: %w", expr, err)
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", expr, rest)
+	}
+	return &JSONPath{expr: expr, segments: segments}, nil
+}
+
+// parsePath parses segments until the input ends or something that isn't a
+// segment follows, which is returned
+func parsePath(s string) ([]pathSegment, string, error) {
+	var segments []pathSegment
+	for s != "" {
+		switch {
+		case strings.HasPrefix(s, ".*"):
+			segments = append(segments, pathSegment{kind: segWildcard})
+			s = s[2:]
+		case s[0] == '.':
+			end := 1
+			for end < len(s) && (isIdentChar(s[end])) {
+				end++
+			}
+			if end == 1 {
+				return nil, s, fmt.Errorf("expected a field name after '.'")
+			}
+			segments = append(segments, pathSegment{kind: segField, field: s[1:end]})
+			s = s[end:]
+		case s[0] == '[':
+			segment, rest, err := parseBracket(s)
+			if err != nil {
+				return nil, s, err
+			}
+			segments = append(segments, segment)
+			s = rest
+		default:
+			return segments, s, nil
+		}
+	}
+	return segments, "", nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '-' || c == '/' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func parseBracket(s string) (pathSegment, string, error) {
+	switch {
+	case strings.HasPrefix(s, "[*]"):
+		return pathSegment{kind: segWildcard}, s[3:], nil
+	case strings.HasPrefix(s, "['") || strings.HasPrefix(s, `["`):
+		quote := s[1]
+		end := strings.IndexByte(s[2:], quote)
+		if end < 0 || !strings.HasPrefix(s[2+end+1:], "]") {
+			return pathSegment{}, s, fmt.Errorf("unterminated field name")
+		}
+		return pathSegment{kind: segField, field: s[2 : 2+end]}, s[2+end+2:], nil
+	case strings.HasPrefix(s, "[?(@"):
+		path, rest, err := parsePath(s[4:])
+		if err != nil {
+			return pathSegment{}, s, err
+		}
+		segment := pathSegment{kind: segFilter, path: path}
+		rest = strings.TrimSpace(rest)
+		for _, op := range []string{"==", "!="} {
+			if strings.HasPrefix(rest, op) {
+				segment.op = op
+				rest = strings.TrimSpace(rest[len(op):])
+				end := strings.Index(rest, ")]")
+				if end < 0 {
+					return pathSegment{}, s, fmt.Errorf("unterminated filter")
+				}
+				literal, err := parseLiteral(strings.TrimSpace(rest[:end]))
+				if err != nil {
+					return pathSegment{}, s, err
+				}
+				segment.literal = literal
+				rest = rest[end:]
+				break
+			}
+		}
+		if !strings.HasPrefix(rest, ")]") {
+			return pathSegment{}, s, fmt.Errorf("unsupported filter, use [?(@.field==\"value\")]")
+		}
+		return segment, rest[2:], nil
+	}
+
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return pathSegment{}, s, fmt.Errorf("unterminated '['")
+	}
+	index, err := strconv.Atoi(strings.TrimSpace(s[1:end]))
+	if err != nil {
+		return pathSegment{}, s, fmt.Errorf("invalid index %q", s[1:end])
+	}
+	return pathSegment{kind: segIndex, index: index}, s[end+1:], nil
+}
+
+func parseLiteral(s string) (interface{}, error) {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1], nil
+	}
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return n, nil
+	}
+	return nil, fmt.Errorf("invalid literal %s", s)
+}
+
+func (p *JSONPath) String() string {
+	return p.expr
+}
+
+// Eval returns the values the path selects in decoded JSON
+func (p *JSONPath) Eval(data interface{}) []interface{} {
+	return evalSegments(p.segments, []interface{}{data})
+}
+
+func evalSegments(segments []pathSegment, current []interface{}) []interface{} {
+	for _, segment := range segments {
+		var next []interface{}
+		for _, value := range current {
+			next = append(next, evalSegment(segment, value)...)
+		}
+		current = next
+	}
+	return current
+}
+
+func evalSegment(segment pathSegment, value interface{}) []interface{} {
+	switch segment.kind {
+	case segField:
+		if m, ok := value.(map[string]interface{}); ok {
+			if v, ok := m[segment.field]; ok {
+				return []interface{}{v}
+			}
+		}
+	case segIndex:
+		if list, ok := value.([]interface{}); ok {
+			i := segment.index
+			if i < 0 {
+				i += len(list)
+			}
+			if i >= 0 && i < len(list) {
+				return []interface{}{list[i]}
+			}
+		}
+	case segWildcard:
+		switch v := value.(type) {
+		case []interface{}:
+			return v
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			values := make([]interface{}, len(keys))
+			for i, k := range keys {
+				values[i] = v[k]
+			}
+			return values
+		}
+	case segFilter:
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		var matches []interface{}
+		for _, item := range list {
+			if filterMatches(segment, item) {
+				matches = append(matches, item)
+			}
+		}
+		return matches
+	}
+	return nil
+}
+
+func filterMatches(segment pathSegment, item interface{}) bool {
+	found := evalSegments(segment.path, []interface{}{item})
+	if segment.op == "" {
+		return len(found) > 0
+	}
+	equal := false
+	for _, v := range found {
+		switch v.(type) {
+		case string, float64, bool:
+			equal = equal || v == segment.literal
+		}
+	}
+	if segment.op == "!=" {
+		return !equal
+	}
+	return equal
+}
+
+// Capture evaluates the path against JSON output and formats the result
+// like kubectl: values separated by spaces, objects and lists as JSON. A
+// path that selects nothing is an error.
+func (p *JSONPath) Capture(output string) (string, error) {
+	var data interface{}
+	if err := json.Unmarshal([]byte(output), &data); err != nil {
+		return "", fmt.Errorf("output is not JSON (add -o json to the command)")
+	}
+
+	values := p.Eval(data)
+	if len(values) == 0 {
+		return "", fmt.Errorf("%s selects nothing in the output", p.expr)
+	}
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			parts = append(parts, v)
+		case nil:
+		case float64:
+			parts = append(parts, strconv.FormatFloat(v, 'f', -1, 64))
+		case map[string]interface{}, []interface{}:
+			encoded, _ := json.Marshal(v)
+			parts = append(parts, string(encoded))
+		default:
+			parts = append(parts, fmt.Sprint(v))
+		}
+	}
+	return strings.Join(parts, " "), nil
+}
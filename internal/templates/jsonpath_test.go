@@ -0,0 +1,91 @@
+package templates
+
+import "testing"
+
+const deployment = `{
+  "metadata": {"name": "web", "labels": {"app.kubernetes.io/name": "web", "tier": "frontend"}},
+  "spec": {"replicas": 3, "paused": false},
+  "status": {
+    "readyReplicas": 2,
+    "conditions": [
+      {"type": "Available", "status": "True", "reason": "MinimumReplicasAvailable"},
+      {"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded"}
+    ]
+  }
+}`
+
+func TestJSONPathCapture(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"{.metadata.name}", "web"},
+		{".metadata.name", "web"},
+		{"$.status.readyReplicas", "2"},
+		{"{.spec.paused}", "false"},
+		{"{.metadata.labels['app.kubernetes.io/name']}", "web"},
+		{`{.metadata.labels["tier"]}`, "frontend"},
+		{"{.status.conditions[0].type}", "Available"},
+		{"{.status.conditions[-1].type}", "Progressing"},
+		{"{.status.conditions[*].type}", "Available Progressing"},
+		{"{.status.conditions.*.status}", "True False"},
+		{"{.metadata.labels.*}", "web frontend"},
+		{`{.status.conditions[?(@.type=="Progressing")].reason}`, "ProgressDeadlineExceeded"},
+		{`{.status.conditions[?(@.type == 'Available')].status}`, "True"},
+		{`{.status.conditions[?(@.status!="True")].type}`, "Progressing"},
+		{`{.status.conditions[?(@.reason)].type}`, "Available Progressing"},
+		{"{.spec}", `{"paused":false,"replicas":3}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			path, err := CompileJSONPath(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := path.Capture(deployment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("captured %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileJSONPathErrors(t *testing.T) {
+	for _, expr := range []string{
+		"{.metadata.name",
+		".",
+		"{.status.conditions[}",
+		"{.status.conditions[x]}",
+		"{.metadata.labels['tier}",
+		`{.status.conditions[?(@.type=="Ready"}`,
+		`{.status.conditions[?(@.type=Ready)]}`,
+		`{.status.conditions[?(@.type==Ready)]}`,
+		"{.metadata name}",
+	} {
+		if _, err := CompileJSONPath(expr); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+
+	path, _ := CompileJSONPath("{.metadata.name}")
+	if _, err := path.Capture("NAME   READY\nweb    1/1"); err == nil {
+		t.Error("expected an error for output that isn't JSON")
+	}
+	for _, expr := range []string{
+		"{.status.conditions[-3].type}",
+		"{.status.conditions[5].type}",
+		"{.status.conditions[?(@.missing)].type}",
+		"{.missing.field}",
+	} {
+		path, err := CompileJSONPath(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := path.Capture(deployment); err == nil {
+			t.Errorf("%s: expected an error for a path that selects nothing", expr)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parameter types
+const (
+	TypeString       = "string"
+	TypeInt          = "int"
+	TypeBool         = "bool"
+	TypeEnum         = "enum"
+	TypeDuration     = "duration"
+	TypeResourceName = "resource-name"
+)
+
+var types = []string{TypeString, TypeInt, TypeBool, TypeEnum, TypeDuration, TypeResourceName}
+
+// resourceNamePattern is a Kubernetes object name (a DNS-1123 subdomain)
+var resourceNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// Kind returns the parameter's type, defaulting to TypeString
+func (p Parameter) Kind() string {
+	if p.Type == "" {
+		return TypeString
+	}
+	return p.Type
+}
+
+// check validates the parameter definition, returning the field at fault
+func (p Parameter) check() (string, error) {
+	known := false
+	for _, t := range types {
+		known = known || p.Kind() == t
+	}
+	if !known {
+		return "type", fmt.Errorf("unknown type %q (expected one of: %s)", p.Type, strings.Join(types, ", "))
+	}
+	if p.Kind() == TypeEnum && len(p.Values) == 0 {
+		return "type", fmt.Errorf("enum parameters need a list of values")
+	}
+	if p.Kind() != TypeEnum && len(p.Values) > 0 {
+		return "values", fmt.Errorf("values are only allowed for enum parameters")
+	}
+	if p.Pattern != "" {
+		if _, err := regexp.Compile(p.Pattern); err != nil {
+			return "pattern", fmt.Errorf("invalid pattern: %v", err)
+		}
+	}
+	if p.Default != "" {
+		if _, err := p.Parse(p.Default); err != nil {
+			return "default", fmt.Errorf("invalid default: %v", err)
+		}
+	}
+	return "", nil
+}
+
+// Parse validates a value given for the parameter and converts it to its
+// type: int and bool values become int and bool so templates can compute
+// and branch on them, the others stay strings
+func (p Parameter) Parse(value string) (interface{}, error) {
+	if p.Pattern != "" {
+		if !regexp.MustCompile(`^(?:` + p.Pattern + `)$`).MatchString(value) {
+			return nil, fmt.Errorf("%q does not match the pattern %s", value, p.Pattern)
+		}
+	}
+
+	switch p.Kind() {
+	case TypeInt:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", value)
+		}
+		return n, nil
+	case TypeBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean (use true or false)", value)
+		}
+		return b, nil
+	case TypeEnum:
+		for _, allowed := range p.Values {
+			if value == allowed {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not one of: %s", value, strings.Join(p.Values, ", "))
+	case TypeDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("%q is not a duration (e.g. 30s, 5m, 1h)", value)
+		}
+	case TypeResourceName:
+		if len(value) > 253 || !resourceNamePattern.MatchString(value) {
+			return nil, fmt.Errorf("%q is not a valid resource name (lowercase letters, digits, '-' and '.')", value)
+		}
+	}
+	return value, nil
+}
+
+// Hint describes the accepted values, for prompts and help text
+func (p Parameter) Hint() string {
+	switch p.Kind() {
+	case TypeEnum:
+		return strings.Join(p.Values, "|")
+	case TypeBool:
+		return "true|false"
+	case TypeString:
+		return ""
+	}
+	return p.Kind()
+}
+
+// Prompter asks the user for the value of a missing parameter, with the
+// problem of the previous answer if it was invalid. ok is false when it
+// can't ask, e.g. without a terminal.
+type Prompter func(p Parameter, invalid error) (value string, ok bool, err error)
+
+// Resolve validates the provided parameter values and fills in missing ones
+// from defaults, or by asking for required ones. Parameters that are
+// neither given nor required are false for bools and empty otherwise, so
+// they disappear from the command.
+func (t *Template) Resolve(provided map[string]string, ask Prompter) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	for _, p := range t.Parameters {
+		raw, given := provided[p.Name]
+		if !given || raw == "" {
+			raw, given = p.Default, p.Default != ""
+		}
+
+		if !given && p.Required && ask != nil {
+			var invalid error
+			for {
+				value, ok, err := ask(p, invalid)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					break
+				}
+				if value == "" {
+					invalid = fmt.Errorf("a value is required")
+					continue
+				}
+				if _, invalid = p.Parse(value); invalid == nil {
+					raw, given = value, true
+					break
+				}
+			}
+		}
+
+		switch {
+		case given:
+			value, err := p.Parse(raw)
+			if err != nil {
+				return nil, fmt.Errorf("parameter %s: %w", p.Name, err)
+			}
+			values[p.Name] = value
+		case p.Required:
+			return nil, fmt.Errorf("parameter %s is required", p.Name)
+		case p.Kind() == TypeBool:
+			values[p.Name] = false
+		default:
+			values[p.Name] = ""
+		}
+	}
+	return values, nil
+}
+
+// Mask returns a copy of values with sensitive values replaced, for
+// rendering a command that is safe to print or record
+func (t *Template) Mask(values map[string]interface{}) map[string]interface{} {
+	masked := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		masked[k] = v
+	}
+	for _, p := range t.Parameters {
+		if p.Sensitive {
+			masked[p.Name] = Safe("********")
+		}
+	}
+	return masked
+}
+
+// Redact replaces the sensitive values found in text
+func (t *Template) Redact(text string, values map[string]interface{}) string {
+	for _, p := range t.Parameters {
+		if value, ok := values[p.Name].(string); ok && p.Sensitive && value != "" {
+			text = strings.ReplaceAll(text, value, "********")
+		}
+	}
+	return text
+}
+
+// Sensitive reports whether any parameter is sensitive
+func (t *Template) Sensitive() bool {
+	for _, p := range t.Parameters {
+		if p.Sensitive {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package templates
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	params := []Parameter{
+		{Name: "replicas", Type: TypeInt},
+		{Name: "wait", Type: TypeBool},
+		{Name: "mode", Type: TypeEnum, Values: []string{"fast", "safe"}, Default: "safe"},
+		{Name: "timeout", Type: TypeDuration, Default: "5m"},
+		{Name: "name", Type: TypeResourceName},
+		{Name: "tag", Pattern: `v\d+`},
+		{Name: "limit", Type: TypeInt, Default: "10"},
+	}
+	tests := []struct {
+		name     string
+		provided map[string]string
+		want     map[string]interface{}
+		err      string
+	}{
+		{
+			name:     "values are converted to their type",
+			provided: map[string]string{"replicas": " 3 ", "wait": "true", "mode": "fast", "timeout": "30s", "name": "web-1", "tag": "v2", "limit": "0"},
+			want:     map[string]interface{}{"replicas": 3, "wait": true, "mode": "fast", "timeout": "30s", "name": "web-1", "tag": "v2", "limit": 0},
+		},
+		{
+			name:     "defaults are converted too",
+			provided: map[string]string{"wait": "0", "mode": ""},
+			want:     map[string]interface{}{"replicas": "", "wait": false, "mode": "safe", "timeout": "5m", "name": "", "tag": "", "limit": 10},
+		},
+		{name: "int", provided: map[string]string{"replicas": "three"}, err: `parameter replicas: "three" is not an integer`},
+		{name: "bool", provided: map[string]string{"wait": "yes"}, err: `parameter wait: "yes" is not a boolean (use true or false)`},
+		{name: "enum", provided: map[string]string{"mode": "Fast"}, err: `parameter mode: "Fast" is not one of: fast, safe`},
+		{name: "duration", provided: map[string]string{"timeout": "5"}, err: `parameter timeout: "5" is not a duration (e.g. 30s, 5m, 1h)`},
+		{name: "resource name", provided: map[string]string{"name": "Web_1"}, err: `parameter name: "Web_1" is not a valid resource name (lowercase letters, digits, '-' and '.')`},
+		{name: "pattern is anchored", provided: map[string]string{"tag": "v2-rc"}, err: `parameter tag: "v2-rc" does not match the pattern v\d+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := (&Template{Name: "t", Parameters: params}).Resolve(tt.provided, nil)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(values, tt.want) {
+				t.Errorf("values = %#v, want %#v", values, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePrompts(t *testing.T) {
+	tmpl := &Template{Name: "t", Parameters: []Parameter{{Name: "replicas", Type: TypeInt, Required: true}}}
+
+	var problems []string
+	answers := []string{"", "many", "4"}
+	ask := func(p Parameter, invalid error) (string, bool, error) {
+		problems = append(problems, fmt.Sprint(invalid))
+		answer := answers[0]
+		answers = answers[1:]
+		return answer, true, nil
+	}
+	values, err := tmpl.Resolve(nil, ask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["replicas"] != 4 {
+		t.Errorf("replicas = %#v, want 4", values["replicas"])
+	}
+	want := []string{"<nil>", "a value is required", `"many" is not an integer`}
+	if strings.Join(problems, "|") != strings.Join(want, "|") {
+		t.Errorf("prompted with %q, want %q", problems, want)
+	}
+
+	// Without a terminal to ask, a missing required value is an error
+	noTerminal := func(Parameter, error) (string, bool, error) { return "", false, nil }
+	if _, err := tmpl.Resolve(nil, noTerminal); err == nil || err.Error() != "parameter replicas is required" {
+		t.Errorf("err = %v", err)
+	}
+	if _, err := tmpl.Resolve(nil, nil); err == nil {
+		t.Error("expected an error without a prompter")
+	}
+}
@@ -0,0 +1,153 @@
+package templates
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults of a step's wait loop
+const (
+	DefaultWaitInterval = 5 * time.Second
+	DefaultWaitTimeout  = 5 * time.Minute
+)
+
+// Step is one command of a runbook
+type Step struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Command     string `json:"command" yaml:"command"`
+	// When is a condition template; the step is skipped if it renders false
+	When string `json:"when,omitempty" yaml:"when,omitempty"`
+	// Confirm asks before running the step even when confirmations are off
+	Confirm bool `json:"confirm,omitempty" yaml:"confirm,omitempty"`
+	// Capture maps variable names to JSONPath expressions evaluated on the
+	// command's JSON output; later steps use them like parameters
+	Capture map[string]string `json:"capture,omitempty" yaml:"capture,omitempty"`
+	Wait    *Wait             `json:"wait,omitempty" yaml:"wait,omitempty"`
+}
+
+// Wait reruns a step's command until a condition holds
+type Wait struct {
+	// Until is a condition template evaluated after every run, with the
+	// step's captured variables
+	Until    string `json:"until" yaml:"until"`
+	Interval string `json:"interval,omitempty" yaml:"interval,omitempty"`
+	Timeout  string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// IsRunbook reports whether the template has steps rather than a command
+func (t *Template) IsRunbook() bool {
+	return len(t.Steps) > 0
+}
+
+// Durations returns the wait's interval and timeout, with defaults
+func (w *Wait) Durations() (interval, timeout time.Duration) {
+	interval, timeout = DefaultWaitInterval, DefaultWaitTimeout
+	if d, err := time.ParseDuration(w.Interval); err == nil && d > 0 {
+		interval = d
+	}
+	if d, err := time.ParseDuration(w.Timeout); err == nil && d > 0 {
+		timeout = d
+	}
+	return interval, timeout
+}
+
+// CaptureNames returns the step's captured variable names in order
+func (s Step) CaptureNames() []string {
+	names := make([]string, 0, len(s.Capture))
+	for name := range s.Capture {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Truthy interprets a rendered condition: empty, false, 0, no and missing
+// values are false
+func Truthy(rendered string) bool {
+	switch strings.ToLower(strings.TrimSpace(rendered)) {
+	case "", "false", "0", "no", "<no value>":
+		return false
+	}
+	return true
+}
+
+// validateSteps checks a runbook's steps. Templates of a step may use the
+// parameters and the variables captured by earlier steps; a wait condition
+// may also use the step's own captures.
+func (t *Template) validateSteps(node *yaml.Node, path string, params map[string]bool) []error {
+	var errs []error
+	fail := func(n *yaml.Node, format string, args ...interface{}) {
+		errs = append(errs, nodeError(path, n, fmt.Sprintf(format, args...)))
+	}
+	const unknown = "is not a parameter or a variable captured by an earlier step"
+
+	known := make(map[string]bool)
+	for name := range params {
+		known[name] = true
+	}
+	names := make(map[string]bool)
+	for i, step := range t.Steps {
+		sNode := node
+		if node != nil && i < len(node.Content) {
+			sNode = node.Content[i]
+		}
+
+		switch {
+		case step.Name == "":
+			fail(sNode, "step %d: missing required field \"name\"", i+1)
+		case !stepNamePattern.MatchString(step.Name):
+			fail(valueNode(sNode, "name"), "invalid step name %q: use letters, digits, '-' and '_'", step.Name)
+		case names[step.Name]:
+			fail(valueNode(sNode, "name"), "step %q is declared twice", step.Name)
+		}
+		names[step.Name] = true
+
+		if step.When != "" {
+			errs = append(errs, checkTemplate(path, valueNode(sNode, "when"), "when", step.When, known, unknown)...)
+		}
+		if strings.TrimSpace(step.Command) == "" {
+			fail(sNode, "step %q: missing required field \"command\"", step.Name)
+		} else {
+			errs = append(errs, checkTemplate(path, valueNode(sNode, "command"), "command", step.Command, known, unknown)...)
+		}
+
+		captureNode := valueNode(sNode, "capture")
+		for _, name := range step.CaptureNames() {
+			switch {
+			case !paramPattern.MatchString(name):
+				fail(captureNode, "invalid variable name %q: use letters, digits and '_'", name)
+			case params[name]:
+				fail(captureNode, "captured variable %q has the name of a parameter", name)
+			case IsBuiltin(name):
+				fail(captureNode, "captured variable %q has the name of a built-in variable", name)
+			}
+			if _, err := CompileJSONPath(step.Capture[name]); err != nil {
+				fail(valueNode(captureNode, name), "%v", err)
+			}
+			known[name] = true
+		}
+
+		if step.Wait != nil {
+			waitNode := valueNode(sNode, "wait")
+			if strings.TrimSpace(step.Wait.Until) == "" {
+				fail(waitNode, "step %q: wait needs an \"until\" condition", step.Name)
+			} else {
+				errs = append(errs, checkTemplate(path, valueNode(waitNode, "until"), "until", step.Wait.Until, known, unknown)...)
+			}
+			for _, field := range [][2]string{{"interval", step.Wait.Interval}, {"timeout", step.Wait.Timeout}} {
+				if field[1] == "" {
+					continue
+				}
+				if d, err := time.ParseDuration(field[1]); err != nil || d <= 0 {
+					fail(valueNode(waitNode, field[0]), "invalid %s %q: use a duration such as 10s or 5m", field[0], field[1])
+				}
+			}
+		}
+	}
+	return errs
+}
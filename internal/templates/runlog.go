@@ -0,0 +1,263 @@
+package templates
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"oc-ai/internal/config"
+	"oc-ai/internal/fsutil"
+)
+
+// Statuses of a runbook step
+const (
+	StepPending   = "pending"
+	StepSucceeded = "succeeded"
+	StepFailed    = "failed"
+	StepSkipped   = "skipped"
+)
+
+// maxStepOutput bounds the output stored for each step of a run
+const maxStepOutput = 4096
+
+// Run is the log of a runbook execution. It is checkpointed after every
+// step, so an interrupted or failed run can be resumed where it stopped.
+type Run struct {
+	ID       string `json:"id"`
+	Template string `json:"template"`
+	Tool     string `json:"tool"`
+	Context  string `json:"context,omitempty"`
+	// Params are the parameter values, without sensitive ones, which are
+	// asked for again on resume
+	Params map[string]string `json:"params"`
+	// Vars are the variables captured so far
+	Vars map[string]string `json:"vars"`
+	// MaskedVars are the variables whose values were redacted in the log,
+	// which are asked for again on resume
+	MaskedVars []string  `json:"masked_vars,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Steps      []StepRun `json:"steps"`
+
+	// Redact, if set, masks secrets in step outputs and captured variables
+	// before they are written to the log
+	Redact func(string) string `json:"-"`
+
+	path string
+}
+
+// StepRun is the outcome of a runbook step
+type StepRun struct {
+	Name       string     `json:"name"`
+	Command    string     `json:"command,omitempty"`
+	Status     string     `json:"status"`
+	Reason     string     `json:"reason,omitempty"`
+	Attempts   int        `json:"attempts,omitempty"`
+	Output     string     `json:"output,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// NewRun starts the log of a runbook execution
+func NewRun(t *Template, tool, context string, params map[string]string) *Run {
+	r := &Run{
+		Template: t.Name,
+		Tool:     tool,
+		Context:  context,
+		Params:   params,
+		Vars:     make(map[string]string),
+	}
+	for _, step := range t.Steps {
+		r.Steps = append(r.Steps, StepRun{Name: step.Name, Status: StepPending})
+	}
+	return r
+}
+
+// Matches reports whether the run was started from a runbook with the same
+// steps, so it can be resumed with it
+func (r *Run) Matches(t *Template) bool {
+	if len(r.Steps) != len(t.Steps) {
+		return false
+	}
+	for i, step := range t.Steps {
+		if r.Steps[i].Name != step.Name {
+			return false
+		}
+	}
+	return true
+}
+
+// NextStep returns the index of the first step that hasn't succeeded or
+// been skipped, or -1 if the run is complete
+func (r *Run) NextStep() int {
+	for i, step := range r.Steps {
+		if step.Status != StepSucceeded && step.Status != StepSkipped {
+			return i
+		}
+	}
+	return -1
+}
+
+// Status summarizes the run's progress, e.g. "2/3 done" or "failed at step 2"
+func (r *Run) Status() string {
+	done := 0
+	for i, step := range r.Steps {
+		switch step.Status {
+		case StepSucceeded, StepSkipped:
+			done++
+		case StepFailed:
+			return fmt.Sprintf("failed at step %d", i+1)
+		}
+	}
+	if done == len(r.Steps) {
+		return "completed"
+	}
+	return fmt.Sprintf("%d/%d done", done, len(r.Steps))
+}
+
+// Finish records the outcome of a step and checkpoints the run
+func (r *Run) Finish(i int, command, output string, err error) error {
+	now := time.Now()
+	step := &r.Steps[i]
+	step.Command = command
+	step.Status = StepSucceeded
+	step.Error = ""
+	if err != nil {
+		step.Status = StepFailed
+		step.Error = err.Error()
+	}
+	if len(output) > maxStepOutput {
+		output = output[:maxStepOutput] + "\n... (truncated)"
+	}
+	if r.Redact != nil {
+		output = r.Redact(output)
+		step.Error = r.Redact(step.Error)
+	}
+	step.Output = output
+	step.FinishedAt = &now
+	return r.Save()
+}
+
+// Skip marks a step as skipped and checkpoints the run
+func (r *Run) Skip(i int, reason string) error {
+	now := time.Now()
+	r.Steps[i].Status = StepSkipped
+	r.Steps[i].Reason = reason
+	r.Steps[i].FinishedAt = &now
+	return r.Save()
+}
+
+// Save writes the run to <config dir>/runs/<id>.json, assigning an id on
+// first save
+func (r *Run) Save() error {
+	if r.path == "" {
+		dir, err := runsDir()
+		if err != nil {
+			return err
+		}
+		if r.ID == "" {
+			suffix := make([]byte, 2)
+			rand.Read(suffix)
+			r.ID = time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+		}
+		if r.CreatedAt.IsZero() {
+			r.CreatedAt = time.Now()
+		}
+		r.path = filepath.Join(dir, r.ID+".json")
+	}
+
+	r.UpdatedAt = time.Now()
+	logged := *r
+	if r.Redact != nil {
+		logged.Vars = make(map[string]string, len(r.Vars))
+		logged.MaskedVars = nil
+		for name, value := range r.Vars {
+			logged.Vars[name] = r.Redact(value)
+			if logged.Vars[name] != value {
+				logged.MaskedVars = append(logged.MaskedVars, name)
+			}
+		}
+		sort.Strings(logged.MaskedVars)
+	}
+	data, err := json.MarshalIndent(&logged, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal run: %w", err)
+	}
+	// Outputs may contain cluster data, so the log is only readable by the user
+	return fsutil.WriteFileAtomic(r.path, data, 0600)
+}
+
+// LoadRun reads a saved run by id
+func LoadRun(id string) (*Run, error) {
+	dir, err := runsDir()
+	if err != nil {
+		return nil, err
+	}
+	if id != filepath.Base(id) {
+		return nil, fmt.Errorf("invalid run id %q", id)
+	}
+
+	path := filepath.Join(dir, id+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("run %s not found", id)
+		}
+		return nil, fmt.Errorf("failed to read run: %w", err)
+	}
+
+	var r Run
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse run %s: %w", id, err)
+	}
+	if r.Vars == nil {
+		r.Vars = make(map[string]string)
+	}
+	// Masked values are placeholders, not the captured values
+	for _, name := range r.MaskedVars {
+		delete(r.Vars, name)
+	}
+	r.path = path
+	return &r, nil
+}
+
+// ListRuns returns all saved runs, most recently updated first
+func ListRuns() ([]*Run, error) {
+	dir, err := runsDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+
+	var runs []*Run
+	for _, path := range paths {
+		r, err := LoadRun(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			continue
+		}
+		runs = append(runs, r)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].UpdatedAt.After(runs[j].UpdatedAt)
+	})
+	return runs, nil
+}
+
+func runsDir() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(configDir, "runs")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create runs directory: %w", err)
+	}
+	return dir, nil
+}
@@ -0,0 +1,101 @@
+package templates
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRunNextStep(t *testing.T) {
+	tests := []struct {
+		statuses []string
+		next     int
+		status   string
+	}{
+		{[]string{StepPending, StepPending}, 0, "0/2 done"},
+		{[]string{StepSucceeded, StepPending}, 1, "1/2 done"},
+		{[]string{StepSkipped, StepSucceeded, StepPending}, 2, "2/3 done"},
+		{[]string{StepSucceeded, StepFailed, StepPending}, 1, "failed at step 2"},
+		{[]string{StepSucceeded, StepSkipped}, -1, "completed"},
+	}
+	for _, tt := range tests {
+		r := &Run{}
+		for _, status := range tt.statuses {
+			r.Steps = append(r.Steps, StepRun{Status: status})
+		}
+		if next := r.NextStep(); next != tt.next {
+			t.Errorf("%v: NextStep() = %d, want %d", tt.statuses, next, tt.next)
+		}
+		if status := r.Status(); status != tt.status {
+			t.Errorf("%v: Status() = %q, want %q", tt.statuses, status, tt.status)
+		}
+	}
+}
+
+func TestRunMatches(t *testing.T) {
+	runbook := &Template{Name: "rollout", Steps: []Step{{Name: "scale"}, {Name: "wait"}}}
+	run := NewRun(runbook, "oc", "dev", nil)
+
+	tests := []struct {
+		name  string
+		steps []Step
+		want  bool
+	}{
+		{"same steps", []Step{{Name: "scale"}, {Name: "wait"}}, true},
+		{"changed commands", []Step{{Name: "scale", Command: "scale --replicas=5"}, {Name: "wait"}}, true},
+		{"step added", []Step{{Name: "scale"}, {Name: "wait"}, {Name: "verify"}}, false},
+		{"step removed", []Step{{Name: "scale"}}, false},
+		{"steps reordered", []Step{{Name: "wait"}, {Name: "scale"}}, false},
+		{"step renamed", []Step{{Name: "scale"}, {Name: "check"}}, false},
+	}
+	for _, tt := range tests {
+		if got := run.Matches(&Template{Name: "rollout", Steps: tt.steps}); got != tt.want {
+			t.Errorf("%s: Matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunSaveRedacts(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	runbook := &Template{Name: "rotate", Steps: []Step{{Name: "token"}, {Name: "use"}}}
+	run := NewRun(runbook, "oc", "dev", nil)
+	run.Redact = func(s string) string { return strings.ReplaceAll(s, "s3cret", "REDACTED_SECRET_1") }
+
+	run.Vars["token"] = "s3cret"
+	run.Vars["pod"] = "web-1"
+	if err := run.Finish(0, "get secret", `{"token":"s3cret"}`, nil); err != nil {
+		t.Fatal(err)
+	}
+	if run.Vars["token"] != "s3cret" {
+		t.Errorf("in-memory token = %q, want the captured value", run.Vars["token"])
+	}
+
+	data, err := os.ReadFile(run.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Errorf("run log contains the secret:\n%s", data)
+	}
+	info, err := os.Stat(run.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("run log mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	loaded, err := LoadRun(run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.MaskedVars) != 1 || loaded.MaskedVars[0] != "token" {
+		t.Errorf("MaskedVars = %v, want [token]", loaded.MaskedVars)
+	}
+	if _, ok := loaded.Vars["token"]; ok {
+		t.Error("masked variable was loaded with its placeholder")
+	}
+	if loaded.Vars["pod"] != "web-1" {
+		t.Errorf("pod = %q, want web-1", loaded.Vars["pod"])
+	}
+}
@@ -0,0 +1,298 @@
+package templates
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"oc-ai/internal/config"
+	"oc-ai/internal/fsutil"
+)
+
+// Scopes of template directories, from highest to lowest precedence
+const (
+	ScopeProject = "project"
+	ScopeUser    = "user"
+	ScopeTeam    = "team"
+)
+
+// Precedence ranks a scope; lower ranks take precedence
+func Precedence(scope string) int {
+	switch scope {
+	case ScopeProject:
+		return 0
+	case ScopeUser:
+		return 1
+	}
+	return 2
+}
+
+// ProjectDir is the directory, relative to a project root, holding the
+// project's templates
+var ProjectDir = filepath.Join(".oc-ai", "templates")
+
+// Dir is a directory (or single file) templates are loaded from
+type Dir struct {
+	Scope string
+	Path  string
+}
+
+// Dirs returns the template locations in precedence order: the nearest
+// project .oc-ai/templates directory, the user's templates (including the
+// legacy templates.json), then the shared team directories
+func Dirs(teamDirs []string) ([]Dir, error) {
+	var dirs []Dir
+	if project := FindProjectDir(); project != "" {
+		dirs = append(dirs, Dir{Scope: ScopeProject, Path: project})
+	}
+
+	configDir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	dirs = append(dirs,
+		Dir{Scope: ScopeUser, Path: filepath.Join(configDir, "templates")},
+		Dir{Scope: ScopeUser, Path: filepath.Join(configDir, "templates.json")},
+	)
+
+	for _, team := range teamDirs {
+		if team = expandHome(strings.TrimSpace(team)); team != "" {
+			dirs = append(dirs, Dir{Scope: ScopeTeam, Path: team})
+		}
+	}
+	return dirs, nil
+}
+
+// FindProjectDir returns the .oc-ai/templates directory of the current
+// directory or its nearest parent that has one
+func FindProjectDir() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		candidate := filepath.Join(dir, ProjectDir)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
+
+// Set is the templates loaded from all directories. A template defined in
+// several places is taken from the one with the highest precedence.
+type Set struct {
+	templates map[string]*Template
+	// Shadowed are definitions hidden by one with higher precedence
+	Shadowed []*Template
+	// Errors are problems in template files; invalid templates are skipped
+	Errors []error
+}
+
+// Load reads every template file of the given directories
+func Load(dirs []Dir) *Set {
+	s := &Set{templates: make(map[string]*Template)}
+	for _, dir := range dirs {
+		for _, path := range dir.Files() {
+			templates, errs := ParseFile(path)
+			s.Errors = append(s.Errors, errs...)
+			for _, t := range templates {
+				t.Scope = dir.Scope
+				if existing, ok := s.templates[t.Name]; ok {
+					if existing.Scope == t.Scope {
+						s.Errors = append(s.Errors, &Error{Path: path,
+							Message: fmt.Sprintf("template %q is also defined in %s, which takes precedence", t.Name, existing.Path)})
+					}
+					s.Shadowed = append(s.Shadowed, t)
+					continue
+				}
+				s.templates[t.Name] = t
+			}
+		}
+	}
+	return s
+}
+
+// Files lists the template files of the directory, or its path if it is a
+// file
+func (d Dir) Files() []string {
+	path := d.Path
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if !info.IsDir() {
+		return []string{path}
+	}
+
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+		matches, _ := filepath.Glob(filepath.Join(path, pattern))
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Get returns the effective template with the given name
+func (s *Set) Get(name string) (*Template, bool) {
+	t, ok := s.templates[name]
+	return t, ok
+}
+
+// InScope returns the definition of a template in the given scope, whether
+// it is effective or shadowed
+func (s *Set) InScope(name, scope string) (*Template, bool) {
+	if t, ok := s.templates[name]; ok && t.Scope == scope {
+		return t, true
+	}
+	for _, t := range s.Shadowed {
+		if t.Name == name && t.Scope == scope {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Overridden returns the definitions hidden by the effective template with
+// the given name
+func (s *Set) Overridden(name string) []*Template {
+	var hidden []*Template
+	for _, t := range s.Shadowed {
+		if t.Name == name {
+			hidden = append(hidden, t)
+		}
+	}
+	return hidden
+}
+
+// List returns the effective templates sorted by name
+func (s *Set) List() []*Template {
+	list := make([]*Template, 0, len(s.templates))
+	for _, t := range s.templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Write saves a template to <dir>/<name>.yaml (or .json) and returns the
+// path written. If that file already exists, the template is added to it,
+// replacing its definition there, and the file's other templates are kept.
+func Write(dir string, t *Template, asJSON bool) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create template directory: %w", err)
+	}
+	ext := ".yaml"
+	if asJSON {
+		ext = ".json"
+	}
+	path := filepath.Join(dir, t.Name+ext)
+	if _, err := os.Stat(path); err == nil {
+		return path, addToFile(path, t)
+	}
+
+	data, err := MarshalTemplate(t, asJSON)
+	if err != nil {
+		return "", err
+	}
+	// Never write a file that wouldn't load again
+	if _, errs := Parse(data, path); len(errs) > 0 {
+		return "", errs[0]
+	}
+	if err := fsutil.WriteFileAtomic(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write template: %w", err)
+	}
+	return path, nil
+}
+
+// addToFile adds a template to an existing template file
+func addToFile(path string, t *Template) error {
+	existing, errs := ParseFile(path)
+	if len(errs) > 0 {
+		return fmt.Errorf("cannot add template %q to %s: %w", t.Name, path, errs[0])
+	}
+	for _, e := range existing {
+		if e.Name == t.Name {
+			return Replace(e, t)
+		}
+	}
+
+	data, err := Marshal(append(existing, t), IsJSON(path))
+	if err != nil {
+		return err
+	}
+	if _, errs := Parse(data, path); len(errs) > 0 {
+		return errs[0]
+	}
+	if err := fsutil.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write template: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes a template from the file defining it. Files holding
+// several templates are rewritten without it.
+func Remove(t *Template) error {
+	return Replace(t, nil)
+}
+
+// Replace swaps a loaded template for a new version in the file defining
+// it, keeping the file's other templates. A nil replacement removes it, and
+// the file too if it was the last one.
+func Replace(old, replacement *Template) error {
+	existing, errs := ParseFile(old.Path)
+	if len(errs) > 0 {
+		return fmt.Errorf("cannot update %s: %w", old.Path, errs[0])
+	}
+
+	var updated []*Template
+	for _, t := range existing {
+		switch {
+		case t.Name != old.Name:
+			updated = append(updated, t)
+		case replacement != nil:
+			updated = append(updated, replacement)
+		}
+	}
+	if len(updated) == 0 {
+		if err := os.Remove(old.Path); err != nil {
+			return fmt.Errorf("failed to delete template: %w", err)
+		}
+		return nil
+	}
+
+	asJSON := IsJSON(old.Path)
+	var data []byte
+	var err error
+	if len(existing) == 1 && len(updated) == 1 {
+		data, err = MarshalTemplate(updated[0], asJSON)
+	} else {
+		data, err = Marshal(updated, asJSON)
+	}
+	if err != nil {
+		return err
+	}
+	if _, errs := Parse(data, old.Path); len(errs) > 0 {
+		return errs[0]
+	}
+	if err := fsutil.WriteFileAtomic(old.Path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write template: %w", err)
+	}
+	return nil
+}
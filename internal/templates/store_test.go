@@ -0,0 +1,107 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteKeepsOtherTemplates(t *testing.T) {
+	dir := t.TempDir()
+	shared := filepath.Join(dir, "ops.yaml")
+	if err := os.WriteFile(shared, []byte(`- name: restart
+  command: rollout restart deployment/web
+- name: scale
+  command: scale deployment/web --replicas=2
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		t     *Template
+		path  string
+		names []string
+	}{
+		{"new file", &Template{Name: "pods", Command: "get pods"}, "pods.yaml", []string{"pods"}},
+		{"added to a file of the same name", &Template{Name: "ops", Command: "get all"}, "ops.yaml", []string{"restart", "scale", "ops"}},
+		{"replaced in that file", &Template{Name: "ops", Command: "get all -A"}, "ops.yaml", []string{"restart", "scale", "ops"}},
+		{"replaced in a file of its own", &Template{Name: "pods", Command: "get pods -A"}, "pods.yaml", []string{"pods"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := Write(dir, tt.t, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != filepath.Join(dir, tt.path) {
+				t.Errorf("wrote %s, want %s", path, tt.path)
+			}
+			loaded, errs := ParseFile(path)
+			if len(errs) > 0 {
+				t.Fatal(errs[0])
+			}
+			var names []string
+			for _, l := range loaded {
+				names = append(names, l.Name)
+				if l.Name == tt.t.Name && l.Command != tt.t.Command {
+					t.Errorf("%s has command %q, want %q", l.Name, l.Command, tt.t.Command)
+				}
+			}
+			if len(names) != len(tt.names) {
+				t.Fatalf("file holds %v, want %v", names, tt.names)
+			}
+			for i := range names {
+				if names[i] != tt.names[i] {
+					t.Errorf("file holds %v, want %v", names, tt.names)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestWriteRefusesInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ops.yaml")
+	broken := []byte("- name: restart\n  command: [\n")
+	if err := os.WriteFile(path, broken, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Write(dir, &Template{Name: "ops", Command: "get all"}, false); err == nil {
+		t.Fatal("expected an error")
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(broken) {
+		t.Errorf("the file was changed:\n%s", data)
+	}
+}
+
+func TestLoadOverridden(t *testing.T) {
+	var dirs []Dir
+	for _, scope := range []string{ScopeProject, ScopeUser, ScopeTeam} {
+		dir := t.TempDir()
+		content := "name: pods\ncommand: get pods -l scope=" + scope + "\n"
+		if scope != ScopeProject {
+			content = "- name: pods\n  command: get pods\n- name: " + scope + "\n  command: get all\n"
+		}
+		if err := os.WriteFile(filepath.Join(dir, "t.yaml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, Dir{Scope: scope, Path: dir})
+	}
+
+	set := Load(dirs)
+	if len(set.Errors) > 0 {
+		t.Fatal(set.Errors[0])
+	}
+	if pods, _ := set.Get("pods"); pods.Scope != ScopeProject {
+		t.Errorf("pods is taken from %s, want the project", pods.Scope)
+	}
+	overridden := set.Overridden("pods")
+	if len(overridden) != 2 || overridden[0].Scope != ScopeUser || overridden[1].Scope != ScopeTeam {
+		t.Errorf("Overridden(pods) = %v, want the user and team definitions", overridden)
+	}
+	if overridden := set.Overridden(ScopeUser); len(overridden) != 0 {
+		t.Errorf("Overridden(%s) = %v, want none", ScopeUser, overridden)
+	}
+}
@@ -0,0 +1,438 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Template is a named, parameterized command, or a runbook of several
+// steps when Steps is set instead of Command
+type Template struct {
+	Name        string      `json:"name" yaml:"name"`
+	Description string      `json:"description,omitempty" yaml:"description,omitempty"`
+	Command     string      `json:"command,omitempty" yaml:"command,omitempty"`
+	Parameters  []Parameter `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Steps       []Step      `json:"steps,omitempty" yaml:"steps,omitempty"`
+
+	// Path and Scope tell where a loaded template was defined
+	Path  string `json:"-" yaml:"-"`
+	Scope string `json:"-" yaml:"-"`
+}
+
+// Parameter is a value substituted into a template's command
+type Parameter struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Type is one of the Type constants; empty means TypeString
+	Type     string `json:"type,omitempty" yaml:"type,omitempty"`
+	Required bool   `json:"required,omitempty" yaml:"required,omitempty"`
+	Default  string `json:"default,omitempty" yaml:"default,omitempty"`
+	// Values lists the allowed values of an enum
+	Values []string `json:"values,omitempty" yaml:"values,omitempty"`
+	// Pattern is a regular expression the whole value must match
+	Pattern string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	// Sensitive values are prompted for without echo and masked in output
+	Sensitive bool `json:"sensitive,omitempty" yaml:"sensitive,omitempty"`
+}
+
+// Error is a problem in a template file, with its position when known
+type Error struct {
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", e.Path, e.Line, e.Column, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+var (
+	namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	// Parameters and captured variables are referenced as {{.name}}, which
+	// can't contain '-'
+	paramPattern     = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+	stepNamePattern  = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+	yamlErrorPattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+)
+
+// IsJSON reports whether a template file uses JSON rather than YAML
+func IsJSON(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// ParseFile reads and validates the templates of a file
+func ParseFile(path string) ([]*Template, []error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, []error{&Error{Path: path, Message: err.Error()}}
+	}
+	return Parse(data, path)
+}
+
+// Parse decodes one template or a list of templates from YAML or JSON and
+// validates them. Every problem found is returned with its position in the
+// file; templates with problems are left out.
+func Parse(data []byte, path string) ([]*Template, []error) {
+	if IsJSON(path) {
+		// Report JSON syntax errors with JSON positions, then let the YAML
+		// parser (JSON is YAML) provide node positions. Valid JSON only has
+		// tabs as whitespace, which YAML doesn't allow for indentation.
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, []error{jsonError(data, path, err)}
+		}
+		data = bytes.ReplaceAll(data, []byte("\t"), []byte(" "))
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, yamlErrors(path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	root := doc.Content[0]
+	var nodes []*yaml.Node
+	switch root.Kind {
+	case yaml.MappingNode:
+		nodes = []*yaml.Node{root}
+	case yaml.SequenceNode:
+		nodes = root.Content
+	default:
+		return nil, []error{nodeError(path, root, "expected a template or a list of templates")}
+	}
+
+	var templates []*Template
+	var errs []error
+	seen := make(map[string]bool)
+	for _, node := range nodes {
+		t, tErrs := parseTemplate(node, path)
+		if len(tErrs) > 0 {
+			errs = append(errs, tErrs...)
+			continue
+		}
+		if seen[t.Name] {
+			errs = append(errs, nodeError(path, node, fmt.Sprintf("template %q is defined twice", t.Name)))
+			continue
+		}
+		seen[t.Name] = true
+		templates = append(templates, t)
+	}
+	return templates, errs
+}
+
+func parseTemplate(node *yaml.Node, path string) (*Template, []error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, []error{nodeError(path, node, "expected a template (a mapping with name and command)")}
+	}
+
+	errs := checkFields(node, reflect.TypeOf(Template{}), path)
+	var t Template
+	if err := node.Decode(&t); err != nil {
+		return nil, append(errs, yamlErrors(path, err)...)
+	}
+
+	errs = append(errs, t.validate(node, path)...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	t.Path = path
+	return &t, nil
+}
+
+// validate checks the values of a decoded template, reporting problems at
+// the position of the offending value
+func (t *Template) validate(node *yaml.Node, path string) []error {
+	var errs []error
+	fail := func(n *yaml.Node, format string, args ...interface{}) {
+		errs = append(errs, nodeError(path, n, fmt.Sprintf(format, args...)))
+	}
+
+	switch {
+	case t.Name == "":
+		fail(node, "missing required field \"name\"")
+	case !namePattern.MatchString(t.Name):
+		fail(valueNode(node, "name"), "invalid template name %q: use letters, digits, '-', '_' and '.'", t.Name)
+	}
+
+	params := make(map[string]bool)
+	paramNodes := valueNode(node, "parameters")
+	for i, p := range t.Parameters {
+		pNode := paramNodes
+		if paramNodes != nil && i < len(paramNodes.Content) {
+			pNode = paramNodes.Content[i]
+		}
+		switch {
+		case p.Name == "":
+			fail(pNode, "parameter %d: missing required field \"name\"", i+1)
+		case !paramPattern.MatchString(p.Name):
+			fail(valueNode(pNode, "name"), "invalid parameter name %q: use letters, digits and '_'", p.Name)
+		case params[p.Name]:
+			fail(valueNode(pNode, "name"), "parameter %q is declared twice", p.Name)
+		case IsBuiltin(p.Name):
+			fail(valueNode(pNode, "name"), "parameter %q has the name of a built-in variable", p.Name)
+		case reservedNames[p.Name]:
+			fail(valueNode(pNode, "name"), "parameter %q has the name of a global flag of oc-ai", p.Name)
+		}
+		params[p.Name] = true
+		if field, err := p.check(); err != nil {
+			fail(valueNode(pNode, field), "parameter %q: %v", p.Name, err)
+		}
+	}
+
+	switch {
+	case len(t.Steps) > 0 && strings.TrimSpace(t.Command) != "":
+		fail(valueNode(node, "steps"), "a template has either a command or steps, not both")
+	case len(t.Steps) > 0:
+		errs = append(errs, t.validateSteps(valueNode(node, "steps"), path, params)...)
+	case strings.TrimSpace(t.Command) == "":
+		fail(node, "missing required field \"command\" (or \"steps\" for a runbook)")
+	default:
+		errs = append(errs, checkTemplate(path, valueNode(node, "command"), "command", t.Command, params,
+			"is not a declared parameter")...)
+	}
+	return errs
+}
+
+// checkTemplate parses a template text of the file, reporting syntax errors
+// and fields that aren't known variables
+func checkTemplate(path string, node *yaml.Node, what, text string, known map[string]bool, unknown string) []error {
+	tree, err := template.New(what).Funcs(funcs).Parse(text)
+	if err != nil {
+		return []error{nodeError(path, node, fmt.Sprintf("invalid %s template: %s", what, templateErrorMessage(err)))}
+	}
+	// Commands are escaped by the quotes around their actions
+	if what == "command" {
+		if _, err := escapeActions(tree.Tree.Root, 0); err != nil {
+			return []error{nodeError(path, node, fmt.Sprintf("invalid %s template: %v", what, err))}
+		}
+	}
+	var errs []error
+	for _, field := range referencedFields(tree.Tree) {
+		if !known[field] && !IsBuiltin(field) {
+			errs = append(errs, nodeError(path, node, fmt.Sprintf("%s uses {{.%s}} but %q %s", what, field, field, unknown)))
+		}
+	}
+	return errs
+}
+
+// checkFields reports mapping keys that don't correspond to a field of typ,
+// recursing into nested structs and lists
+func checkFields(node *yaml.Node, typ reflect.Type, path string) []error {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	var errs []error
+	switch {
+	case typ.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := make(map[string]reflect.Type)
+		var names []string
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			fields[name] = f.Type
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				errs = append(errs, nodeError(path, key, fmt.Sprintf("unknown field %q (expected one of: %s)",
+					key.Value, strings.Join(names, ", "))))
+				continue
+			}
+			errs = append(errs, checkFields(value, fieldType, path)...)
+		}
+	case typ.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for _, item := range node.Content {
+			errs = append(errs, checkFields(item, typ.Elem(), path)...)
+		}
+	}
+	return errs
+}
+
+// valueNode returns the value of key in a mapping, or the mapping itself if
+// the key is missing so errors still point somewhere useful
+func valueNode(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return mapping
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return mapping
+}
+
+func nodeError(path string, node *yaml.Node, message string) *Error {
+	if node == nil {
+		return &Error{Path: path, Message: message}
+	}
+	return &Error{Path: path, Line: node.Line, Column: node.Column, Message: message}
+}
+
+// yamlErrors converts the errors of the YAML parser, which carry line
+// numbers in their text, into positioned errors
+func yamlErrors(path string, err error) []error {
+	var messages []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+
+	errs := make([]error, 0, len(messages))
+	for _, msg := range messages {
+		if m := yamlErrorPattern.FindStringSubmatch(msg); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			errs = append(errs, &Error{Path: path, Line: line, Message: m[2]})
+			continue
+		}
+		errs = append(errs, &Error{Path: path, Message: strings.TrimPrefix(msg, "yaml: ")})
+	}
+	return errs
+}
+
+// jsonError positions a JSON decoding error by its byte offset
+func jsonError(data []byte, path string, err error) *Error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// The offset is after the offending character
+		offset = syntaxErr.Offset - 1
+		if offset < 0 {
+			offset = 0
+		}
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return &Error{Path: path, Message: err.Error()}
+	}
+
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return &Error{Path: path, Line: line, Column: column, Message: err.Error()}
+}
+
+// templateErrorMessage strips the template name and position prefix that
+// text/template adds, since the file position is reported instead
+func templateErrorMessage(err error) string {
+	msg := err.Error()
+	if i := strings.LastIndex(msg, ": "); i >= 0 && strings.HasPrefix(msg, "template: ") {
+		return msg[i+2:]
+	}
+	return msg
+}
+
+// referencedFields lists the top-level fields ({{.name}}) a template uses,
+// ignoring those inside range and with blocks where dot changes
+func referencedFields(tree *parse.Tree) []string {
+	seen := make(map[string]bool)
+	var fields []string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				for _, arg := range cmd.Args {
+					walk(arg)
+				}
+			}
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.FieldNode:
+			if name := n.Ident[0]; !seen[name] {
+				seen[name] = true
+				fields = append(fields, name)
+			}
+		}
+	}
+	if tree != nil {
+		walk(tree.Root)
+	}
+	return fields
+}
+
+// Marshal encodes a list of templates as YAML or JSON
+func Marshal(templates []*Template, asJSON bool) ([]byte, error) {
+	return encode(templates, asJSON)
+}
+
+// MarshalTemplate encodes a single template as YAML or JSON
+func MarshalTemplate(t *Template, asJSON bool) ([]byte, error) {
+	return encode(t, asJSON)
+}
+
+func encode(v interface{}, asJSON bool) ([]byte, error) {
+	if asJSON {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal templates: %w", err)
+		}
+		return append(data, '\n'), nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to marshal templates: %w", err)
+	}
+	enc.Close()
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,79 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGlobalFlagParameters(t *testing.T) {
+	tests := []struct {
+		param string
+		err   string
+	}{
+		{"namespace", ""},
+		{"context", ""},
+		{"yes", "t.yaml:4:11: parameter \"yes\" has the name of a global flag of oc-ai"},
+		{"offline", "t.yaml:4:11: parameter \"offline\" has the name of a global flag of oc-ai"},
+		{"dry-run", "t.yaml:4:11: invalid parameter name \"dry-run\": use letters, digits and '_'"},
+		{"dry_run", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.param, func(t *testing.T) {
+			data := "name: t\ncommand: get pods\nparameters:\n  - name: " + tt.param + "\n"
+			_, errs := Parse([]byte(data), "t.yaml")
+			var got []string
+			for _, err := range errs {
+				got = append(got, err.Error())
+			}
+			if strings.Join(got, "\n") != tt.err {
+				t.Errorf("errors = %q, want %q", got, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		data string
+		want []string
+	}{
+		{"json trailing comma", "t.json", "{\n  \"name\": \"x\",\n  \"command\": \"get pods\",\n}\n",
+			[]string{"t.json:4:1: invalid character '}' looking for beginning of object key string"}},
+		{"json bad value", "t.json", "{\"name\": x}",
+			[]string{"t.json:1:10: invalid character 'x' looking for beginning of value"}},
+		{"json truncated", "t.json", "{\"name\": \"x\"",
+			[]string{"t.json:1:12: unexpected end of JSON input"}},
+		{"json with tabs", "t.json", "{\n\t\"name\": \"x\",\n\t\"command\": \"get {{.a}}\"\n}",
+			[]string{"t.json:3:13: command uses {{.a}} but \"a\" is not a declared parameter"}},
+		{"json nested value", "t.json", `{"name": "x", "command": "get", "parameters": [{"name": "a", "type": "bogus"}]}`,
+			[]string{"t.json:1:70: parameter \"a\": unknown type \"bogus\" (expected one of: string, int, bool, enum, duration, resource-name)"}},
+		{"yaml syntax", "t.yaml", "name: x\ncommand: get pods\n  bad: indent\n",
+			[]string{"t.yaml:3: mapping values are not allowed in this context"}},
+		{"yaml types", "t.yaml", "name: [1]\ncommand: get\nparameters: x\n",
+			[]string{"t.yaml:1: cannot unmarshal !!seq into string", "t.yaml:3: cannot unmarshal !!str `x` into []templates.Parameter"}},
+		{"yaml unknown field", "t.yaml", "name: x\ncomand: get pods\n",
+			[]string{"t.yaml:2:1: unknown field \"comand\" (expected one of: command, description, name, parameters, steps)",
+				"t.yaml:1:1: missing required field \"command\" (or \"steps\" for a runbook)"}},
+		{"yaml list", "t.yaml", "- name: x\n  command: get pods\n- name: bad name\n  command: get {{.nope}}\n",
+			[]string{"t.yaml:3:9: invalid template name \"bad name\": use letters, digits, '-', '_' and '.'",
+				"t.yaml:4:12: command uses {{.nope}} but \"nope\" is not a declared parameter"}},
+		{"runbook variable name", "t.yaml", "name: x\nsteps:\n  - name: get-pod\n    command: get pods -o json\n    capture:\n      pod-name: '{.items[0].metadata.name}'\n",
+			[]string{"t.yaml:6:7: invalid variable name \"pod-name\": use letters, digits and '_'"}},
+		{"yaml not a template", "t.yaml", "just text\n",
+			[]string{"t.yaml:1:1: expected a template or a list of templates"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := Parse([]byte(tt.data), tt.path)
+			var got []string
+			for _, err := range errs {
+				got = append(got, err.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}